@@ -0,0 +1,309 @@
+package interpreter
+
+// An analysis of a program records where each identifier is declared and used as the parser type checks the program.
+// It is only collected when a tool needs it, such as the linter or the language server, since the parser doesn't
+// otherwise need to remember identifiers once the scope they are declared in ends.
+
+import (
+	"main/interpreter/environment"
+	"sort"
+)
+
+type DeclarationKind uint8
+
+const (
+	DeclarationVariable DeclarationKind = iota
+	// The arguments of functions and methods, along with the variables of match arms, which can be left unused
+	DeclarationParameter
+	DeclarationFunction
+	DeclarationStruct
+	DeclarationType
+	DeclarationImport
+	// The properties of structs and the methods of structs and types, which are the members of their declarations
+	DeclarationProperty
+	DeclarationMethod
+)
+
+// An identifier declared by the program
+type Declaration struct {
+	Name string
+	Kind DeclarationKind
+	// The span of the name where it is declared
+	Position environment.Position
+	Def      TypeDef
+	// The span of the whole declaration for functions, methods, structs and types, otherwise the same as the position
+	Span environment.Position
+	// The scope the identifier can be used in, members aren't declared in a scope
+	Scope *Scope
+	// The properties and methods of structs and the methods of types
+	Members []*Declaration
+	// Set once the identifier has been used, for the linter
+	used bool
+}
+
+// A use of an identifier, or of a member such as the property of a struct
+type Reference struct {
+	Name     string
+	Position environment.Position
+	// The declaration the identifier refers to, nil if it isn't declared by the program such as the functions of the
+	// standard library
+	Declaration *Declaration
+	Def         TypeDef
+}
+
+// The identifiers declared in a block
+type Scope struct {
+	// The span of the block, which is empty for the top-level scope since it's the whole program. The end is empty if
+	// the block was never closed.
+	Span         environment.Position
+	Parent       *Scope
+	declarations map[string]*Declaration
+}
+
+type Analysis struct {
+	// The identifiers declared by the program in the order they are declared, not including members
+	Declarations []*Declaration
+	References   []Reference
+	scopes       map[*TypeEnvironment]*Scope
+	// The declarations of structs and types by their names, used to find the members that are used
+	namedTypes map[string]*Declaration
+}
+
+func newAnalysis() *Analysis {
+	return &Analysis{
+		scopes:     make(map[*TypeEnvironment]*Scope),
+		namedTypes: make(map[string]*Declaration),
+	}
+}
+
+// Parses the program while recording where identifiers are declared and used, returning the analysis along with the
+// diagnostics of the program. The analysis is still returned if the program has errors, but it won't include the
+// statements that couldn't be parsed.
+func (p *Parser) Analyse() (*Analysis, []Diagnostic) {
+	p.analysis = newAnalysis()
+	_, diagnostics := p.Parse()
+	return p.analysis, diagnostics
+}
+
+// Gets the scope of a type environment, creating it if it hasn't been used yet
+func (a *Analysis) scope(typeEnv *TypeEnvironment) *Scope {
+	if scope, ok := a.scopes[typeEnv]; ok {
+		return scope
+	}
+	scope := &Scope{declarations: make(map[string]*Declaration)}
+	if parent := typeEnv.GetParent(); parent != nil {
+		scope.Parent = a.scope(parent)
+	}
+	a.scopes[typeEnv] = scope
+	return scope
+}
+
+// Finds the declaration an identifier refers to from a scope, which is nil if it isn't declared by the program
+func (a *Analysis) find(typeEnv *TypeEnvironment, name string) *Declaration {
+	for ; typeEnv != nil; typeEnv = typeEnv.GetParent() {
+		if _, ok := typeEnv.identifiers[name]; ok {
+			if scope, ok := a.scopes[typeEnv]; ok {
+				return scope.declarations[name]
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// Gets the declaration of an identifier that can be used at a position, which is the one in the innermost scope
+func (a *Analysis) Resolve(name string, position environment.Position) *Declaration {
+	var resolved *Declaration
+	for _, declaration := range a.Visible(position) {
+		if declaration.Name == name {
+			resolved = declaration
+		}
+	}
+	return resolved
+}
+
+// Gets the declarations that can be used at a position, ordered from the outermost scope to the innermost scope
+func (a *Analysis) Visible(position environment.Position) []*Declaration {
+	visible := make([]*Declaration, 0)
+	for _, declaration := range a.Declarations {
+		if !declaration.Scope.Contains(position) {
+			continue
+		}
+		// Variables can only be used after they are declared, while functions and types can be used anywhere
+		if declaration.Kind == DeclarationVariable || declaration.Kind == DeclarationImport {
+			if !isBefore(declaration.Position, position) {
+				continue
+			}
+		}
+		visible = append(visible, declaration)
+	}
+	// Inner scopes start after the scopes they are in, the sort is stable so later declarations in a scope stay last
+	sort.SliceStable(visible, func(i, j int) bool {
+		return isBefore(visible[i].Scope.Span, visible[j].Scope.Span)
+	})
+	return visible
+}
+
+// Checks whether a position is inside the scope
+func (s *Scope) Contains(position environment.Position) bool {
+	if s.Span.Line == 0 {
+		return true
+	}
+	if isBefore(position, s.Span) {
+		return false
+	}
+	if s.Span.EndLine == 0 {
+		return true
+	}
+	return position.Line < s.Span.EndLine || (position.Line == s.Span.EndLine && position.Column < s.Span.EndColumn)
+}
+
+// Checks whether the start of a span is before the start of another
+func isBefore(span environment.Position, other environment.Position) bool {
+	return span.Line < other.Line || (span.Line == other.Line && span.Column < other.Column)
+}
+
+// Gets the declaration and the definition of the identifier or member used at a position
+func (a *Analysis) ReferenceAt(position environment.Position) (Reference, bool) {
+	for _, reference := range a.References {
+		if spanContains(reference.Position, position) {
+			return reference, true
+		}
+	}
+	for _, declaration := range a.Declarations {
+		if spanContains(declaration.Position, position) {
+			return Reference{declaration.Name, declaration.Position, declaration, declaration.Def}, true
+		}
+		for _, member := range declaration.Members {
+			if spanContains(member.Position, position) {
+				return Reference{member.Name, member.Position, member, member.Def}, true
+			}
+		}
+	}
+	return Reference{}, false
+}
+
+// Checks whether a position is inside a span on a single line, such as the span of an identifier
+func spanContains(span environment.Position, position environment.Position) bool {
+	return span.Line == position.Line && position.Column >= span.Column && position.Column < span.EndColumn
+}
+
+// Records an identifier that has just been declared in the current scope, returning the declaration so that the
+// caller can add to it. Returns nil unless the program is being analysed.
+func (p *Parser) declare(name string, kind DeclarationKind, position environment.Position, def TypeDef) *Declaration {
+	if p.analysis == nil || name == "_" || name == "" {
+		return nil
+	}
+	scope := p.analysis.scope(p.currentTypeEnv)
+	// Top-level declarations are declared again once they are parsed after being hoisted
+	if existing, ok := scope.declarations[name]; ok && existing.Position == position {
+		existing.Def = def
+		return existing
+	}
+
+	if p.linting && kind == DeclarationVariable {
+		p.checkShadowing(name, position)
+	}
+	declaration := &Declaration{Name: name, Kind: kind, Position: position, Def: def, Span: position, Scope: scope}
+	scope.declarations[name] = declaration
+	p.analysis.Declarations = append(p.analysis.Declarations, declaration)
+	if kind == DeclarationStruct || kind == DeclarationType {
+		p.analysis.namedTypes[name] = declaration
+	}
+	return declaration
+}
+
+// Creates the declaration of a member of a struct or type, which is nil unless the program is being analysed
+func (p *Parser) declareMember(name string, kind DeclarationKind, position environment.Position, def TypeDef) *Declaration {
+	if p.analysis == nil {
+		return nil
+	}
+	return &Declaration{Name: name, Kind: kind, Position: position, Def: def, Span: position}
+}
+
+// Sets the members of a struct or type declaration
+func setMembers(declaration *Declaration, properties []*Declaration, methods []methodDeclaration) {
+	if declaration == nil {
+		return
+	}
+	declaration.Members = append(make([]*Declaration, 0, len(properties)+len(methods)), properties...)
+	for _, method := range methods {
+		declaration.Members = append(declaration.Members, method.declaration)
+	}
+}
+
+// Extends the span of a declaration to the end of the last token that was read
+func (p *Parser) endDeclaration(declaration *Declaration) {
+	if declaration != nil {
+		end := p.lexer.LastToken()
+		declaration.Span.EndLine, declaration.Span.EndColumn = end.Line, end.EndColumn
+	}
+}
+
+// Declares the variables of a block, such as the arguments of a function or the variables of a for loop
+func (p *Parser) declareScopedVariables(variables []scopedVariable) {
+	for _, variable := range variables {
+		p.currentTypeEnv.Set(variable.name, variable.def)
+		if variable.position.Line != 0 {
+			p.declare(variable.name, variable.kind, variable.position, variable.def)
+		}
+	}
+}
+
+// Gets the arguments of a function to declare in it's body, along with the positions they are declared at
+func argScope(def FuncDef, positions []environment.Position) []scopedVariable {
+	args := def.getArgScope()
+	for i := range args {
+		if i < len(positions) {
+			args[i].position = positions[i]
+			args[i].kind = DeclarationParameter
+		}
+	}
+	return args
+}
+
+// Starts the scope of a block that has just been opened
+func (p *Parser) beginScope() {
+	if p.analysis != nil {
+		start := p.lexer.LastToken()
+		p.analysis.scope(p.currentTypeEnv).Span = environment.Position{Line: start.Line, Column: start.Column}
+	}
+}
+
+// Ends the scope of the block that has just been closed
+func (p *Parser) endScope() {
+	if p.analysis != nil {
+		end := p.lexer.LastToken()
+		scope := p.analysis.scope(p.currentTypeEnv)
+		scope.Span.EndLine, scope.Span.EndColumn = end.Line, end.EndColumn
+	}
+}
+
+// Gets the definition of an identifier where it is used, recording the use if the program is being analysed
+func (p *Parser) lookup(token Token) TypeDef {
+	def, _ := p.currentTypeEnv.Get(token.Literal)
+	if p.analysis != nil && !p.hoisting && def != nil {
+		declaration := p.analysis.find(p.currentTypeEnv, token.Literal)
+		if declaration != nil {
+			declaration.used = true
+		}
+		p.analysis.References = append(p.analysis.References, Reference{token.Literal, token.Position(), declaration, def})
+	}
+	return def
+}
+
+// Records the use of a member of a struct, type or module
+func (p *Parser) referenceMember(typeName string, token Token, def TypeDef) {
+	if p.analysis == nil || p.hoisting {
+		return
+	}
+	var member *Declaration
+	if declaration, ok := p.analysis.namedTypes[typeName]; ok {
+		for _, declarationMember := range declaration.Members {
+			if declarationMember.Name == token.Literal {
+				member = declarationMember
+			}
+		}
+	}
+	p.analysis.References = append(p.analysis.References, Reference{token.Literal, token.Position(), member, def})
+}
@@ -0,0 +1,320 @@
+package interpreter
+
+// Go functions and structs are bound to values for use in the interpreter, with their definitions derived from their
+// Go types so that the definitions can't drift from the Go code they describe
+
+import (
+	"main/interpreter/environment"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+var goErrorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Derives the definition of a Go function and creates the value called by programs. An error returned as the last
+// result of the function becomes a runtime error, and arguments and results are converted between the Go types of the
+// function and the types values are stored as at runtime. Panics if the function uses types that have no equivalent.
+func BindFunc(fn any) (FuncDef, environment.Callable) {
+	function := reflect.ValueOf(fn)
+	if function.Kind() != reflect.Func {
+		panic("Cannot bind a value of type " + function.Type().String() + " as a function")
+	}
+	b := &binder{deriving: make(map[reflect.Type]bool)}
+	def, convert := b.funcDef(function.Type(), false)
+	if !convert {
+		// The function already takes and returns values as they are stored at runtime
+		return def, environment.NewNative(fn)
+	}
+	return def, b.bindFunc(function)
+}
+
+// Derives the definition of a Go struct, or a pointer to one, and converts it to a struct instance. Exported fields
+// become readonly properties holding the values the fields had when the struct was bound, followed by the exported
+// methods. Property names start with a lower case letter, such as "get" for a method named Get.
+func BindStruct(value any) (StructDef, []any) {
+	b := &binder{deriving: make(map[reflect.Type]bool)}
+	goValue := reflect.ValueOf(value)
+	def, ok := b.typeDef(goValue.Type(), false).(StructDef)
+	if !ok {
+		panic("Cannot bind a value of type " + goValue.Type().String() + " as a struct")
+	}
+	return def, b.toRuntime(goValue).([]any)
+}
+
+// Binds every Go function in a map of values passed to the interpreter, such as globals or a module, and derives the
+// definitions of them for the parser. Values that aren't functions must be of a type with an equivalent definition.
+// Nil values are skipped since they have no type to derive a definition from.
+func BindValues(values map[string]any) (map[string]TypeDef, map[string]any) {
+	defs := make(map[string]TypeDef, len(values))
+	bound := make(map[string]any, len(values))
+	for name, value := range values {
+		if value == nil {
+			continue
+		}
+		if reflect.TypeOf(value).Kind() == reflect.Func {
+			defs[name], bound[name] = BindFunc(value)
+			continue
+		}
+		b := &binder{deriving: make(map[reflect.Type]bool)}
+		defs[name] = b.typeDef(reflect.TypeOf(value), false)
+		bound[name] = b.toRuntime(reflect.ValueOf(value))
+	}
+	return defs, bound
+}
+
+type binder struct {
+	// The struct types whose definitions are being derived, used to reject recursive types
+	deriving map[reflect.Type]bool
+}
+
+// Derives the definition of a function type, and whether or not values have to be converted when it is called.
+// The first argument of the function type of a method is the receiver, which is skipped since it is bound.
+func (b *binder) funcDef(funcType reflect.Type, isMethod bool) (FuncDef, bool) {
+	firstArg := 0
+	if isMethod {
+		firstArg = 1
+	}
+	args := make([]TypeDef, 0, funcType.NumIn())
+	convert := false
+	for i := firstArg; i < funcType.NumIn(); i++ {
+		argType := funcType.In(i)
+		if funcType.IsVariadic() && i == funcType.NumIn()-1 {
+			argType = argType.Elem()
+		}
+		def := b.typeDef(argType, true)
+		args = append(args, def)
+		convert = convert || argType != GetRuntimeType(def)
+	}
+	def := NewFuncDef(args, funcType.IsVariadic(), nil)
+
+	results := funcType.NumOut()
+	if results > 0 && funcType.Out(results-1) == goErrorType {
+		results--
+		convert = true
+	}
+	if results > 1 {
+		panic("Cannot bind a function with more than one result that isn't an error")
+	}
+	if results == 1 {
+		def.ReturnType = b.typeDef(funcType.Out(0), false)
+		convert = convert || funcType.Out(0) != GetRuntimeType(def.ReturnType)
+	}
+	return def, convert
+}
+
+// Derives the definition of a Go type, which can only be a struct if it isn't taken as an argument since programs
+// can't pass back the Go value of a struct instance
+func (b *binder) typeDef(goType reflect.Type, isArg bool) TypeDef {
+	switch goType.Kind() {
+	case reflect.Int8:
+		return GenericTypeDef{TypeInt8}
+	case reflect.Int16:
+		return GenericTypeDef{TypeInt16}
+	case reflect.Int32:
+		return GenericTypeDef{TypeInt32}
+	case reflect.Int64, reflect.Int:
+		return GenericTypeDef{TypeInt64}
+	case reflect.Uint8:
+		return GenericTypeDef{TypeUint8}
+	case reflect.Uint16:
+		return GenericTypeDef{TypeUint16}
+	case reflect.Uint32:
+		return GenericTypeDef{TypeUint32}
+	case reflect.Uint64, reflect.Uint:
+		return GenericTypeDef{TypeUint64}
+	case reflect.Float32:
+		return GenericTypeDef{TypeFloat32}
+	case reflect.Float64:
+		return GenericTypeDef{TypeFloat64}
+	case reflect.String:
+		return GenericTypeDef{TypeString}
+	case reflect.Bool:
+		return GenericTypeDef{TypeBool}
+	case reflect.Interface:
+		if goType.NumMethod() == 0 {
+			return GenericTypeDef{TypeAny}
+		}
+	case reflect.Slice:
+		return NewArrayDef(b.typeDef(goType.Elem(), isArg), -1)
+	case reflect.Map:
+		return NewMapDef(b.typeDef(goType.Key(), isArg), b.typeDef(goType.Elem(), isArg))
+	case reflect.Pointer:
+		if goType.Elem().Kind() == reflect.Struct {
+			return b.structDef(goType, isArg)
+		}
+	case reflect.Struct:
+		return b.structDef(goType, isArg)
+	}
+	panic("Cannot bind a value of type " + goType.String() + " since there is no equivalent type")
+}
+
+func (b *binder) structDef(goType reflect.Type, isArg bool) StructDef {
+	if isArg {
+		panic("Cannot bind a function that takes a struct of type " + goType.String() + " as an argument")
+	}
+	if b.deriving[goType] {
+		panic("Cannot bind a struct of type " + goType.String() + " since it contains itself")
+	}
+	b.deriving[goType] = true
+	defer delete(b.deriving, goType)
+
+	structType := goType
+	if goType.Kind() == reflect.Pointer {
+		structType = goType.Elem()
+	}
+	def := NewStructDef(make(map[string]int), nil, structType.Name()).InstanceDef()
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		name := propertyName(field.Name)
+		def.Properties[name] = len(def.PropertyDefs)
+		def.PropertyDefs = append(def.PropertyDefs, b.typeDef(field.Type, false))
+		// Assigning to the property wouldn't change the Go value, so it isn't allowed
+		def.ReadonlyProperties[name] = true
+	}
+	def.DataProperties = len(def.PropertyDefs)
+	for i := 0; i < goType.NumMethod(); i++ {
+		method := goType.Method(i)
+		methodDef, _ := b.funcDef(method.Func.Type(), true)
+		def.Properties[propertyName(method.Name)] = len(def.PropertyDefs)
+		def.PropertyDefs = append(def.PropertyDefs, methodDef)
+	}
+	return def
+}
+
+// Gets the name of a property from the name of an exported field or method
+func propertyName(name string) string {
+	first, size := utf8.DecodeRuneInString(name)
+	return string(unicode.ToLower(first)) + name[size:]
+}
+
+// Creates a function that converts the arguments passed by the interpreter before calling the Go function
+func (b *binder) bindFunc(function reflect.Value) environment.Callable {
+	funcType := function.Type()
+	returnsError := funcType.NumOut() > 0 && funcType.Out(funcType.NumOut()-1) == goErrorType
+	return environment.NativeFunc(func(env *environment.Environment, args []any) any {
+		argVals := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			var argType reflect.Type
+			if funcType.IsVariadic() && i >= funcType.NumIn()-1 {
+				argType = funcType.In(funcType.NumIn() - 1).Elem()
+			} else {
+				argType = funcType.In(i)
+			}
+			argVals[i] = fromRuntime(arg, argType)
+		}
+
+		out := function.Call(argVals)
+		if returnsError {
+			if err := out[len(out)-1]; !err.IsNil() {
+				panic(err.Interface())
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return nil
+		}
+		return b.toRuntime(out[0])
+	})
+}
+
+// Converts a Go value to the way values of it's definition are stored at runtime
+func (b *binder) toRuntime(value reflect.Value) any {
+	goType := value.Type()
+	switch goType.Kind() {
+	case reflect.Interface:
+		if value.IsNil() {
+			return nil
+		}
+		return value.Elem().Interface()
+	case reflect.Slice:
+		def := b.typeDef(goType, false)
+		runtimeType := GetRuntimeType(def)
+		if goType == runtimeType {
+			return value.Interface()
+		}
+		converted := reflect.MakeSlice(runtimeType, value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			converted.Index(i).Set(reflect.ValueOf(b.toRuntime(value.Index(i))))
+		}
+		return converted.Interface()
+	case reflect.Map:
+		def := b.typeDef(goType, false)
+		runtimeType := GetRuntimeType(def)
+		if goType == runtimeType {
+			return value.Interface()
+		}
+		converted := reflect.MakeMapWithSize(runtimeType, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			converted.SetMapIndex(reflect.ValueOf(b.toRuntime(iter.Key())), reflect.ValueOf(b.toRuntime(iter.Value())))
+		}
+		return converted.Interface()
+	case reflect.Pointer, reflect.Struct:
+		return b.structToRuntime(value)
+	}
+	// Numbers, strings and bools of named Go types are stored as the basic type
+	return value.Convert(GetRuntimeType(b.typeDef(goType, false))).Interface()
+}
+
+// Converts a Go struct to a struct instance, an array of the values of it's fields followed by it's methods
+func (b *binder) structToRuntime(value reflect.Value) any {
+	if value.Kind() == reflect.Pointer && value.IsNil() {
+		return nil
+	}
+	structValue := reflect.Indirect(value)
+
+	var instance []any
+	for i := 0; i < structValue.NumField(); i++ {
+		if field := structValue.Type().Field(i); field.IsExported() && !field.Anonymous {
+			instance = append(instance, b.toRuntime(structValue.Field(i)))
+		}
+	}
+	for i := 0; i < value.NumMethod(); i++ {
+		// The method is bound once, the Go value is already bound so the self arg passed by the interpreter is dropped
+		method := value.Method(i)
+		_, convert := b.funcDef(method.Type(), false)
+		var native environment.Callable
+		if convert {
+			native = b.bindFunc(method)
+		} else {
+			native = environment.NewNative(method.Interface())
+		}
+		instance = append(instance, environment.NativeFunc(func(env *environment.Environment, args []any) any {
+			return native.Call(env, args[1:])
+		}))
+	}
+	return instance
+}
+
+// Converts a value stored at runtime to the Go type of an argument
+func fromRuntime(value any, goType reflect.Type) reflect.Value {
+	if value == nil {
+		return reflect.Zero(goType)
+	}
+	runtimeValue := reflect.ValueOf(value)
+	if runtimeValue.Type() == goType {
+		return runtimeValue
+	}
+	switch goType.Kind() {
+	case reflect.Interface:
+		return runtimeValue
+	case reflect.Slice:
+		converted := reflect.MakeSlice(goType, runtimeValue.Len(), runtimeValue.Len())
+		for i := 0; i < runtimeValue.Len(); i++ {
+			converted.Index(i).Set(fromRuntime(runtimeValue.Index(i).Interface(), goType.Elem()))
+		}
+		return converted
+	case reflect.Map:
+		converted := reflect.MakeMapWithSize(goType, runtimeValue.Len())
+		iter := runtimeValue.MapRange()
+		for iter.Next() {
+			converted.SetMapIndex(fromRuntime(iter.Key().Interface(), goType.Key()), fromRuntime(iter.Value().Interface(), goType.Elem()))
+		}
+		return converted
+	}
+	return runtimeValue.Convert(goType)
+}
@@ -0,0 +1,258 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"main/interpreter"
+	"main/interpreter/environment"
+	"strings"
+	"testing"
+)
+
+var (
+	int64Def   = interpreter.GenericTypeDef{Type: interpreter.TypeInt64}
+	float64Def = interpreter.GenericTypeDef{Type: interpreter.TypeFloat64}
+	stringDef  = interpreter.GenericTypeDef{Type: interpreter.TypeString}
+)
+
+// Parses and runs a program with the tree walker given globals bound from Go values along with print
+func runBound(t *testing.T, source string, values map[string]any) (string, *interpreter.RuntimeError) {
+	var output bytes.Buffer
+	values["print"] = func(args ...any) {
+		fmt.Fprintln(&output, args...)
+	}
+	globalDefs, globals := interpreter.BindValues(values)
+	ast, diagnostics := interpreter.NewParser(source, "test.lang", globalDefs, nil).Parse()
+	if interpreter.HasErrors(diagnostics) {
+		t.Fatalf("Expected the program to parse, got %v", diagnostics)
+	}
+	_, _, err := interpreter.Execute(context.Background(), ast, "test.lang", interpreter.EngineTreeWalker, false, environment.GCOptions{}, environment.Limits{}, globals, nil)
+	return output.String(), err
+}
+
+// Gets the message a function panics with, or an empty string if it doesn't panic
+func panicMessage(f func()) (message string) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			message = fmt.Sprint(recovered)
+		}
+	}()
+	f()
+	return ""
+}
+
+// Derives the definitions of Go functions, checking the types of their arguments and results
+func TestBindFuncDefinition(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		fn   any
+		def  interpreter.FuncDef
+	}{
+		{
+			name: "numbers",
+			fn:   func(a int, b int8, c uint, d float32) int32 { return 0 },
+			def: interpreter.NewFuncDef([]interpreter.TypeDef{
+				int64Def,
+				interpreter.GenericTypeDef{Type: interpreter.TypeInt8},
+				interpreter.GenericTypeDef{Type: interpreter.TypeUint64},
+				interpreter.GenericTypeDef{Type: interpreter.TypeFloat32},
+			}, false, interpreter.GenericTypeDef{Type: interpreter.TypeInt32}),
+		},
+		{
+			name: "arrays and maps",
+			fn:   func(values []int, weights map[string]float64) []string { return nil },
+			def: interpreter.NewFuncDef([]interpreter.TypeDef{
+				interpreter.NewArrayDef(int64Def, -1),
+				interpreter.NewMapDef(stringDef, float64Def),
+			}, false, interpreter.NewArrayDef(stringDef, -1)),
+		},
+		{
+			name: "variadic",
+			fn:   func(separator string, values ...any) {},
+			def: interpreter.NewFuncDef([]interpreter.TypeDef{
+				stringDef,
+				interpreter.GenericTypeDef{Type: interpreter.TypeAny},
+			}, true, nil),
+		},
+		{
+			// The error becomes a runtime error instead of a result
+			name: "error result",
+			fn:   func(text string) (float64, error) { return 0, nil },
+			def:  interpreter.NewFuncDef([]interpreter.TypeDef{stringDef}, false, float64Def),
+		},
+		{
+			name: "only an error result",
+			fn:   func() error { return nil },
+			def:  interpreter.NewFuncDef([]interpreter.TypeDef{}, false, nil),
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			def, _ := interpreter.BindFunc(test.fn)
+			if !def.Equals(test.def) || def.Variadic != test.def.Variadic {
+				t.Errorf("Expected the definition %+v, got %+v", test.def, def)
+			}
+		})
+	}
+}
+
+// Calls bound Go functions from programs, checking arguments and results are converted and errors fail the program
+func TestBindFuncCall(t *testing.T) {
+	values := map[string]any{
+		"total": func(values []int, scale float32) float32 {
+			total := 0
+			for _, value := range values {
+				total += value
+			}
+			return float32(total) * scale
+		},
+		"join": func(separator string, values ...int) string {
+			parts := make([]string, len(values))
+			for i, value := range values {
+				parts[i] = fmt.Sprint(value)
+			}
+			return strings.Join(parts, separator)
+		},
+		"check": func(value int) (int, error) {
+			if value < 0 {
+				return 0, errors.New("value is negative")
+			}
+			return value * 2, nil
+		},
+	}
+	output, err := runBound(t, "print(total([1, 2, 3], 0.5))\nprint(join(\"-\", 1, 2, 3))\nprint(check(4))\nprint(check(0 - 1))\nprint(5)\n", values)
+	if output != "3\n1-2-3\n8\n" {
+		t.Errorf("Expected the output %q, got %q", "3\n1-2-3\n8\n", output)
+	}
+	if err == nil || err.Message != "value is negative" || err.Position.Line != 4 {
+		t.Errorf("Expected the error value is negative at line 4, got %v", err)
+	}
+}
+
+type account struct {
+	Owner   string
+	Balance float64
+	History []int64
+	secret  string
+}
+
+func (a *account) Deposit(amount float64) float64 {
+	a.Balance += amount
+	return a.Balance
+}
+
+func (a *account) OwnerName() string {
+	return a.Owner + a.secret
+}
+
+// Binds a Go struct, checking it's exported fields become readonly properties followed by it's methods
+func TestBindStruct(t *testing.T) {
+	value := &account{Owner: "ada", Balance: 10, History: []int64{1, 2}, secret: "!"}
+	def, instance := interpreter.BindStruct(value)
+
+	properties := []string{"owner", "balance", "history", "deposit", "ownerName"}
+	if len(def.Properties) != len(properties) || def.DataProperties != 3 {
+		t.Fatalf("Expected the properties %v with 3 holding data, got %v with %d", properties, def.Properties, def.DataProperties)
+	}
+	for _, name := range properties {
+		if _, ok := def.Properties[name]; !ok {
+			t.Errorf("Expected a property named %s", name)
+		}
+	}
+	for i, expected := range []interpreter.TypeDef{stringDef, float64Def, interpreter.NewArrayDef(int64Def, -1)} {
+		name := properties[i]
+		if !def.PropertyDefs[def.Properties[name]].Equals(expected) || !def.ReadonlyProperties[name] {
+			t.Errorf("Expected %s to be a readonly property of type %+v, got %+v", name, expected, def.PropertyDefs[def.Properties[name]])
+		}
+	}
+	if !def.PropertyDefs[def.Properties["deposit"]].Equals(interpreter.NewFuncDef([]interpreter.TypeDef{float64Def}, false, float64Def)) {
+		t.Errorf("Expected deposit to take and return a float64, got %+v", def.PropertyDefs[def.Properties["deposit"]])
+	}
+	if len(instance) != len(properties) || instance[def.Properties["owner"]] != "ada" || instance[def.Properties["balance"]] != 10.0 {
+		t.Errorf("Expected an instance holding the values of the fields, got %v", instance)
+	}
+
+	// The methods change the Go value, the properties keep the values the fields had when the struct was bound
+	output, err := runBound(t, "print(account.deposit(2.5), account.balance, account.ownerName())\n", map[string]any{"account": value})
+	if err != nil {
+		t.Fatalf("Expected the program to run, got %v", err)
+	}
+	if output != "12.5 10 ada!\n" {
+		t.Errorf("Expected the output %q, got %q", "12.5 10 ada!\n", output)
+	}
+}
+
+type node struct {
+	Next *node
+}
+
+// Binds Go values that have no equivalent in the interpreter, checking they are rejected
+func TestBindUnsupported(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		bind    func()
+		message string
+	}{
+		{
+			name:    "channel",
+			bind:    func() { interpreter.BindValues(map[string]any{"c": make(chan int)}) },
+			message: "Cannot bind a value of type chan int since there is no equivalent type",
+		},
+		{
+			name:    "complex argument",
+			bind:    func() { interpreter.BindFunc(func(c complex128) {}) },
+			message: "Cannot bind a value of type complex128 since there is no equivalent type",
+		},
+		{
+			name:    "interface with methods",
+			bind:    func() { interpreter.BindFunc(func(s fmt.Stringer) {}) },
+			message: "Cannot bind a value of type fmt.Stringer since there is no equivalent type",
+		},
+		{
+			name:    "struct argument",
+			bind:    func() { interpreter.BindFunc(func(a account) {}) },
+			message: "Cannot bind a function that takes a struct of type interpreter_test.account as an argument",
+		},
+		{
+			name:    "two results",
+			bind:    func() { interpreter.BindFunc(func() (int, string) { return 0, "" }) },
+			message: "Cannot bind a function with more than one result that isn't an error",
+		},
+		{
+			name:    "recursive struct",
+			bind:    func() { interpreter.BindStruct(&node{}) },
+			message: "Cannot bind a struct of type *interpreter_test.node since it contains itself",
+		},
+		{
+			name:    "function that isn't a function",
+			bind:    func() { interpreter.BindFunc(1) },
+			message: "Cannot bind a value of type int as a function",
+		},
+		{
+			name:    "struct that isn't a struct",
+			bind:    func() { interpreter.BindStruct("a") },
+			message: "Cannot bind a value of type string as a struct",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if message := panicMessage(test.bind); message != test.message {
+				t.Errorf("Expected the panic %q, got %q", test.message, message)
+			}
+		})
+	}
+}
+
+// Binds a map of values with a nil value, checking it is skipped
+func TestBindValuesNil(t *testing.T) {
+	defs, values := interpreter.BindValues(map[string]any{"missing": nil, "count": 1})
+	if _, ok := defs["missing"]; ok {
+		t.Error("Expected the nil value to be skipped")
+	}
+	if _, ok := values["missing"]; ok {
+		t.Error("Expected the nil value to be skipped")
+	}
+	if !defs["count"].Equals(int64Def) || values["count"] != int64(1) {
+		t.Errorf("Expected count to be bound as an int64, got %v of type %+v", values["count"], defs["count"])
+	}
+}
@@ -0,0 +1,158 @@
+package interpreter
+
+// Parsed programs are cached in files next to their source code so that they don't have to be lexed and parsed again
+// each time they are run. The nodes and the definitions of their type checks are encoded with gob, which must be told
+// every type that can be stored in an interface, including each instantiation of the generic nodes.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"main/interpreter/environment"
+	"main/interpreter/nodes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Changed whenever the nodes or definitions change in a way that stops older caches from being decoded correctly.
+// The version is hashed along with the source code, so caches written by other versions are ignored.
+const cacheVersion = 1
+
+// The extension of cache files, which replaces the extension of the source file
+const CacheExtension = ".langc"
+
+// A parsed and type checked program as it is stored in a cache file
+type CompiledProgram struct {
+	AST []environment.Node
+}
+
+// The types of node that can be stored in a cache, which are registered with gob
+var cachedNodes []environment.Node
+
+func init() {
+	for _, def := range []TypeDef{
+		GenericTypeDef{}, FuncDef{}, MapDef{}, ArrayDef{}, StructDef{}, ModuleDef{}, TypeDeclarationDef{}, NamedTypeDef{},
+		UnionDef{},
+	} {
+		gob.Register(def)
+	}
+
+	cachedNodes = []environment.Node{
+		&nodes.And{}, &nodes.Assignment{}, &nodes.Block{}, &nodes.EqualityComparison{}, &nodes.FuncCall{},
+		&nodes.FuncDeclaration{}, &nodes.Global{}, &nodes.Identifier{}, &nodes.IfStatement{}, &nodes.Import{},
+		&nodes.LoopRange{}, &nodes.LoopWhile{}, &nodes.MapValue[string, any]{}, &nodes.MultiAssignment{}, &nodes.Not{},
+		&nodes.Or{}, &nodes.Return{}, &nodes.StructDeclaration{}, &nodes.StructProperty{},
+		&nodes.StructPropertyAssignment{}, &nodes.TypeCheck{}, &nodes.TypeDeclaration{}, &nodes.TypeMethod{},
+		&nodes.TypeSwitch{}, &nodes.Value{},
+	}
+	// The generic nodes are registered for every type the generators create them for
+	for _, genericType := range []GenericType{
+		TypeInt8, TypeInt16, TypeInt32, TypeInt64, TypeUint8, TypeUint16, TypeUint32, TypeUint64, TypeFloat32,
+		TypeFloat64, TypeString, TypeBool, TypeAny,
+	} {
+		def := GenericTypeDef{genericType}
+		generator := GetGenericTypeNode(def)
+		cachedNodes = append(cachedNodes,
+			generator.GetArrayInitialization(nil),
+			generator.GetArrayIndex(nil, nil),
+			generator.GetArrayAssignment(nil, nil),
+			generator.GetLoopArray("", "", nil, nil),
+			generator.GetDestructure(nil, nil, nil, nil),
+		)
+		if def.IsNumber() {
+			cachedNodes = append(cachedNodes,
+				generator.GetMathsOperation(nodes.MathsAddition, nil, nil),
+				generator.GetInequalityComparison(nodes.ComparisonGreaterThan, nil, nil),
+			)
+		}
+	}
+	for _, node := range cachedNodes {
+		gob.Register(node)
+	}
+
+	gob.Register(&runtimeTypeCheck{})
+	gob.Register(&variadicPacker{})
+}
+
+// Gets the path of the cache file of a source file
+func CachePath(sourcePath string) string {
+	return strings.TrimSuffix(sourcePath, filepath.Ext(sourcePath)) + CacheExtension
+}
+
+func hashSource(version int, source string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(strconv.Itoa(version) + "\n" + source))
+}
+
+// Writes a program parsed from source code to a cache file. The hash of the source code is written first, so that a
+// cache written for different source code can be ignored without decoding the program.
+func WriteCache(path string, source string, program *CompiledProgram) error {
+	return writeCache(path, hashSource(cacheVersion, source), program)
+}
+
+func writeCache(path string, hash [sha256.Size]byte, program *CompiledProgram) error {
+	var buffer bytes.Buffer
+	encoder := gob.NewEncoder(&buffer)
+	if err := encoder.Encode(hash); err != nil {
+		return err
+	}
+	if err := encoder.Encode(program); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buffer.Bytes(), 0644)
+}
+
+// Reads a program from a cache file, returning false if there isn't a cache or it was written for different source code
+func ReadCache(path string, source string) (*CompiledProgram, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(bufio.NewReader(file))
+	var hash [sha256.Size]byte
+	if err := decoder.Decode(&hash); err != nil || hash != hashSource(cacheVersion, source) {
+		return nil, false
+	}
+	program := &CompiledProgram{}
+	if err := decoder.Decode(program); err != nil {
+		return nil, false
+	}
+	return program, true
+}
+
+// Type checks are cached as the definition they were created from, since the function doing the check can't be encoded
+func (c *runtimeTypeCheck) GobEncode() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(&c.def)
+	return buffer.Bytes(), err
+}
+
+func (c *runtimeTypeCheck) GobDecode(data []byte) error {
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&c.def); err != nil {
+		return err
+	}
+	c.matches = GetRuntimeTypeCheck(c.def)
+	return nil
+}
+
+// Variadic packers are cached as the generic type of the array they pack arguments in to
+func (p *variadicPacker) GobEncode() ([]byte, error) {
+	return []byte{byte(p.elementType)}, nil
+}
+
+func (p *variadicPacker) GobDecode(data []byte) error {
+	if len(data) != 1 {
+		return errors.New("invalid variadic packer")
+	}
+	p.elementType = GenericType(data[0])
+	p.TypeNodeGenerator = GetGenericTypeNode(GenericTypeDef{p.elementType})
+	if p.TypeNodeGenerator == nil {
+		return errors.New("invalid variadic packer")
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"main/interpreter"
+	"main/interpreter/environment"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var (
+	numbersDefs, numbers = interpreter.BindValues(map[string]any{
+		"double": func(x int64) int64 {
+			return x * 2
+		},
+	})
+	cacheModuleDefs = map[string]map[string]interpreter.TypeDef{"numbers": numbersDefs}
+	cacheModules    = map[string]map[string]any{"numbers": numbers}
+)
+
+// Parses the program in testdata/cache, which uses every type of node that can be cached
+func parseCacheProgram(t *testing.T) (string, []environment.Node) {
+	source, err := os.ReadFile(filepath.Join("testdata", "cache", "program.lang"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	globalDefs, _ := interpreter.BindValues(map[string]any{"print": func(args ...any) {}})
+	ast, diagnostics := interpreter.NewParser(string(source), "program.lang", globalDefs, cacheModuleDefs).Parse()
+	if interpreter.HasErrors(diagnostics) {
+		t.Fatalf("Expected the program to parse, got %v", diagnostics)
+	}
+	return string(source), ast
+}
+
+// Runs a parsed or cached program, returning what it printed
+func runAST(t *testing.T, ast []environment.Node) string {
+	var output bytes.Buffer
+	_, globals := interpreter.BindValues(map[string]any{
+		"print": func(args ...any) {
+			fmt.Fprintln(&output, args...)
+		},
+	})
+	_, _, err := interpreter.Execute(context.Background(), ast, "program.lang", interpreter.EngineTreeWalker, false, environment.GCOptions{}, environment.Limits{}, globals, cacheModules)
+	if err != nil {
+		t.Fatalf("Expected the program to run, got %v", err)
+	}
+	return output.String()
+}
+
+// Records the type of every pointer reachable from a value, which includes each node of a syntax tree
+func collectTypes(value reflect.Value, types map[reflect.Type]bool, visited map[uintptr]bool) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() || visited[value.Pointer()] {
+			return
+		}
+		visited[value.Pointer()] = true
+		types[value.Type()] = true
+		collectTypes(value.Elem(), types, visited)
+	case reflect.Interface:
+		if !value.IsNil() {
+			collectTypes(value.Elem(), types, visited)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			collectTypes(value.Index(i), types, visited)
+		}
+	case reflect.Map:
+		iter := value.MapRange()
+		for iter.Next() {
+			collectTypes(iter.Value(), types, visited)
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			collectTypes(value.Field(i), types, visited)
+		}
+	}
+}
+
+// Caches a program using every type of node, checking the program read from the cache prints the same output as the
+// program parsed from the source code
+func TestCacheRoundTrip(t *testing.T) {
+	source, ast := parseCacheProgram(t)
+	path := filepath.Join(t.TempDir(), "program.langc")
+	if err := interpreter.WriteCache(path, source, &interpreter.CompiledProgram{AST: ast}); err != nil {
+		t.Fatal(err)
+	}
+	program, ok := interpreter.ReadCache(path, source)
+	if !ok {
+		t.Fatal("Expected the cache to be read")
+	}
+
+	types := map[reflect.Type]bool{}
+	collectTypes(reflect.ValueOf(program.AST), types, map[uintptr]bool{})
+	for _, node := range interpreter.CachedNodes() {
+		if !types[reflect.TypeOf(node)] {
+			t.Errorf("Expected the program to use a %T node", node)
+		}
+	}
+
+	output := runAST(t, ast)
+	if output == "" {
+		t.Error("Expected the program to print something")
+	}
+	if cachedOutput := runAST(t, program.AST); cachedOutput != output {
+		t.Errorf("Expected the cached program to print\n%s\ngot\n%s", output, cachedOutput)
+	}
+}
+
+// Reads caches that can't be used, checking they are ignored so that the program is parsed instead
+func TestCacheIgnored(t *testing.T) {
+	source, ast := parseCacheProgram(t)
+	program := &interpreter.CompiledProgram{AST: ast}
+	validPath := filepath.Join(t.TempDir(), "valid.langc")
+	if err := interpreter.WriteCache(validPath, source, program); err != nil {
+		t.Fatal(err)
+	}
+	valid, err := os.ReadFile(validPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		name  string
+		write func(path string) error
+		// The source code the cache is read for
+		source string
+	}{
+		{
+			name: "changed source code",
+			write: func(path string) error {
+				return interpreter.WriteCache(path, source, program)
+			},
+			source: source + "print(1)\n",
+		},
+		{
+			name: "another version",
+			write: func(path string) error {
+				return interpreter.WriteCacheVersion(path, source, program, 0)
+			},
+			source: source,
+		},
+		{
+			name: "missing file",
+			write: func(path string) error {
+				return nil
+			},
+			source: source,
+		},
+		{
+			name: "corrupt file",
+			write: func(path string) error {
+				return os.WriteFile(path, []byte("not a cache"), 0644)
+			},
+			source: source,
+		},
+		{
+			// The hash matches but the program can't be decoded
+			name: "truncated file",
+			write: func(path string) error {
+				return os.WriteFile(path, valid[:len(valid)/2], 0644)
+			},
+			source: source,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "program.langc")
+			if err := test.write(path); err != nil {
+				t.Fatal(err)
+			}
+			if program, ok := interpreter.ReadCache(path, test.source); ok || program != nil {
+				t.Error("Expected the cache to be ignored")
+			}
+		})
+	}
+}
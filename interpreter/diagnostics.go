@@ -0,0 +1,97 @@
+package interpreter
+
+import (
+	"fmt"
+	"main/interpreter/environment"
+
+	"github.com/logrusorgru/aurora/v4"
+)
+
+// Identifies the kind of problem a diagnostic reports
+type DiagnosticCode string
+
+const (
+	DiagnosticSyntaxError DiagnosticCode = "syntax-error"
+	DiagnosticTypeError   DiagnosticCode = "type-error"
+	// A token that couldn't be read, such as a string literal that is never closed
+	DiagnosticLexerError DiagnosticCode = "lexer-error"
+	// Statements after a return statement, which are never run
+	DiagnosticUnreachableCode DiagnosticCode = "unreachable-code"
+	// A bug in the parser, which is reported instead of ending the program using it
+	DiagnosticInternalError DiagnosticCode = "internal-error"
+	// The rules only checked by the linter
+	DiagnosticUnusedVariable   DiagnosticCode = "unused-variable"
+	DiagnosticUnusedImport     DiagnosticCode = "unused-import"
+	DiagnosticShadowedVariable DiagnosticCode = "shadowed-variable"
+)
+
+type Severity uint8
+
+const (
+	// The program can't be run
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// A problem found in the source code of a program
+type Diagnostic struct {
+	// The span of the source code the diagnostic is about
+	environment.Position
+	Code     DiagnosticCode
+	Severity Severity
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprint(d.Line, ":", d.Column, ": ", d.Severity, ": ", d.Message, " [", d.Code, "]")
+}
+
+// Formats the diagnostic with colours for displaying in a terminal, along with the line of the source code it is about
+func (d Diagnostic) Render(filePath string, source string) string {
+	label := aurora.Red("[ERROR]")
+	if d.Severity == SeverityWarning {
+		label = aurora.Yellow("[WARNING]")
+	}
+
+	var kind string
+	switch d.Code {
+	case DiagnosticSyntaxError, DiagnosticLexerError:
+		kind = "Syntax error"
+	case DiagnosticTypeError:
+		kind = "Type error"
+	case DiagnosticUnreachableCode:
+		kind = "Unreachable code"
+	case DiagnosticInternalError:
+		kind = "Internal error"
+	case DiagnosticUnusedVariable:
+		kind = "Unused variable"
+	case DiagnosticUnusedImport:
+		kind = "Unused import"
+	case DiagnosticShadowedVariable:
+		kind = "Shadowed variable"
+	default:
+		kind = string(d.Code)
+	}
+
+	return fmt.Sprintln(label, aurora.Gray(5, kind+" at line "+fmt.Sprint(d.Line)+":"+fmt.Sprint(d.Column)+":")) +
+		fmt.Sprintln(" ", aurora.Gray(3, ">"), aurora.Red(d.Message)) +
+		fmt.Sprintln(" ", aurora.Gray(18, "in "+filePath)) +
+		d.Snippet(source)
+}
+
+// Checks whether any of the diagnostics are errors that stop the program from being run
+func HasErrors(diagnostics []Diagnostic) bool {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
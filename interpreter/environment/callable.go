@@ -0,0 +1,94 @@
+package environment
+
+import (
+	"reflect"
+)
+
+// A function that can be called by the interpreter, implemented by script functions, struct constructors, methods
+// and Go functions so that calls are made without reflection
+type Callable interface {
+	// Calls the function from the environment of the caller, which is nil when called by the virtual machine
+	Call(env *Environment, args []any) any
+}
+
+// A Go function that takes the arguments of a call directly
+type NativeFunc func(env *Environment, args []any) any
+
+func (f NativeFunc) Call(env *Environment, args []any) any {
+	return f(env, args)
+}
+
+// Adapts a Go function to a Callable, this is done once when the function is passed to the interpreter.
+// Common signatures are called directly, others fall back to calling the function with reflection.
+func NewNative(fn any) Callable {
+	switch fn := fn.(type) {
+	case Callable:
+		return fn
+	case func(env *Environment, args []any) any:
+		return NativeFunc(fn)
+	case func(...any):
+		return NativeFunc(func(env *Environment, args []any) any {
+			fn(args...)
+			return nil
+		})
+	case func(...any) any:
+		return NativeFunc(func(env *Environment, args []any) any {
+			return fn(args...)
+		})
+	case func():
+		return NativeFunc(func(env *Environment, args []any) any {
+			fn()
+			return nil
+		})
+	case func() string:
+		return NativeFunc(func(env *Environment, args []any) any {
+			return fn()
+		})
+	case func(string):
+		return NativeFunc(func(env *Environment, args []any) any {
+			fn(args[0].(string))
+			return nil
+		})
+	case func(string) string:
+		return NativeFunc(func(env *Environment, args []any) any {
+			return fn(args[0].(string))
+		})
+	case func(string, string):
+		return NativeFunc(func(env *Environment, args []any) any {
+			fn(args[0].(string), args[1].(string))
+			return nil
+		})
+	case func(string) []any:
+		return NativeFunc(func(env *Environment, args []any) any {
+			return fn(args[0].(string))
+		})
+	}
+
+	function := reflect.ValueOf(fn)
+	if function.Kind() != reflect.Func {
+		panic("Cannot create a native function from a value of type " + function.Type().String())
+	}
+	return NativeFunc(func(env *Environment, args []any) any {
+		argVals := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			argVals[i] = reflect.ValueOf(arg)
+		}
+		out := function.Call(argVals)
+		if len(out) > 0 {
+			return out[0].Interface()
+		}
+		return nil
+	})
+}
+
+// Adapts every function in a map of values passed to the interpreter, such as globals or a module, to a Callable
+func NewNativeValues(values map[string]any) map[string]any {
+	adapted := make(map[string]any, len(values))
+	for name, value := range values {
+		if value != nil && reflect.TypeOf(value).Kind() == reflect.Func {
+			value = NewNative(value)
+		}
+		adapted[name] = value
+	}
+	return adapted
+}
@@ -1,43 +1,70 @@
 package environment
 
 import (
+	"context"
 	"fmt"
 	"main/profiler"
-	"os"
-	"strconv"
+	"strings"
 	"time"
 )
 
 // Execution environment handles the storing of values, garbage collection, and evaluation of the AST
 type Environment struct {
-	identifiers map[string]any
-	parent      *Environment
+	// Values of identifiers, the parser resolves each identifier to a slot of the environment it is declared in
+	slots []any
+	// Names of the identifiers in each slot, used by the garbage collector
+	names  []string
+	parent *Environment
+	// Set once a function has been declared in the environment or one of it's children, since the function can
+	// outlive the environment it can't be reused
+	captured bool
 	// A pointer to an address that is always updated to the current environment being executed
 	currentExecutionEnv **Environment
 	// call stores which function call initialized the environment
 	Call Call
+	// The environment a function was called from, nil for environments that aren't of a function call
+	caller *Environment
+	// The position of the function call being made from the environment, which becomes the call site of the call
+	callSite Position
+	// The number of function calls the environment is nested in
+	callDepth int
 
 	returnCallback func(any)
 	// Public value of whether or not loops are broken and should exit (e.g. after a return or break statement)
 	IsBroken bool
+	// The statement being executed, used for the position of runtime errors
+	statement Node
 
-	ast           []Node
-	position      int
-	attachedRefs  map[string][]string
+	// Identifiers referenced by functions declared in the environment or it's children, which are never swept
+	pinned        map[string]struct{}
 	profile       bool
 	profileResult *profiler.ProfileResult
 
+	// Shared by the whole tree of environments
+	gc *GCOptions
+	// Statistics are collected by environments of function calls when profiling, other environments add to the
+	// statistics of their parent. Nil if statistics aren't being collected.
+	gcStats *profiler.GCStats
+	// Slots of identifiers that are waiting for the number of them to reach the threshold before they are swept
+	pendingSweep []int
+
+	// Shared by the whole tree of environments
+	limiter *Limiter
+
 	modules map[string]map[string]any
+	globals map[string]any
 }
 
-// A Call instance represents a function call in the call stack for stack trace displays
+// A Call instance represents a function call in the call stack for stack trace displays.
+// The line and column are of the call site the function was called from.
 type Call struct {
-	File string
-	Line int
-	Name string
+	File   string
+	Line   int
+	Column int
+	Name   string
 }
 
-func New(parent *Environment, call Call, modules map[string]map[string]any, profile bool) *Environment {
+func New(parent *Environment, call Call, modules map[string]map[string]any, globals map[string]any, profile bool) *Environment {
 	var profileResult *profiler.ProfileResult
 	if profile && call.Name != "" {
 		profileResult = &profiler.ProfileResult{
@@ -47,94 +74,229 @@ func New(parent *Environment, call Call, modules map[string]map[string]any, prof
 		}
 	}
 
-	var currentExecutionEnv **Environment
-	if parent != nil {
+	// The pointer is shared by the whole tree of environments so it is created by the root environment
+	currentExecutionEnv := new(*Environment)
+	gc := &GCOptions{}
+	var limiter *Limiter
+	var gcStats *profiler.GCStats
+	callDepth := 0
+	if parent == nil {
+		limiter = NewLimiter(context.Background(), Limits{})
+	} else {
 		currentExecutionEnv = parent.currentExecutionEnv
+		gc = parent.gc
+		limiter = parent.limiter
+		callDepth = parent.callDepth
+		gcStats = parent.gcStats
+		if gcStats != nil && profileResult != nil {
+			gcStats = &profiler.GCStats{}
+			profileResult.GC = gcStats
+		}
 	}
 
 	return &Environment{
-		identifiers:         make(map[string]any),
 		parent:              parent,
 		Call:                call,
-		attachedRefs:        make(map[string][]string),
 		modules:             modules,
+		globals:             globals,
 		profile:             profile,
 		profileResult:       profileResult,
 		currentExecutionEnv: currentExecutionEnv,
+		gc:                  gc,
+		gcStats:             gcStats,
+		limiter:             limiter,
+		callDepth:           callDepth,
 	}
 }
 
-func (e *Environment) Get(name string) any {
-	if value, ok := e.identifiers[name]; ok {
-		return value
+// Sets how the garbage collector runs for the environment and every environment created from it, which must be done
+// before any are created
+func (e *Environment) SetGCOptions(options GCOptions) {
+	*e.gc = options
+	if options.Stats {
+		e.gcStats = &profiler.GCStats{}
+		if e.profileResult != nil {
+			e.profileResult.GC = e.gcStats
+		}
 	}
-	if e.parent != nil {
-		return e.parent.Get(name)
+}
+
+// Gets the statistics of the garbage collector for the environment, nil if they aren't being collected
+func (e *Environment) GetGCStats() *profiler.GCStats {
+	return e.gcStats
+}
+
+// Gets the value in a slot of a parent environment with a depth of how many parent environments to go back
+func (e *Environment) Get(slot int, depth int) any {
+	for i := 0; i < depth; i++ {
+		e = e.parent
 	}
-	return nil
+	if slot >= len(e.slots) {
+		return nil
+	}
+	return e.slots[slot]
 }
 
-func (e *Environment) Set(name string, value any) {
-	e.identifiers[name] = value
+// Sets the value of an identifier in a slot of the environment
+func (e *Environment) Set(name string, slot int, value any) {
+	if slot >= len(e.slots) {
+		e.slots = append(e.slots, make([]any, slot+1-len(e.slots))...)
+		e.names = append(e.names, make([]string, slot+1-len(e.names))...)
+	}
+	e.slots[slot] = value
+	e.names[slot] = name
 }
 
 // Sets a value in a parent environment with a depth of how many parent environments to go back
-func (env *Environment) SetWithDepth(name string, value any, depth int) {
+func (env *Environment) SetWithDepth(name string, slot int, value any, depth int) {
 	for i := 0; i < depth; i++ {
 		env = env.GetParent()
 		if env == nil {
 			panic("Depth is greater than total available depth")
 		}
 	}
-	env.Set(name, value)
+	env.Set(name, slot, value)
 }
 
 func (e *Environment) NewChild(call Call) *Environment {
-	child := New(e, call, e.modules, e.profile)
+	child := New(e, call, e.modules, e.globals, e.profile)
 	child.SetReturnCallback(e.returnCallback)
 	return child
 }
 
+// Creates the environment of a call to a function declared in the environment, from the environment of the caller
+// which is nil if the function was called by Go code. The call site of the call is the position given to SetCallSite.
+func (e *Environment) NewCall(name string, caller *Environment) *Environment {
+	call := Call{Name: name, File: e.Call.File}
+	if caller != nil {
+		call.Line = caller.callSite.Line
+		call.Column = caller.callSite.Column
+	}
+	child := e.NewChild(call)
+	child.caller = caller
+	if caller != nil {
+		child.callDepth = caller.callDepth + 1
+	} else {
+		child.callDepth = e.callDepth + 1
+	}
+	if err := e.limiter.CheckCallDepth(child.callDepth); err != nil {
+		if caller != nil {
+			panic(caller.newRuntimeErrorAt(err, caller.callSite))
+		}
+		panic(err)
+	}
+	return child
+}
+
+// Sets the position of the function call about to be made from the environment
+func (e *Environment) SetCallSite(position Position) {
+	e.callSite = position
+}
+
+// Gets the environment for the next iteration of a loop, reusing the environment of the previous iteration
+// (which can be nil) unless a function captured it
+func (e *Environment) NewLoopChild(previous *Environment) *Environment {
+	if previous == nil || previous.captured {
+		return e.NewChild(Call{})
+	}
+	return previous
+}
+
+// Marks the environment and it's parents as captured by a function declared in it, pinning the identifiers the
+// function references so they aren't swept before it is called
+func (e *Environment) Capture(refs []string) {
+	for ; e != nil; e = e.parent {
+		e.captured = true
+		e.Pin(refs...)
+	}
+}
+
+// Stops the garbage collector from freeing identifiers of the environment, such as ones that can be used by Go code
+// after the statements referencing them have been executed
+func (e *Environment) Pin(names ...string) {
+	if e.pinned == nil {
+		e.pinned = make(map[string]struct{}, len(names))
+	}
+	for _, name := range names {
+		e.pinned[name] = struct{}{}
+	}
+}
+
 func (e *Environment) GetParent() *Environment {
 	return e.parent
 }
 
-// Generates the call stack of the up to the current call
-func (e *Environment) GetCallStackOutput() string {
-	output := ""
-	if e.Call.Name != "" {
-		output = "\tFile: " + e.Call.File + ", Line: " + strconv.Itoa(e.Call.Line) + ", In " + e.Call.Name
+// Gets the calls that lead to the statement being executed in the environment, starting with the innermost.
+// The position of each call is where it currently is, so the position of a caller is the call site of it's callee.
+func (e *Environment) GetCallStack() []Call {
+	return e.callStackAt(e.statementPosition())
+}
+
+// Gets the calls that lead to a position in the environment, starting with the innermost
+func (e *Environment) callStackAt(position Position) []Call {
+	var stack []Call
+	for env := e; env != nil; {
+		// Blocks such as loops and if statements are part of the function they are in
+		function := env
+		for function.Call.Name == "" && function.parent != nil {
+			function = function.parent
+		}
+		stack = append(stack, Call{
+			File:   function.Call.File,
+			Line:   position.Line,
+			Column: position.Column,
+			Name:   function.Call.Name,
+		})
+		position = Position{Line: function.Call.Line, Column: function.Call.Column}
+		env = function.caller
 	}
-	if e.parent != nil {
-		if output != "" {
-			output += "\n"
+	return stack
+}
+
+// Gets the position of the statement being executed. Blocks that haven't started executing a statement, such as an
+// empty loop body, are at the statement of the block they are in.
+func (e *Environment) statementPosition() Position {
+	for env := e; env != nil; env = env.parent {
+		if positioned, ok := env.statement.(Positioned); ok {
+			return positioned.GetPosition()
+		}
+		if env.Call.Name != "" {
+			break
 		}
-		output += e.parent.GetCallStackOutput()
 	}
-	return output
+	return Position{}
+}
+
+// Generates the call stack up to the current call, long call stacks only show the calls at each end
+func (e *Environment) GetCallStackOutput() string {
+	return formatCallStack(e.GetCallStack())
 }
 
-func (e *Environment) Execute(ast []Node) {
-	var prevExecutionEnv *Environment
+// Executes the statements of a block, sweeping identifiers once they are no longer used
+func (e *Environment) Execute(ast []Node, liveness *Liveness) {
 	// Update the current execution env
-	if e.currentExecutionEnv != nil {
-		prevExecutionEnv = *e.currentExecutionEnv
-		*e.currentExecutionEnv = e
-	} else {
-		e.currentExecutionEnv = &e
-	}
+	prevExecutionEnv := *e.currentExecutionEnv
+	*e.currentExecutionEnv = e
 	// Store start time for profiling
 	startTime := time.Now()
+	// Identifiers waiting to be swept from a previous execution of a reused environment may have been redeclared
+	e.pendingSweep = e.pendingSweep[:0]
+	// Executing a block counts as a step so that loops with empty bodies are still limited
+	if e.limiter.enabled {
+		e.step()
+	}
 
-	e.ast = ast
 	for i, node := range ast {
 		if e.IsBroken {
 			// If IsBroken is true, the environment should stop executing since a return statement has been reached
-			return
+			break
+		}
+		e.statement = node
+		if e.limiter.enabled {
+			e.step()
 		}
-		e.position = i
 		node.Eval(e)
-		e.RunGC()
+		e.RunGC(liveness, i)
 	}
 
 	if e.profileResult != nil {
@@ -177,19 +339,27 @@ func (e *Environment) GetCurrentExecutionEnv() *Environment {
 	return *e.currentExecutionEnv
 }
 
-// Declares references attached to a certain identifier.
-// This allows the garbage collector to mark identifiers relied on by another identifier as in use.
-func (e *Environment) AttachReferences(name string, refs []string) {
-	e.attachedRefs[name] = refs
+// Makes the environment the one being executed again, after a panic stopped the execution of the environments
+// created from it
+func (e *Environment) ResetCurrentExecutionEnv() {
+	*e.currentExecutionEnv = e
 }
 
-// Performs a runtime panic, throwing an error and exiting the program
+// Performs a runtime panic, ending the program with a runtime error
 func (e *Environment) Panic(msg ...any) {
-	fmt.Println(append([]any{"panic:"}, msg...)...)
-	fmt.Println(e.GetCallStackOutput())
-	os.Exit(1)
+	panic(e.NewRuntimeError(strings.TrimSuffix(fmt.Sprintln(msg...), "\n")))
+}
+
+// Performs a runtime panic at the position of the expression that failed
+func (e *Environment) PanicAt(position Position, msg ...any) {
+	panic(e.newRuntimeErrorAt(strings.TrimSuffix(fmt.Sprintln(msg...), "\n"), position))
 }
 
 func (e *Environment) GetBuiltInModule(module string) map[string]any {
 	return e.modules[module]
 }
+
+// Gets a value that was passed to the interpreter as a global, such as print
+func (e *Environment) GetGlobal(name string) any {
+	return e.globals[name]
+}
@@ -1,24 +1,126 @@
 package environment
 
-func (e *Environment) RunGC() {
-	// Mark variables that are in use in a hash map
-	inUse := make(map[string]struct{}, len(e.identifiers))
-	for i := e.position + 1; i < len(e.ast); i++ {
-		for _, ref := range e.ast[i].References() {
-			inUse[ref] = struct{}{}
-			if attachedRefs, ok := e.attachedRefs[ref]; ok {
-				for _, ref := range attachedRefs {
-					inUse[ref] = struct{}{}
+import (
+	"main/profiler"
+	"time"
+)
+
+// The garbage collector frees the values of identifiers once the statements of a block no longer reference them.
+// Which identifiers are no longer used after each statement is computed once per block, so the cost of a sweep is
+// only the number of identifiers it frees.
+
+// How often the garbage collector sweeps identifiers that are no longer used
+type GCMode uint8
+
+const (
+	// Sweeps after every statement
+	GCEager GCMode = iota
+	// Never sweeps, values are only freed along with their environment
+	GCOff
+	// Sweeps once the number of identifiers waiting to be swept in an environment reaches a threshold
+	GCThreshold
+)
+
+type GCOptions struct {
+	Mode GCMode
+	// The number of identifiers waiting to be swept that triggers a sweep, used by GCThreshold
+	Threshold int
+	// Whether or not statistics of the garbage collector are collected
+	Stats bool
+}
+
+// The identifiers that are no longer used after each statement of a block
+type Liveness struct {
+	// Names of the identifiers whose last reference is in each statement
+	deadAfter [][]string
+	// The slots of the dead identifiers, resolved the first time each statement is swept since the parser gives an
+	// identifier the same slot every time the block is executed
+	deadSlots [][]int
+	resolved  []bool
+}
+
+// Computes the liveness of the identifiers referenced by the statements of a block
+func NewLiveness(ast []Node) *Liveness {
+	lastUse := make(map[string]int)
+	for i, node := range ast {
+		for _, ref := range node.References() {
+			lastUse[ref] = i
+		}
+	}
+
+	deadAfter := make([][]string, len(ast))
+	for name, i := range lastUse {
+		deadAfter[i] = append(deadAfter[i], name)
+	}
+	return &Liveness{
+		deadAfter: deadAfter,
+		deadSlots: make([][]int, len(ast)),
+		resolved:  make([]bool, len(ast)),
+	}
+}
+
+// Gets the slots of the environment that are no longer used after a statement.
+// Names that aren't declared in the environment belong to a parent or child environment and are skipped.
+func (l *Liveness) slotsAfter(e *Environment, statement int) []int {
+	if !l.resolved[statement] {
+		for _, name := range l.deadAfter[statement] {
+			for slot, ident := range e.names {
+				if ident == name {
+					l.deadSlots[statement] = append(l.deadSlots[statement], slot)
+					break
 				}
 			}
 		}
+		l.resolved[statement] = true
+	}
+	return l.deadSlots[statement]
+}
+
+// Frees the identifiers of the environment that are no longer used after a statement
+func (e *Environment) RunGC(liveness *Liveness, statement int) {
+	if e.gc.Mode == GCOff {
+		return
+	}
+	var startTime time.Time
+	if e.gcStats != nil {
+		startTime = time.Now()
+	}
+
+	dead := liveness.slotsAfter(e, statement)
+	if e.gc.Mode == GCThreshold {
+		e.pendingSweep = append(e.pendingSweep, dead...)
+		if len(e.pendingSweep) < e.gc.Threshold {
+			return
+		}
+		dead = e.pendingSweep
+		e.pendingSweep = e.pendingSweep[:0]
+	}
+
+	live := 0
+	if e.gcStats != nil {
+		for _, value := range e.slots {
+			if value != nil {
+				live++
+			}
+		}
 	}
 
-	// Sweep identifiers that are out of scope from memory
-	for ident := range e.identifiers {
-		if _, ok := inUse[ident]; !ok {
-			delete(e.identifiers, ident)
-			delete(e.attachedRefs, ident)
+	swept := 0
+	for _, slot := range dead {
+		// Identifiers referenced by a function declared in the environment can be used whenever it is called
+		if _, ok := e.pinned[e.names[slot]]; ok || e.slots[slot] == nil {
+			continue
 		}
+		e.slots[slot] = nil
+		swept++
+	}
+
+	if e.gcStats != nil {
+		e.gcStats.Add(profiler.GCStats{
+			Runs:     1,
+			Swept:    swept,
+			Duration: time.Since(startTime),
+			PeakLive: live,
+		})
 	}
 }
@@ -0,0 +1,122 @@
+package environment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Limits stop programs, such as ones supplied by users, from running forever or using too much memory. Both engines
+// check them as the program runs and end the program with a runtime error that wraps ErrLimitExceeded.
+
+type Limits struct {
+	// The maximum number of statements executed by the tree walker, or instructions executed by the virtual machine.
+	// 0 for no limit.
+	MaxSteps int64
+	// The maximum number of function calls that can be nested in each other, 0 for DefaultMaxCallDepth. Unlike the
+	// other limits there is always a maximum call depth, since without one unbounded recursion crashes the interpreter.
+	MaxCallDepth int
+	// The maximum number of array elements that can be allocated over the whole program, 0 for no limit
+	MaxArrayElements int64
+}
+
+var (
+	// Wrapped by the error of every limit, including the context of the program being cancelled
+	ErrLimitExceeded = errors.New("execution limit exceeded")
+	ErrStepLimit     = fmt.Errorf("%w: too many steps were executed", ErrLimitExceeded)
+	// Wrapped by the error of function calls being nested deeper than the maximum call depth
+	ErrStackOverflow = fmt.Errorf("%w: stack overflow", ErrLimitExceeded)
+	ErrArrayLimit    = fmt.Errorf("%w: too many array elements were allocated", ErrLimitExceeded)
+)
+
+// The maximum call depth of programs that don't set one, which is far less than the depth that overflows the Go stack
+// of the tree walker
+const DefaultMaxCallDepth = 10000
+
+// The number of steps between checks of whether the context is done, since checking it is slower than a step
+const contextCheckInterval = 1024
+
+// Enforces the limits of a program, shared by the whole program
+type Limiter struct {
+	ctx          context.Context
+	limits       Limits
+	maxCallDepth int
+	// Set if there are any limits or the context can be cancelled, otherwise nothing needs to be checked
+	enabled       bool
+	steps         int64
+	arrayElements int64
+}
+
+func NewLimiter(ctx context.Context, limits Limits) *Limiter {
+	maxCallDepth := limits.MaxCallDepth
+	if maxCallDepth <= 0 {
+		maxCallDepth = DefaultMaxCallDepth
+	}
+	return &Limiter{
+		ctx:          ctx,
+		limits:       limits,
+		maxCallDepth: maxCallDepth,
+		// The call depth is always checked, so a maximum call depth alone doesn't need steps to be counted
+		enabled: ctx.Done() != nil || limits.MaxSteps > 0 || limits.MaxArrayElements > 0,
+	}
+}
+
+// Whether or not steps and arrays need to be counted, so that callers can skip counting when there are no limits
+func (l *Limiter) Enabled() bool {
+	return l.enabled
+}
+
+// Counts a step, returning an error if there have been too many or the context is done
+func (l *Limiter) Step() error {
+	l.steps++
+	if l.limits.MaxSteps > 0 && l.steps > l.limits.MaxSteps {
+		return ErrStepLimit
+	}
+	if l.steps%contextCheckInterval == 0 {
+		if err := l.ctx.Err(); err != nil {
+			return fmt.Errorf("%w: %w", ErrLimitExceeded, err)
+		}
+	}
+	return nil
+}
+
+// Returns an error if a function call at a depth would nest calls too deeply. This is checked even if the limiter
+// isn't enabled.
+func (l *Limiter) CheckCallDepth(depth int) error {
+	if depth > l.maxCallDepth {
+		return fmt.Errorf("%w, more than %d function calls are nested", ErrStackOverflow, l.maxCallDepth)
+	}
+	return nil
+}
+
+// Counts the elements of an array being allocated, returning an error if too many have been allocated
+func (l *Limiter) AllocateArray(elements int) error {
+	l.arrayElements += int64(elements)
+	if l.limits.MaxArrayElements > 0 && l.arrayElements > l.limits.MaxArrayElements {
+		return ErrArrayLimit
+	}
+	return nil
+}
+
+// Sets the limits of the program along with the context that cancels it, which restarts the counts of the limits.
+// The limiter is shared by every environment of the program, including ones that have already been created.
+func (e *Environment) SetLimits(ctx context.Context, limits Limits) {
+	*e.limiter = *NewLimiter(ctx, limits)
+}
+
+// Counts a step of the program, ending the program if a limit has been exceeded
+func (e *Environment) step() {
+	if err := e.limiter.Step(); err != nil {
+		panic(err)
+	}
+}
+
+// Counts the elements of an array being allocated by the program, ending the program if too many have been allocated
+func (e *Environment) AllocateArray(elements int) {
+	if !e.limiter.enabled {
+		return
+	}
+	if err := e.limiter.AllocateArray(elements); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,69 @@
+package environment
+
+import (
+	"strconv"
+	"strings"
+)
+
+// A span of the source code from a start position to an end position, embedded in nodes so that errors can show
+// where they happened
+type Position struct {
+	Line int
+	// Starting from 1, 0 if the column is unknown
+	Column  int
+	EndLine int
+	// The column after the last character of the span
+	EndColumn int
+}
+
+func (p *Position) GetPosition() Position {
+	return *p
+}
+
+func (p *Position) SetPosition(pos Position) {
+	*p = pos
+}
+
+// Implemented by nodes that embed a position
+type Positioned interface {
+	GetPosition() Position
+	SetPosition(pos Position)
+}
+
+// Formats the line of the source code the position starts on, with the span underlined by a caret and tildes
+func (p Position) Snippet(source string) string {
+	lines := strings.Split(source, "\n")
+	if p.Line < 1 || p.Line > len(lines) || p.Column < 1 {
+		return ""
+	}
+	line := strings.TrimRight(lines[p.Line-1], "\r")
+	start := p.Column - 1
+	if start > len(line) {
+		start = len(line)
+	}
+	// Spans that go past the first line are underlined up to the end of it, positions without an end only get a caret
+	end := len(line)
+	if p.EndLine == 0 {
+		end = start + 1
+	} else if p.EndLine == p.Line && p.EndColumn > p.Column && p.EndColumn-1 < end {
+		end = p.EndColumn - 1
+	}
+
+	// Tabs are kept in the indentation of the underline so that it lines up with the source code
+	underline := ""
+	for _, char := range line[:start] {
+		if char == '\t' {
+			underline += "\t"
+		} else {
+			underline += " "
+		}
+	}
+	underline += "^"
+	if end-start > 1 {
+		underline += strings.Repeat("~", end-start-1)
+	}
+
+	lineNumber := strconv.Itoa(p.Line)
+	gutter := strings.Repeat(" ", len(lineNumber))
+	return " " + lineNumber + " | " + line + "\n " + gutter + " | " + underline + "\n"
+}
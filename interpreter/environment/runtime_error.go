@@ -0,0 +1,81 @@
+package environment
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// An error that ended the execution of a program, either from a runtime panic or a panic of the interpreter itself
+// such as a division by zero
+type RuntimeError struct {
+	Message string
+	// The calls the error happened in, starting with the innermost
+	CallStack []Call
+	// The position of the expression that failed, such as a division by zero or a call to a Go function that panicked.
+	// Errors that aren't from an expression, such as a limit being exceeded, are at the statement being executed.
+	Position Position
+	// The Go error that was panicked with, such as ErrStepLimit when a limit is exceeded. Nil if the panic wasn't
+	// with an error.
+	Cause error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Message + " (line " + strconv.Itoa(e.Position.Line) + ", column " + strconv.Itoa(e.Position.Column) + ")"
+}
+
+func (e *RuntimeError) Unwrap() error {
+	return e.Cause
+}
+
+// Gets the call stack in the format used by GetCallStackOutput
+func (e *RuntimeError) StackTrace() string {
+	return formatCallStack(e.CallStack)
+}
+
+// The number of calls shown at each end of a call stack too long to show in full, such as one that overflowed
+const callStackEndsShown = 10
+
+// Formats a call stack one call per line, only showing the calls at each end of long call stacks
+func formatCallStack(stack []Call) string {
+	lines := make([]string, 0, len(stack))
+	for i, call := range stack {
+		if len(stack) > callStackEndsShown*2+1 && i >= callStackEndsShown && i < len(stack)-callStackEndsShown {
+			if i == callStackEndsShown {
+				lines = append(lines, "\t... "+strconv.Itoa(len(stack)-callStackEndsShown*2)+" more calls ...")
+			}
+			continue
+		}
+		lines = append(lines, "\tFile: "+call.File+", Line: "+strconv.Itoa(call.Line)+":"+strconv.Itoa(call.Column)+", In "+call.Name)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Creates a runtime error from a value recovered from a panic, with the call stack of the environment
+func (e *Environment) NewRuntimeError(recovered any) *RuntimeError {
+	return e.newRuntimeErrorAt(recovered, e.statementPosition())
+}
+
+// Creates a runtime error at the position of the expression that failed
+func (e *Environment) newRuntimeErrorAt(recovered any, position Position) *RuntimeError {
+	if err, ok := recovered.(*RuntimeError); ok {
+		return err
+	}
+
+	err := &RuntimeError{Message: fmt.Sprint(recovered)}
+	if goErr, ok := recovered.(error); ok {
+		err.Message = goErr.Error()
+		err.Cause = goErr
+	}
+	err.Position = position
+	err.CallStack = e.callStackAt(position)
+	return err
+}
+
+// Converts a panic of the Go code run by an expression, such as a division by zero or a Go function, in to a runtime
+// error at the position of the expression. Must be deferred by the node of the expression.
+func (e *Environment) RecoverAt(position Position) {
+	if recovered := recover(); recovered != nil {
+		panic(e.newRuntimeErrorAt(recovered, position))
+	}
+}
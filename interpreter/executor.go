@@ -1,22 +1,104 @@
 package interpreter
 
 import (
+	"context"
+	"errors"
 	"main/interpreter/environment"
+	"main/interpreter/nodes"
+	"main/interpreter/vm"
 	"main/profiler"
 )
 
+// The way a program's AST is executed
+type Engine uint8
+
+const (
+	// Evaluates the nodes of the AST directly
+	EngineTreeWalker Engine = iota
+	// Compiles the AST to bytecode that is run by a virtual machine. Programs using features that the compiler
+	// does not support yet, or that are being profiled or given options for the garbage collector, are run by the
+	// tree walker instead.
+	EngineBytecode
+)
+
+// An error that ended the execution of a program
+type RuntimeError = environment.RuntimeError
+
 // Execute a program in the interpreter, loading all globals and modules in to the environment
-func Execute(ast []environment.Node, fileName string, runProfiler bool, globals map[string]any, modules map[string]map[string]any) *profiler.ProfileResult {
+// The profile result is returned when profiling or collecting statistics of the garbage collector.
+// If the bytecode engine can't run the program it is run by the tree walker, with fallback giving the reason.
+// Panics of the program and of the interpreter itself are recovered and returned as a runtime error, as are the
+// context being cancelled and the limits being exceeded.
+func Execute(ctx context.Context, ast []environment.Node, fileName string, engine Engine, runProfiler bool, gc environment.GCOptions, limits environment.Limits, globals map[string]any, modules map[string]map[string]any) (profileResult *profiler.ProfileResult, fallback error, err *RuntimeError) {
+	// Go functions are adapted to be called without reflection once, before either engine uses them
+	globals = environment.NewNativeValues(globals)
+	adaptedModules := make(map[string]map[string]any, len(modules))
+	for name, module := range modules {
+		adaptedModules[name] = environment.NewNativeValues(module)
+	}
+	modules = adaptedModules
+
+	if engine == EngineBytecode {
+		var program *vm.Program
+		if program, fallback = compileBytecode(ast, fileName, runProfiler, gc); fallback == nil {
+			return nil, nil, vm.Run(ctx, program, globals, modules, limits)
+		}
+	}
+
 	env := environment.New(nil, environment.Call{
 		File: fileName,
 		Line: 0,
 		Name: "main",
-	}, modules, runProfiler)
+	}, modules, globals, runProfiler)
+	env.SetGCOptions(gc)
+	env.SetLimits(ctx, limits)
+	if err := executeTree(env, ast); err != nil {
+		return nil, fallback, err
+	}
+	if !runProfiler && gc.Stats {
+		return &profiler.ProfileResult{Name: "main", GC: env.GetGCStats()}, fallback, nil
+	}
+	return env.GetProfileResult(), fallback, nil
+}
+
+// Compiles a program for the bytecode engine, returning why it has to be run by the tree walker if it can't be
+func compileBytecode(ast []environment.Node, fileName string, runProfiler bool, gc environment.GCOptions) (*vm.Program, error) {
+	if runProfiler {
+		return nil, errors.New("programs are only profiled by the tree engine")
+	}
+	// The virtual machine frees the variables of a function when it returns instead of sweeping them
+	if gc != (environment.GCOptions{}) {
+		return nil, errors.New("the options of the garbage collector are only used by the tree engine")
+	}
+	return vm.Compile(ast, fileName)
+}
+
+// Runs a program by walking it's AST in an environment, returning a runtime error if the program panics
+func executeTree(env *environment.Environment, ast []environment.Node) (err *RuntimeError) {
+	defer func() {
+		if r := recover(); r != nil {
+			// The current execution environment is only set once the statements start being executed
+			current := env.GetCurrentExecutionEnv()
+			if current == nil {
+				current = env
+			}
+			err = current.NewRuntimeError(r)
+			env.ResetCurrentExecutionEnv()
+		}
+	}()
 
-	for name, val := range globals {
-		env.Set(name, val)
+	// Top-level functions, structs and types are declared before the first statement runs, matching the
+	// parser which allows them to be used before the point they are declared at
+	statements := make([]environment.Node, 0, len(ast))
+	for _, node := range ast {
+		switch node.(type) {
+		case *nodes.FuncDeclaration, *nodes.StructDeclaration, *nodes.TypeDeclaration:
+			node.Eval(env)
+		default:
+			statements = append(statements, node)
+		}
 	}
 
-	env.Execute(ast)
-	return env.GetProfileResult()
+	env.Execute(statements, environment.NewLiveness(statements))
+	return nil
 }
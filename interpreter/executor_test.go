@@ -0,0 +1,347 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"main/interpreter"
+	"main/interpreter/environment"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var engines = map[string]interpreter.Engine{
+	"tree":     interpreter.EngineTreeWalker,
+	"bytecode": interpreter.EngineBytecode,
+}
+
+// Parses a program and runs it with an engine, returning what it printed. The program can call fail, a Go function
+// that panics. Programs must be able to be run by the engine instead of falling back to the tree walker.
+func run(t testing.TB, source string, engine interpreter.Engine, limits environment.Limits) (string, *interpreter.RuntimeError) {
+	var output bytes.Buffer
+	globalDefs, globals := interpreter.BindValues(map[string]any{
+		"print": func(args ...any) {
+			fmt.Fprintln(&output, args...)
+		},
+		"fail": func() int64 {
+			panic("failed")
+		},
+	})
+	ast, diagnostics := interpreter.NewParser(source, "test.lang", globalDefs, nil).Parse()
+	if interpreter.HasErrors(diagnostics) {
+		t.Fatalf("Expected the program to parse, got %v", diagnostics)
+	}
+	_, fallback, err := interpreter.Execute(context.Background(), ast, "test.lang", engine, false, environment.GCOptions{}, limits, globals, nil)
+	if fallback != nil {
+		t.Fatalf("Expected the program to be run by the engine, got %v", fallback)
+	}
+	return output.String(), err
+}
+
+// Runs each program in testdata/engines with both engines, checking they print the same output and fail with the same
+// error
+func TestEngineParity(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "engines", "*.lang"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("No programs in testdata/engines")
+	}
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".lang"), func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			treeOutput, treeErr := run(t, string(source), interpreter.EngineTreeWalker, environment.Limits{})
+			bytecodeOutput, bytecodeErr := run(t, string(source), interpreter.EngineBytecode, environment.Limits{})
+			if treeOutput == "" {
+				t.Error("Expected the program to print something")
+			}
+			if treeOutput != bytecodeOutput {
+				t.Errorf("Expected the same output from both engines, the tree walker printed\n%s\nthe bytecode engine printed\n%s", treeOutput, bytecodeOutput)
+			}
+			if (treeErr == nil) != (bytecodeErr == nil) {
+				t.Fatalf("Expected the same error from both engines, got %v and %v", treeErr, bytecodeErr)
+			}
+			if treeErr != nil && (treeErr.Error() != bytecodeErr.Error() || treeErr.StackTrace() != bytecodeErr.StackTrace()) {
+				t.Errorf("Expected the same error from both engines, the tree walker failed with\n%v\n%s\nthe bytecode engine failed with\n%v\n%s",
+					treeErr, treeErr.StackTrace(), bytecodeErr, bytecodeErr.StackTrace())
+			}
+		})
+	}
+}
+
+// Runs programs the bytecode engine can't run, checking they are run by the tree walker with the reason
+func TestBytecodeFallback(t *testing.T) {
+	globalDefs, globals := interpreter.BindValues(map[string]any{"print": func(args ...any) {}})
+	for _, test := range []struct {
+		name     string
+		source   string
+		profile  bool
+		gc       environment.GCOptions
+		fallback string
+	}{
+		{
+			name:     "struct",
+			source:   "var x = 1\nstruct P {\n\tname: string\n}\nprint(P{name: \"a\"}.name)\n",
+			fallback: "the bytecode engine does not support struct declarations (line 2)",
+		},
+		{
+			name:     "type",
+			source:   "type Id int64\nvar id Id = 1\nprint(id)\n",
+			fallback: "the bytecode engine does not support type declarations (line 1)",
+		},
+		{
+			name:     "function in a loop",
+			source:   "for i range 3 {\n\tfn f(): int64 {\n\t\treturn 1\n\t}\n\tprint(f())\n}\n",
+			fallback: "the bytecode engine does not support functions declared inside of loops (line 2)",
+		},
+		{
+			name:     "closure over a local",
+			source:   "fn outer(): int64 {\n\tvar x int64 = 1\n\tfn inner(): int64 {\n\t\treturn x\n\t}\n\treturn inner()\n}\nprint(outer())\n",
+			fallback: "the bytecode engine does not support functions using the variables of the function they are declared in (line 4)",
+		},
+		{
+			name:     "profiled",
+			source:   "print(1)\n",
+			profile:  true,
+			fallback: "programs are only profiled by the tree engine",
+		},
+		{
+			name:     "garbage collector statistics",
+			source:   "print(1)\n",
+			gc:       environment.GCOptions{Stats: true},
+			fallback: "the options of the garbage collector are only used by the tree engine",
+		},
+		{
+			name:     "garbage collector off",
+			source:   "print(1)\n",
+			gc:       environment.GCOptions{Mode: environment.GCOff},
+			fallback: "the options of the garbage collector are only used by the tree engine",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			ast, diagnostics := interpreter.NewParser(test.source, "test.lang", globalDefs, nil).Parse()
+			if interpreter.HasErrors(diagnostics) {
+				t.Fatalf("Expected the program to parse, got %v", diagnostics)
+			}
+			_, fallback, err := interpreter.Execute(context.Background(), ast, "test.lang", interpreter.EngineBytecode, test.profile, test.gc, environment.Limits{}, globals, nil)
+			if err != nil {
+				t.Fatalf("Expected the program to run, got %v", err)
+			}
+			if fallback == nil || fallback.Error() != test.fallback {
+				t.Errorf("Expected the program to be run by the tree walker since %s, got %v", test.fallback, fallback)
+			}
+		})
+	}
+}
+
+// Runs programs that fail with both engines, checking the error is at the expression that failed
+func TestRuntimeErrorPosition(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		source  string
+		message string
+		line    int
+		column  int
+	}{
+		{
+			name:    "division by zero",
+			source:  "print(1 / 0)\n",
+			message: "runtime error: integer divide by zero",
+			line:    1,
+			column:  7,
+		},
+		{
+			name:    "division by zero in a function",
+			source:  "fn div(a: int64, b: int64): int64 {\n\treturn a / b\n}\nprint(div(4, 0))\n",
+			message: "runtime error: integer divide by zero",
+			line:    2,
+			column:  9,
+		},
+		{
+			name:    "index out of bounds",
+			source:  "var a = [1, 2]\nprint(1, a[1 + 4])\n",
+			message: "Index out of array bounds",
+			line:    2,
+			column:  10,
+		},
+		{
+			name:    "negative index",
+			source:  "var a = [1, 2]\nvar i = 0 - 1\nprint(a[i])\n",
+			message: "Array index cannot be less than 0",
+			line:    3,
+			column:  7,
+		},
+		{
+			name:    "assignment out of bounds",
+			source:  "var a = [1, 2]\nfn f() {\n\ta[2] = 3\n}\nf()\n",
+			message: "Index out of array bounds",
+			line:    3,
+			column:  2,
+		},
+		{
+			name:    "Go function",
+			source:  "print(1, fail())\n",
+			message: "failed",
+			line:    1,
+			column:  10,
+		},
+		{
+			name:    "Go function in tail position",
+			source:  "fn f(): int64 {\n\treturn fail()\n}\nprint(f())\n",
+			message: "failed",
+			line:    2,
+			column:  9,
+		},
+		{
+			name:    "stack overflow",
+			source:  "fn f(): int64 {\n\treturn f() + 1\n}\nprint(f())\n",
+			message: "execution limit exceeded: stack overflow, more than 10000 function calls are nested",
+			line:    2,
+			column:  9,
+		},
+	} {
+		for engineName, engine := range engines {
+			t.Run(test.name+"/"+engineName, func(t *testing.T) {
+				_, err := run(t, test.source, engine, environment.Limits{})
+				if err == nil {
+					t.Fatal("Expected a runtime error")
+				}
+				if err.Message != test.message {
+					t.Errorf("Expected the message %q, got %q", test.message, err.Message)
+				}
+				if err.Position.Line != test.line || err.Position.Column != test.column {
+					t.Errorf("Expected the error at %d:%d, got %d:%d", test.line, test.column, err.Position.Line, err.Position.Column)
+				}
+				if call := err.CallStack[0]; call.Line != test.line || call.Column != test.column {
+					t.Errorf("Expected the innermost call at %d:%d, got %d:%d", test.line, test.column, call.Line, call.Column)
+				}
+			})
+		}
+	}
+}
+
+// Runs programs that fail with both engines, checking the snippet of the error underlines just the expression
+func TestRuntimeErrorSnippet(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		source  string
+		snippet string
+	}{
+		{
+			name:    "division by zero",
+			source:  "print(1 / 0)\n",
+			snippet: " 1 | print(1 / 0)\n   |       ^~~~~\n",
+		},
+		{
+			name:    "indented by tabs",
+			source:  "fn div(a: int64, b: int64): int64 {\n\treturn a / b\n}\nprint(div(4, 0))\n",
+			snippet: " 2 | \treturn a / b\n   | \t       ^~~~~\n",
+		},
+		{
+			name:    "Go function",
+			source:  "print(1, fail())\n",
+			snippet: " 1 | print(1, fail())\n   |          ^~~~~~\n",
+		},
+	} {
+		for engineName, engine := range engines {
+			t.Run(test.name+"/"+engineName, func(t *testing.T) {
+				_, err := run(t, test.source, engine, environment.Limits{})
+				if err == nil {
+					t.Fatal("Expected a runtime error")
+				}
+				if snippet := err.Position.Snippet(test.source); snippet != test.snippet {
+					t.Errorf("Expected the snippet\n%s\ngot\n%s", test.snippet, snippet)
+				}
+			})
+		}
+	}
+}
+
+// Runs recursion a million calls deep with the default maximum call depth, which tail calls don't count towards
+func TestTailCalls(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		source string
+		output string
+		// Whether the recursion overflows the stack, since the recursive call isn't in tail position
+		overflows bool
+	}{
+		{
+			name:   "tail recursion",
+			source: "fn count(n: int64, acc: int64): int64 {\n\tif n == 0 {\n\t\treturn acc\n\t}\n\treturn count(n - 1, acc + 1)\n}\nprint(count(1000000, 0))\n",
+			output: "1000000\n",
+		},
+		{
+			name:   "mutual tail recursion",
+			source: "fn isEven(n: int64): bool {\n\tif n == 0 {\n\t\treturn true\n\t}\n\treturn isOdd(n - 1)\n}\nfn isOdd(n: int64): bool {\n\tif n == 0 {\n\t\treturn false\n\t}\n\treturn isEven(n - 1)\n}\nprint(isEven(1000000), isOdd(1000001))\n",
+			output: "true true\n",
+		},
+		{
+			name:      "recursion not in tail position",
+			source:    "fn count(n: int64): int64 {\n\tif n == 0 {\n\t\treturn 0\n\t}\n\treturn count(n - 1) + 1\n}\nprint(count(1000000))\n",
+			overflows: true,
+		},
+	} {
+		for engineName, engine := range engines {
+			t.Run(test.name+"/"+engineName, func(t *testing.T) {
+				output, err := run(t, test.source, engine, environment.Limits{})
+				if test.overflows {
+					if !errors.Is(err, environment.ErrStackOverflow) {
+						t.Fatalf("Expected a stack overflow, got %v", err)
+					}
+					if len(err.CallStack) <= environment.DefaultMaxCallDepth {
+						t.Errorf("Expected more than %d calls in the call stack, got %d", environment.DefaultMaxCallDepth, len(err.CallStack))
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("Expected the program to run, got %v", err)
+				}
+				if output != test.output {
+					t.Errorf("Expected the output %q, got %q", test.output, output)
+				}
+			})
+		}
+	}
+}
+
+// Runs each program in benchmarks with both engines, run with: go test ./interpreter -run ^$ -bench .
+func BenchmarkPrograms(b *testing.B) {
+	paths, err := filepath.Glob(filepath.Join("benchmarks", "*.lang"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	globalDefs, globals := interpreter.BindValues(map[string]any{"print": func(args ...any) {}})
+
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ast, diagnostics := interpreter.NewParser(string(source), path, globalDefs, nil).Parse()
+		if interpreter.HasErrors(diagnostics) {
+			b.Fatalf("Expected %s to parse, got %v", path, diagnostics)
+		}
+		for _, engineName := range []string{"tree", "bytecode"} {
+			b.Run(strings.TrimSuffix(filepath.Base(path), ".lang")+"/"+engineName, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					_, fallback, err := interpreter.Execute(context.Background(), ast, path, engines[engineName], false, environment.GCOptions{}, environment.Limits{}, globals, nil)
+					if fallback != nil {
+						b.Fatalf("Expected the program to be run by the engine, got %v", fallback)
+					}
+					if err != nil {
+						b.Fatalf("Expected the program to run, got %v", err)
+					}
+				}
+			})
+		}
+	}
+}
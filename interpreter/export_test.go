@@ -0,0 +1,13 @@
+package interpreter
+
+import "main/interpreter/environment"
+
+// The types of node that can be stored in a cache
+func CachedNodes() []environment.Node {
+	return cachedNodes
+}
+
+// Writes a cache as it would be written by another version of the interpreter
+func WriteCacheVersion(path string, source string, program *CompiledProgram, version int) error {
+	return writeCache(path, hashSource(version, source), program)
+}
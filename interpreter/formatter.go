@@ -0,0 +1,786 @@
+package interpreter
+
+// The formatter reprints source code in one canonical style. It parses the tokens of a program, including it's
+// comments, without checking types so that programs can be formatted without the declarations they use.
+//
+// Statements and the members of struct declarations, type declarations and match statements are each put on their own
+// line, and blocks are indented with tabs. Lists such as function call arguments stay on one line unless the source
+// breaks them over multiple lines, in which case each item is put on it's own line with a trailing comma. A single
+// blank line is kept wherever the source has blank lines between statements or members.
+
+import (
+	"errors"
+	"strings"
+)
+
+// Marks where the values of the lines in a group are aligned, such as the types of the properties of a struct
+const alignMarker = "\x00"
+
+type formatter struct {
+	tokens []Token
+	pos    int
+	// The names of the structs declared by the program, since an identifier followed by a { is only the start of a
+	// struct initialization if the identifier is a struct
+	structs map[string]bool
+
+	lines []formattedLine
+	line  strings.Builder
+	// The indentation of the line being written, and the indentation that new lines start at
+	lineIndent int
+	indent     int
+	// The indentation of lines that continue a value from the line before, unless the value isn't broken
+	continuation int
+	// Set when a space should be written before the next text on the line
+	space bool
+	// A comment written at the end of the current line once it ends
+	comment string
+}
+
+type formattedLine struct {
+	indent int
+	text   string
+}
+
+// Raised to stop formatting when the source code can't be parsed
+type formatError struct {
+	err error
+}
+
+// Formats source code in the canonical style, returning an error if it can't be parsed
+func Format(source string) (formatted string, err error) {
+	lexer := NewLexer(source)
+	lexer.keepComments = true
+	f := &formatter{structs: make(map[string]bool), continuation: -1}
+	for {
+		token, err := lexer.Next()
+		if err != nil {
+			return "", err
+		}
+		f.tokens = append(f.tokens, token)
+		if token.Type == TokenEOF {
+			break
+		}
+	}
+	for i, token := range f.tokens[:len(f.tokens)-1] {
+		if token.Type == TokenStructDeclaration && f.tokens[i+1].Type == TokenIdentifier {
+			f.structs[f.tokens[i+1].Literal] = true
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			formatErr, ok := r.(formatError)
+			if !ok {
+				panic(r)
+			}
+			err = formatErr.err
+		}
+	}()
+	f.statements(false)
+	f.newLine()
+	return f.output(), nil
+}
+
+// Formats statements up to the end of the program or block
+func (f *formatter) statements(inBlock bool) {
+	for first := true; ; first = false {
+		f.skipLines(first, TokenNewLine, TokenSemiColon)
+		token := f.peek()
+		if token.Type == TokenRightBrace && inBlock {
+			return
+		} else if token.Type == TokenEOF {
+			if inBlock {
+				f.fail(token)
+			}
+			return
+		}
+
+		f.newLine()
+		f.continuation = -1
+		f.statement()
+		switch token := f.peek(); token.Type {
+		case TokenNewLine, TokenSemiColon, TokenRightBrace, TokenEOF:
+		default:
+			f.fail(token)
+		}
+	}
+}
+
+func (f *formatter) statement() {
+	switch token := f.peek(); token.Type {
+	case TokenVarDeclaration, TokenLetDeclaration:
+		f.token()
+		if f.peek().Type == TokenLeftSquareBracket || f.peek().Type == TokenLeftBrace {
+			closing := TokenRightSquareBracket
+			if f.peek().Type == TokenLeftBrace {
+				closing = TokenRightBrace
+			}
+			f.token()
+			f.space = false
+			for {
+				f.token(TokenIdentifier)
+				if f.peek().Type != TokenComma {
+					break
+				}
+				f.token()
+			}
+			f.space = false
+			f.token(closing)
+		} else {
+			f.token(TokenIdentifier)
+			if f.peek().Type != TokenEquals {
+				f.typeDef()
+			}
+		}
+		f.token(TokenEquals)
+		f.value()
+	case TokenFunctionDeclaration:
+		f.token()
+		f.signature()
+		f.block()
+	case TokenIfStatement:
+		f.ifStatement()
+	case TokenReturnStatement:
+		f.token()
+		f.value()
+	case TokenForStatement:
+		f.token()
+		f.token(TokenIdentifier)
+		if f.peek().Type == TokenComma {
+			f.token()
+			f.token(TokenIdentifier)
+		}
+		f.token(TokenRangeStatement)
+		f.value()
+		if f.peek().Type == TokenComma {
+			f.token()
+			f.value()
+		}
+		f.block()
+	case TokenWhileStatement:
+		f.token()
+		f.value()
+		f.block()
+	case TokenStructDeclaration:
+		f.token()
+		f.token(TokenIdentifier)
+		f.members(func() {
+			if f.peek().Type == TokenFunctionDeclaration {
+				f.method()
+				return
+			}
+			if f.peek().Type == TokenReadonly {
+				f.token()
+			}
+			f.token(TokenIdentifier)
+			f.colon(true)
+			f.typeDef()
+		})
+	case TokenTypeDeclaration:
+		f.token()
+		f.token(TokenIdentifier)
+		if f.peek().Type == TokenEquals {
+			f.token()
+			f.typeDef()
+			return
+		}
+		f.typeDef()
+		if f.peek().Type == TokenLeftBrace {
+			f.members(f.method)
+		}
+	case TokenImportStatement:
+		f.token()
+		f.token(TokenString)
+		if f.peek().Type == TokenAsStatement {
+			f.token()
+			f.token(TokenIdentifier)
+		}
+	case TokenMatchStatement:
+		f.token()
+		f.value()
+		f.members(func() {
+			if token := f.peek(); token.Type == TokenIdentifier && token.Literal == "_" {
+				f.token()
+			} else {
+				f.token(TokenIdentifier)
+				f.colon(false)
+				f.typeDef()
+			}
+			f.write("=>", 2, TokenEquals, TokenGreaterThan)
+			if f.peek().Type == TokenLeftBrace {
+				f.block()
+			} else {
+				f.statement()
+			}
+		})
+	case TokenIdentifier:
+		if f.peekAt(1).Type != TokenComma {
+			f.value()
+			return
+		}
+		// Multiple values are assigned to multiple variables
+		for {
+			f.token(TokenIdentifier)
+			if f.peek().Type != TokenComma {
+				break
+			}
+			f.token()
+		}
+		f.token(TokenEquals)
+		for {
+			f.value()
+			if f.peek().Type != TokenComma {
+				break
+			}
+			f.token()
+		}
+	default:
+		f.fail(token)
+	}
+}
+
+func (f *formatter) ifStatement() {
+	f.token(TokenIfStatement)
+	f.value()
+	f.block()
+	if f.peek().Type == TokenElseStatement {
+		f.token()
+		if f.peek().Type == TokenIfStatement {
+			f.ifStatement()
+		} else {
+			f.block()
+		}
+	}
+}
+
+// Formats a method of a struct or type declaration
+func (f *formatter) method() {
+	f.token(TokenFunctionDeclaration)
+	f.signature()
+	f.block()
+}
+
+// Formats the name, arguments and return type of a function
+func (f *formatter) signature() {
+	f.token(TokenIdentifier)
+	f.space = false
+	f.token(TokenLeftBracket)
+	for f.peek().Type != TokenRightBracket {
+		f.token(TokenIdentifier)
+		f.colon(false)
+		if f.peek().Type == TokenPeriod {
+			f.write("...", 3, TokenPeriod, TokenPeriod, TokenPeriod)
+			f.space = false
+		}
+		f.typeDef()
+		if f.peek().Type == TokenEquals {
+			f.token()
+			f.value()
+		}
+		if f.peek().Type != TokenComma {
+			break
+		}
+		f.skip()
+		if f.peek().Type != TokenRightBracket {
+			f.write(",", 0)
+		}
+	}
+	f.token(TokenRightBracket)
+	if f.peek().Type == TokenColon {
+		f.colon(false)
+		f.typeDef()
+	}
+}
+
+// Formats a code block, which is put on multiple lines unless it is empty
+func (f *formatter) block() {
+	f.token(TokenLeftBrace)
+	if f.isEmpty() {
+		f.space = false
+		f.token(TokenRightBrace)
+		return
+	}
+	f.indent++
+	f.statements(true)
+	f.indent--
+	f.newLine()
+	f.token(TokenRightBrace)
+}
+
+// Formats the members in the braces of a struct declaration, type declaration or match statement, each on it's own
+// line. Members can be separated by commas or new lines in the source but are always put on separate lines.
+func (f *formatter) members(member func()) {
+	f.token(TokenLeftBrace)
+	if f.isEmpty() {
+		f.space = false
+		f.token(TokenRightBrace)
+		return
+	}
+	f.indent++
+	for first := true; ; first = false {
+		f.skipLines(first, TokenNewLine, TokenComma)
+		if f.peek().Type == TokenRightBrace {
+			break
+		}
+		f.newLine()
+		f.continuation = -1
+		member()
+	}
+	f.indent--
+	f.newLine()
+	f.token(TokenRightBrace)
+}
+
+// Checks whether there is nothing but new lines before the closing brace of a block
+func (f *formatter) isEmpty() bool {
+	for i := f.pos; ; i++ {
+		switch f.tokens[i].Type {
+		case TokenNewLine, TokenSemiColon:
+		case TokenRightBrace:
+			f.pos = i
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// Formats a type definition, including unions
+func (f *formatter) typeDef() {
+	f.singleTypeDef()
+	// A second bar is the || operator which ends the type definition
+	for f.peek().Type == TokenBar && f.peekAt(1).Type != TokenBar {
+		f.token()
+		f.singleTypeDef()
+	}
+}
+
+func (f *formatter) singleTypeDef() {
+	switch token := f.peek(); token.Type {
+	case TokenLeftBracket:
+		f.token()
+		f.space = false
+		f.typeDef()
+		f.token(TokenRightBracket)
+	case TokenTypeMap:
+		f.token()
+		f.space = false
+		f.token(TokenLeftSquareBracket)
+		f.typeDef()
+		f.token(TokenRightSquareBracket)
+		f.space = false
+		f.singleTypeDef()
+	case TokenLeftSquareBracket:
+		f.token()
+		if f.peek().Type == TokenNumber {
+			f.token()
+		}
+		f.token(TokenRightSquareBracket)
+		f.space = false
+		f.singleTypeDef()
+	case TokenFunctionDeclaration:
+		f.token()
+		f.signature()
+	case TokenTypeInt8, TokenTypeInt16, TokenTypeInt32, TokenTypeInt64, TokenTypeUint8, TokenTypeUint16, TokenTypeUint32,
+		TokenTypeUint64, TokenTypeFloat32, TokenTypeFloat64, TokenTypeString, TokenTypeBool, TokenTypeAny, TokenIdentifier:
+		f.token()
+	default:
+		f.fail(token)
+	}
+}
+
+// Formats a value along with the operators that follow it
+func (f *formatter) value() {
+	f.operand()
+	for {
+		switch token := f.peek(); token.Type {
+		case TokenPlus, TokenDash, TokenAsterisk, TokenForwardSlash:
+			f.token()
+		case TokenAmpersand, TokenBar:
+			if f.peekAt(1).Type != token.Type {
+				return
+			}
+			f.write(token.Literal+token.Literal, 2, token.Type, token.Type)
+		case TokenEquals, TokenExclamationMark:
+			if f.peekAt(1).Type == TokenEquals {
+				f.write(token.Literal+"=", 2, token.Type, TokenEquals)
+			} else if token.Type == TokenEquals {
+				f.token()
+			} else {
+				return
+			}
+		case TokenGreaterThan, TokenLessThan:
+			if f.peekAt(1).Type == TokenEquals {
+				f.write(token.Literal+"=", 2, token.Type, TokenEquals)
+			} else {
+				f.token()
+			}
+		case TokenIsOperator:
+			f.token()
+			f.typeDef()
+			continue
+		default:
+			return
+		}
+		f.operand()
+	}
+}
+
+// Formats a value without the operators that follow it
+func (f *formatter) operand() {
+	f.lineBreaks()
+	switch token := f.peek(); token.Type {
+	case TokenString:
+		f.write(quote(token.Literal), 1, TokenString)
+	case TokenNumber:
+		if f.peekAt(1).Type == TokenPeriod && f.peekAt(2).Type == TokenNumber {
+			f.write(token.Literal+"."+f.peekAt(2).Literal, 3, TokenNumber, TokenPeriod, TokenNumber)
+		} else {
+			f.token()
+		}
+	case TokenIdentifier:
+		f.token()
+		if f.structs[token.Literal] && f.peek().Type == TokenLeftBrace {
+			f.list(TokenRightBrace, func(multiline bool) {
+				if f.peek().Type == TokenIdentifier && f.peekAt(1).Type == TokenColon {
+					f.token()
+					f.colon(multiline)
+				}
+				f.value()
+			})
+		}
+	case TokenTrue, TokenFalse, TokenTypeInt8, TokenTypeInt16, TokenTypeInt32, TokenTypeInt64, TokenTypeUint8,
+		TokenTypeUint16, TokenTypeUint32, TokenTypeUint64, TokenTypeFloat32, TokenTypeFloat64, TokenTypeString, TokenTypeBool:
+		f.token()
+	case TokenLeftBracket:
+		f.token()
+		f.space = false
+		f.value()
+		f.token(TokenRightBracket)
+	case TokenLeftSquareBracket:
+		f.list(TokenRightSquareBracket, func(bool) {
+			f.value()
+		})
+	case TokenExclamationMark, TokenDash:
+		// The operator applies to the whole value that follows it
+		f.token()
+		f.space = false
+		f.value()
+		return
+	default:
+		f.fail(token)
+	}
+
+	for {
+		switch f.peek().Type {
+		case TokenLeftBracket:
+			f.space = false
+			f.list(TokenRightBracket, func(multiline bool) {
+				if f.peek().Type == TokenIdentifier && f.peekAt(1).Type == TokenColon {
+					f.token()
+					f.colon(false)
+				}
+				f.value()
+			})
+		case TokenLeftSquareBracket:
+			f.space = false
+			f.token()
+			f.space = false
+			f.value()
+			f.token(TokenRightSquareBracket)
+		case TokenPeriod:
+			f.space = false
+			f.token()
+			f.space = false
+			f.token(TokenIdentifier)
+		default:
+			return
+		}
+	}
+}
+
+// Formats a list of items separated by commas, from the opening bracket to the closing bracket. If the source breaks
+// the list over multiple lines each item is put on it's own line with a trailing comma, otherwise the list is kept on
+// one line.
+func (f *formatter) list(closing TokenType, item func(multiline bool)) {
+	if closing != TokenRightSquareBracket {
+		f.space = false
+	}
+	f.token()
+	f.space = false
+	multiline := f.isMultiline()
+	indent, continuation, openingIndent := f.indent, f.continuation, f.lineIndent
+	if multiline {
+		f.indent = f.lineIndent + 1
+		f.continuation = -1
+	}
+
+	for first := true; ; first = false {
+		if multiline {
+			f.skipLines(first, TokenNewLine)
+		}
+		if f.peek().Type == closing {
+			break
+		}
+		if multiline {
+			f.newLine()
+		}
+		item(multiline)
+		if multiline && f.peek().Type == TokenComma {
+			f.write(",", 1)
+		} else if multiline {
+			f.write(",", 0)
+		} else if f.peek().Type == TokenComma {
+			f.skip()
+			if f.peek().Type != closing {
+				f.write(",", 0)
+			}
+		}
+	}
+
+	f.indent, f.continuation = indent, continuation
+	if multiline {
+		f.newLine()
+		f.lineIndent = openingIndent
+	}
+	f.space = false
+	f.token(closing)
+}
+
+// Checks whether there are new lines or comments in the list that starts at the current token, not counting ones in
+// lists nested in it
+func (f *formatter) isMultiline() bool {
+	depth := 0
+	for _, token := range f.tokens[f.pos:] {
+		switch token.Type {
+		case TokenLeftBracket, TokenLeftSquareBracket, TokenLeftBrace:
+			depth++
+		case TokenRightBracket, TokenRightSquareBracket, TokenRightBrace:
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case TokenNewLine, TokenComment:
+			if depth == 0 {
+				return true
+			}
+		case TokenEOF:
+			return false
+		}
+	}
+	return false
+}
+
+// Skips the separators between statements or members, writing the comments between them. A blank line is kept wherever
+// the source has one, unless it is at the start of the block or before the end of it.
+func (f *formatter) skipLines(atStart bool, separators ...TokenType) {
+	newLines := 0
+	for {
+		token := f.peek()
+		switch {
+		case token.Type == TokenNewLine:
+			newLines++
+		case token.Type == TokenComment:
+			if newLines == 0 && !atStart {
+				// The comment is at the end of the line of the statement before it
+				f.trailingComment()
+				continue
+			}
+			f.newLine()
+			if newLines > 1 && !atStart {
+				f.blankLine()
+			}
+			f.line.WriteString(token.Literal)
+			f.newLine()
+			atStart = false
+			newLines = 0
+		case isSeparator(token.Type, separators):
+		default:
+			if newLines > 1 && !atStart && token.Type != TokenRightBrace && token.Type != TokenEOF {
+				f.newLine()
+				f.blankLine()
+			}
+			return
+		}
+		f.pos++
+	}
+}
+
+func isSeparator(tokenType TokenType, separators []TokenType) bool {
+	for _, separator := range separators {
+		if tokenType == separator {
+			return true
+		}
+	}
+	return false
+}
+
+// Skips the new lines that a value can start with, continuing the value on the next line if there are any
+func (f *formatter) lineBreaks() {
+	broken := false
+	for {
+		switch token := f.peek(); token.Type {
+		case TokenNewLine:
+			broken = true
+			f.pos++
+		case TokenComment:
+			f.startContinuation()
+			f.line.WriteString(token.Literal)
+			f.pos++
+		default:
+			if broken {
+				f.startContinuation()
+			}
+			return
+		}
+	}
+}
+
+// Ends the line, so that the next line continues the value on it
+func (f *formatter) startContinuation() {
+	if f.continuation == -1 {
+		f.continuation = f.indent + 1
+	}
+	f.newLine()
+	f.lineIndent = f.continuation
+}
+
+// Writes a colon, which is followed by a space or the marker of where the values of lines are aligned
+func (f *formatter) colon(aligned bool) {
+	f.space = false
+	f.token(TokenColon)
+	if aligned {
+		f.line.WriteString(alignMarker)
+		f.space = false
+	}
+}
+
+// Writes the current token, which must be one of the types if any are passed
+func (f *formatter) token(types ...TokenType) {
+	token := f.peek()
+	if token.Type == TokenString {
+		f.write(quote(token.Literal), 1, types...)
+		return
+	}
+	f.write(token.Literal, 1, types...)
+}
+
+// Writes text in place of a number of tokens, which must be of the types if any are passed. Spaces are put between
+// tokens unless they are brackets or punctuation.
+func (f *formatter) write(text string, tokens int, types ...TokenType) {
+	for i, tokenType := range types {
+		if token := f.peekAt(i); token.Type != tokenType {
+			f.fail(token)
+		}
+	}
+
+	switch text {
+	case ")", "]", ",", ":", ".":
+		f.space = false
+	}
+	if f.line.Len() > 0 && f.space {
+		f.line.WriteString(" ")
+	}
+	f.line.WriteString(text)
+	f.pos += tokens
+	switch text {
+	case "(", "[", ".":
+		f.space = false
+	default:
+		f.space = true
+	}
+	f.trailingComment()
+}
+
+// Reads the comment at the end of the current line if there is one, which is written once the line ends
+func (f *formatter) trailingComment() {
+	if token := f.peek(); token.Type == TokenComment {
+		f.comment = token.Literal
+		f.pos++
+	}
+}
+
+// Skips the current token without writing it
+func (f *formatter) skip() {
+	f.pos++
+	f.trailingComment()
+}
+
+func (f *formatter) peek() Token {
+	return f.peekAt(0)
+}
+
+func (f *formatter) peekAt(offset int) Token {
+	if f.pos+offset >= len(f.tokens) {
+		return f.tokens[len(f.tokens)-1]
+	}
+	return f.tokens[f.pos+offset]
+}
+
+// Ends the current line if anything has been written on it, the next line starts at the current indentation
+func (f *formatter) newLine() {
+	if f.comment != "" {
+		if f.line.Len() > 0 {
+			f.line.WriteString(" ")
+		}
+		f.line.WriteString(f.comment)
+		f.comment = ""
+	}
+	if f.line.Len() > 0 {
+		f.lines = append(f.lines, formattedLine{f.lineIndent, f.line.String()})
+		f.line.Reset()
+	}
+	f.lineIndent = f.indent
+	f.space = false
+}
+
+func (f *formatter) blankLine() {
+	if len(f.lines) > 0 && f.lines[len(f.lines)-1].text != "" {
+		f.lines = append(f.lines, formattedLine{})
+	}
+}
+
+func (f *formatter) fail(token Token) {
+	message := "Unexpected " + token.describe() + "."
+	panic(formatError{&LexerError{Err: errors.New(message), Line: token.Line, Column: token.Column}})
+}
+
+// Joins the lines, aligning the values of consecutive lines that have markers at the same indentation
+func (f *formatter) output() string {
+	var output strings.Builder
+	for start := 0; start < len(f.lines); {
+		end := start + 1
+		width := 0
+		if before, _, ok := strings.Cut(f.lines[start].text, alignMarker); ok {
+			width = len(before)
+			for end < len(f.lines) && f.lines[end].indent == f.lines[start].indent {
+				before, _, ok := strings.Cut(f.lines[end].text, alignMarker)
+				if !ok {
+					break
+				}
+				if len(before) > width {
+					width = len(before)
+				}
+				end++
+			}
+		}
+		for _, line := range f.lines[start:end] {
+			if line.text != "" {
+				output.WriteString(strings.Repeat("\t", line.indent))
+			}
+			if before, after, ok := strings.Cut(line.text, alignMarker); ok {
+				line.text = before + strings.Repeat(" ", width-len(before)+1) + after
+			}
+			output.WriteString(line.text)
+			output.WriteString("\n")
+		}
+		start = end
+	}
+	return output.String()
+}
+
+// Writes a string as a literal in the source code, with the characters the lexer reads from escape sequences escaped
+func quote(value string) string {
+	replacer := strings.NewReplacer("\\", "\\\\", "\n", "\\n", "\t", "\\t", "\r", "\\r")
+	return "\"" + replacer.Replace(value) + "\""
+}
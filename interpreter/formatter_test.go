@@ -0,0 +1,94 @@
+package interpreter_test
+
+import (
+	"flag"
+	"main/interpreter"
+	standardlibrary "main/standard_library"
+	keyvalue "main/standard_library/key_value.go"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Rewrites the golden files with the current output of the formatter, run with go test ./interpreter -update
+var update = flag.Bool("update", false, "If passed the golden files are updated instead of being compared")
+
+// Formats each program in testdata/format, comparing the result with it's golden file. The formatted programs must be
+// formatted the same way again, and must still parse.
+func TestFormatGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "format", "*.lang"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("No programs to format in testdata/format")
+	}
+
+	globalDefs, _ := interpreter.BindValues(standardlibrary.Globals)
+	keyValueDefs, _ := interpreter.BindValues(keyvalue.Module)
+	moduleDefs := map[string]map[string]interpreter.TypeDef{"key_value": keyValueDefs}
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".lang"), func(t *testing.T) {
+			source, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			formatted, err := interpreter.Format(string(source))
+			if err != nil {
+				t.Fatal("Formatting failed:", err)
+			}
+
+			goldenPath := strings.TrimSuffix(path, ".lang") + ".golden"
+			if *update {
+				if err := os.WriteFile(goldenPath, []byte(formatted), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			golden, err := os.ReadFile(goldenPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if formatted != string(golden) {
+				t.Errorf("Formatted program doesn't match %s:\n%s", goldenPath, formatted)
+			}
+
+			again, err := interpreter.Format(formatted)
+			if err != nil {
+				t.Fatal("Formatting the formatted program failed:", err)
+			}
+			if again != formatted {
+				t.Errorf("Formatting isn't idempotent, formatting again gives:\n%s", again)
+			}
+
+			_, diagnostics := interpreter.NewParser(formatted, path, globalDefs, moduleDefs).Parse()
+			for _, diagnostic := range diagnostics {
+				t.Error("Formatted program doesn't parse:", diagnostic)
+			}
+		})
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	for _, test := range []struct {
+		source string
+		line   int
+		column int
+	}{
+		{"var x = (1\n", 1, 11},
+		{"fn f() {\n\tprint(1)\n", 3, 1},
+		{"struct P { name string }\n", 1, 17},
+		{"print(\"unterminated)\n", 1, 7},
+	} {
+		_, err := interpreter.Format(test.source)
+		lexerErr, ok := err.(*interpreter.LexerError)
+		if !ok {
+			t.Errorf("Expected an error formatting %q, got %v", test.source, err)
+			continue
+		}
+		if lexerErr.Line != test.line || lexerErr.Column != test.column {
+			t.Errorf("Expected the error formatting %q at %d:%d, got %d:%d", test.source, test.line, test.column, lexerErr.Line, lexerErr.Column)
+		}
+	}
+}
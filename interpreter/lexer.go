@@ -2,8 +2,8 @@ package interpreter
 
 import (
 	"errors"
-	"fmt"
-	"os"
+	"main/interpreter/environment"
+	"strings"
 )
 
 type TokenType uint8
@@ -22,6 +22,11 @@ const (
 	TokenAsStatement
 	TokenRangeStatement
 	TokenWhileStatement
+	TokenTypeDeclaration
+	TokenMatchStatement
+	TokenIsOperator
+	TokenLetDeclaration
+	TokenReadonly
 
 	// Values
 	TokenTrue
@@ -44,6 +49,7 @@ const (
 	TokenTypeString
 	TokenTypeBool
 	TokenTypeMap
+	TokenTypeAny
 
 	// Symbols
 	TokenColon
@@ -69,6 +75,9 @@ const (
 	TokenLessThan
 	TokenPeriod
 
+	// A comment from // to the end of the line, only read by lexers that keep comments
+	TokenComment
+
 	TokenEOF
 )
 
@@ -76,19 +85,65 @@ type Token struct {
 	Type    TokenType
 	Literal string
 	Line    int
+	// The column the token starts at, starting from 1
+	Column int
+	// The column after the last character of the token
+	EndColumn int
+}
+
+// Gets the span of the source code the token was read from
+func (t Token) Position() environment.Position {
+	return environment.Position{Line: t.Line, Column: t.Column, EndLine: t.Line, EndColumn: t.EndColumn}
+}
+
+// Describes the token for error messages, tokens without text that can be shown are described by what they end
+func (t Token) describe() string {
+	switch t.Type {
+	case TokenNewLine:
+		return "end of line"
+	case TokenEOF:
+		return "end of file"
+	}
+	return "token \"" + t.Literal + "\""
 }
 
 type Lexer struct {
 	content     string
 	cursor      int
 	currentLine int
+	// The last token that was read, used for the position of errors, and the token before it which is restored when
+	// the last token is unread
+	lastToken     Token
+	previousToken Token
+	// Whether comments are read as tokens instead of being skipped, used by the formatter
+	keepComments bool
+}
+
+// An error reading a token, such as a string literal that is never closed
+type LexerError struct {
+	Err    error
+	Line   int
+	Column int
+}
+
+func (e *LexerError) Error() string {
+	return e.Err.Error()
 }
 
 func NewLexer(content string) *Lexer {
-	return &Lexer{content, 0, 1}
+	return &Lexer{content: content, currentLine: 1}
 }
 
 func (l *Lexer) Next() (Token, error) {
+	token, err := l.next()
+	if err == nil {
+		l.previousToken = l.lastToken
+		l.lastToken = token
+	}
+	return token, err
+}
+
+func (l *Lexer) next() (Token, error) {
 	currentStr := ""
 	for l.cursor < len(l.content) {
 		// Need to use a substring instead of an index to get a value of type string
@@ -99,28 +154,56 @@ func (l *Lexer) Next() (Token, error) {
 			continue
 		}
 
+		if char == "/" && strings.HasPrefix(l.content[l.cursor:], "/") {
+			start := l.cursor - 1
+			end := strings.IndexByte(l.content[start:], '\n')
+			if end == -1 {
+				end = len(l.content)
+			} else {
+				end += start
+			}
+			// The new line after the comment is still read as a token since it ends the statement before it
+			l.cursor = end
+			if !l.keepComments {
+				continue
+			}
+			return Token{
+				Type:      TokenComment,
+				Literal:   strings.TrimRight(l.content[start:end], " \t\r"),
+				Line:      l.currentLine,
+				Column:    l.columnAt(start),
+				EndColumn: l.columnAt(end),
+			}, nil
+		}
+
 		// Check character is a valid token
 		if token, err := getCharTokenType(char); err == nil {
+			line := l.currentLine
 			if token == TokenNewLine {
 				l.currentLine++
 			}
 			return Token{
-				Type:    token,
-				Literal: char,
-				Line:    l.currentLine,
+				Type:      token,
+				Literal:   char,
+				Line:      line,
+				Column:    l.columnAt(l.cursor - 1),
+				EndColumn: l.columnAt(l.cursor-1) + 1,
 			}, nil
 		}
 
 		// If the character is a quotation mark, it's the beginning of a string
 		if char == "\"" {
+			start := l.cursor - 1
 			strContent, err := l.readString()
 			if err != nil {
-				return Token{}, err
+				return Token{}, &LexerError{Err: err, Line: l.currentLine, Column: l.columnAt(start)}
 			}
 			return Token{
-				Type:    TokenString,
-				Literal: strContent,
-				Line:    l.currentLine,
+				Type:      TokenString,
+				Literal:   strContent,
+				Line:      l.currentLine,
+				Column:    l.columnAt(start),
+				EndColumn: l.columnAt(l.cursor),
 			}, nil
 		}
 
@@ -140,48 +223,69 @@ func (l *Lexer) Next() (Token, error) {
 
 		if endOfToken {
 			return Token{
-				Type:    getLiteralTokenType(currentStr),
-				Literal: currentStr,
-				Line:    l.currentLine,
+				Type:      getLiteralTokenType(currentStr),
+				Literal:   currentStr,
+				Line:      l.currentLine,
+				Column:    l.columnAt(l.cursor - len(currentStr)),
+				EndColumn: l.columnAt(l.cursor),
 			}, nil
 		}
 	}
 
 	return Token{
-		Type:    TokenEOF,
-		Literal: "EOF",
-		Line:    l.currentLine,
+		Type:      TokenEOF,
+		Literal:   "EOF",
+		Line:      l.currentLine,
+		Column:    l.columnAt(l.cursor),
+		EndColumn: l.columnAt(l.cursor),
 	}, nil
 }
 
+// Gets the column of a position in the content, starting from 1
+func (l *Lexer) columnAt(pos int) int {
+	return pos - strings.LastIndexByte(l.content[:pos], '\n')
+}
+
 // Returns the contents of the next token without progressing the cursor
 func (l *Lexer) Peek() (Token, error) {
 	originalPos := l.cursor
 	originalLine := l.currentLine
-	token, err := l.Next()
+	token, err := l.next()
 	l.cursor = originalPos
 	l.currentLine = originalLine
 	return token, err
 }
 
-func (l *Lexer) PeekOrExit() Token {
+// Peeks the next token, panicking with a *LexerError if it can't be read
+func (l *Lexer) MustPeek() Token {
 	token, err := l.Peek()
 	if err != nil {
-		fmt.Println(err, fmt.Sprint(l.currentLine)+":"+fmt.Sprint(l.cursor))
-		os.Exit(1)
+		panic(l.newError(err))
 	}
 	return token
 }
 
-func (l *Lexer) NextOrExit() Token {
+// Reads the next token, panicking with a *LexerError if it can't be read
+func (l *Lexer) MustNext() Token {
 	token, err := l.Next()
 	if err != nil {
-		fmt.Println(err, fmt.Sprint(l.currentLine)+":"+fmt.Sprint(l.cursor))
-		os.Exit(1)
+		panic(l.newError(err))
 	}
 	return token
 }
 
+func (l *Lexer) newError(err error) *LexerError {
+	if lexerErr, ok := err.(*LexerError); ok {
+		return lexerErr
+	}
+	return &LexerError{Err: err, Line: l.currentLine, Column: l.columnAt(l.cursor)}
+}
+
+// Gets the last token that was read
+func (l *Lexer) LastToken() Token {
+	return l.lastToken
+}
+
 func (l *Lexer) readString() (string, error) {
 	currentStr := ""
 	escapedChar := false
@@ -214,6 +318,8 @@ func (l *Lexer) readString() (string, error) {
 			currentStr += char
 		}
 		if char == "\n" {
+			// The newline is left to be read as the next token so the line count stays correct
+			l.cursor--
 			return "", errors.New("unexpected newline while reading string literal")
 		}
 	}
@@ -240,6 +346,7 @@ func (l *Lexer) SetCursor(cursor int) {
 // Moves the cursor back to the start of the previously read token so it will be read at the next call of Next().
 // Only the last read token should be passed to Unread.
 func (l *Lexer) Unread(token Token) {
+	l.lastToken = l.previousToken
 	if token.Type == TokenEOF {
 		return
 	}
@@ -267,7 +374,7 @@ func (pos LexerPos) GoTo() (undo func()) {
 	originalCursor := pos.lexer.GetCursor()
 
 	pos.lexer.SetCurrentLine(pos.Line)
-	pos.lexer.SetCurrentLine(pos.Cursor)
+	pos.lexer.SetCursor(pos.Cursor)
 
 	return func() {
 		pos.lexer.SetCurrentLine(originalLine)
@@ -85,6 +85,16 @@ func getLiteralTokenType(literal string) TokenType {
 		return TokenWhileStatement
 	case "range":
 		return TokenRangeStatement
+	case "type":
+		return TokenTypeDeclaration
+	case "match":
+		return TokenMatchStatement
+	case "is":
+		return TokenIsOperator
+	case "let":
+		return TokenLetDeclaration
+	case "readonly":
+		return TokenReadonly
 
 	// Types
 	case "int8":
@@ -111,6 +121,8 @@ func getLiteralTokenType(literal string) TokenType {
 		return TokenTypeString
 	case "bool":
 		return TokenTypeBool
+	case "any":
+		return TokenTypeAny
 	}
 
 	for _, char := range literal {
@@ -0,0 +1,110 @@
+package interpreter
+
+// The linter reports code that is valid but is likely to be a mistake, such as variables that are never used. It runs
+// alongside the parser, which records where identifiers are declared and used as it type checks the program.
+//
+// Each rule is reported with it's diagnostic code, and can be turned off for a line with a comment listing the codes:
+//
+//	var unused = 1 // lint:ignore unused-variable
+//
+// A comment on a line of it's own turns the rules off for the line after it instead.
+
+import (
+	"main/interpreter/environment"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The prefix of comments that turn rules off
+const lintIgnoreComment = "lint:ignore"
+
+// Parses the program with the lint rules, returning the diagnostics of the parser along with the warnings of the
+// rules. The unused rules are only checked if the program has no errors, since identifiers can be used in statements
+// that couldn't be parsed.
+func (p *Parser) Lint() []Diagnostic {
+	p.linting = true
+	analysis, diagnostics := p.Analyse()
+
+	if !HasErrors(diagnostics) {
+		for _, declaration := range analysis.Declarations {
+			if declaration.used {
+				continue
+			}
+			if declaration.Kind == DeclarationVariable {
+				diagnostics = append(diagnostics, lintWarning(declaration.Position, DiagnosticUnusedVariable,
+					strconv.Quote(declaration.Name)+" is declared but never used."))
+			} else if declaration.Kind == DeclarationImport {
+				diagnostics = append(diagnostics, lintWarning(declaration.Position, DiagnosticUnusedImport,
+					strconv.Quote(declaration.Name)+" is imported but never used."))
+			}
+		}
+	}
+
+	ignored := ignoredRules(p.lexer.content)
+	linted := make([]Diagnostic, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity != SeverityWarning || !ignored[diagnostic.Line][diagnostic.Code] {
+			linted = append(linted, diagnostic)
+		}
+	}
+	sort.SliceStable(linted, func(i, j int) bool {
+		if linted[i].Line != linted[j].Line {
+			return linted[i].Line < linted[j].Line
+		}
+		return linted[i].Column < linted[j].Column
+	})
+	return linted
+}
+
+// Gets the rules turned off on each line by comments
+func ignoredRules(source string) map[int]map[DiagnosticCode]bool {
+	ignored := make(map[int]map[DiagnosticCode]bool)
+	lexer := NewLexer(source)
+	lexer.keepComments = true
+	ownLine := true
+	for {
+		token, err := lexer.Next()
+		// Comments after a token that can't be read are ignored, the error is reported by the parser
+		if err != nil || token.Type == TokenEOF {
+			return ignored
+		}
+		if token.Type == TokenComment {
+			text := strings.TrimSpace(strings.TrimPrefix(token.Literal, "//"))
+			if rules, ok := strings.CutPrefix(text, lintIgnoreComment); ok {
+				line := token.Line
+				if ownLine {
+					line++
+				}
+				if ignored[line] == nil {
+					ignored[line] = make(map[DiagnosticCode]bool)
+				}
+				for _, rule := range strings.FieldsFunc(rules, func(r rune) bool { return r == ' ' || r == ',' }) {
+					ignored[line][DiagnosticCode(rule)] = true
+				}
+			}
+		}
+		ownLine = token.Type == TokenNewLine
+	}
+}
+
+// Reports a variable that has just been declared if it has the same name as an identifier of an outer scope
+func (p *Parser) checkShadowing(name string, position environment.Position) {
+	parent := p.currentTypeEnv.GetParent()
+	if parent == nil {
+		return
+	}
+	if def, _ := parent.Get(name); def == nil {
+		return
+	}
+	message := strconv.Quote(name) + " shadows a declaration of an outer scope."
+	if outer := p.analysis.find(parent, name); outer != nil {
+		message = strconv.Quote(name) + " shadows the declaration at line " + strconv.Itoa(outer.Position.Line) + "."
+	}
+	p.addDiagnostic(lintWarning(position, DiagnosticShadowedVariable, message))
+}
+
+// Creates the warning of a lint rule
+func lintWarning(position environment.Position, code DiagnosticCode, message string) Diagnostic {
+	return Diagnostic{Position: position, Code: code, Severity: SeverityWarning, Message: message}
+}
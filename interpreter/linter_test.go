@@ -0,0 +1,77 @@
+package interpreter_test
+
+import (
+	"main/interpreter"
+	standardlibrary "main/standard_library"
+	keyvalue "main/standard_library/key_value.go"
+	"testing"
+)
+
+// Lints a small program for each rule, comparing the diagnostics with the ones expected in the order they are reported
+func TestLint(t *testing.T) {
+	globalDefs, _ := interpreter.BindValues(standardlibrary.Globals)
+	keyValueDefs, _ := interpreter.BindValues(keyvalue.Module)
+	moduleDefs := map[string]map[string]interpreter.TypeDef{"key_value": keyValueDefs}
+
+	for _, test := range []struct {
+		name        string
+		source      string
+		diagnostics []string
+	}{
+		{
+			name:        "unused variable",
+			source:      "var x = 1\n",
+			diagnostics: []string{`1:5: warning: "x" is declared but never used. [unused-variable]`},
+		},
+		{
+			name:   "used variable",
+			source: "var x = 1\nprint(x)\n",
+		},
+		{
+			name:        "unused import",
+			source:      "import \"key_value\"\n",
+			diagnostics: []string{`1:8: warning: "key_value" is imported but never used. [unused-import]`},
+		},
+		{
+			name:        "shadowed variable",
+			source:      "var x = 1\nfn f() {\n\tvar x = 2\n\tprint(x)\n}\nprint(x)\nf()\n",
+			diagnostics: []string{`3:6: warning: "x" shadows the declaration at line 1. [shadowed-variable]`},
+		},
+		{
+			name:        "unreachable code",
+			source:      "fn f(): int64 {\n\treturn 1\n\tprint(2)\n}\nprint(f())\n",
+			diagnostics: []string{`3:2: warning: The code after the return statement is never run. [unreachable-code]`},
+		},
+		{
+			name:   "ignored on the same line",
+			source: "var x = 1 // lint:ignore unused-variable\n",
+		},
+		{
+			name:   "ignored on the line before",
+			source: "// lint:ignore unused-variable, unused-import\nvar x = 1\n",
+		},
+		{
+			name:        "errors can't be ignored",
+			source:      "var x int64 = \"a\" // lint:ignore type-error\n",
+			diagnostics: []string{`1:15: error: Incorrect type of value on right hand side of variable declaration. [type-error]`},
+		},
+		{
+			// Identifiers can be used by the statements that couldn't be parsed
+			name:        "unused rules skipped with errors",
+			source:      "var x = 1\nvar y int64 = \"a\"\n",
+			diagnostics: []string{`2:15: error: Incorrect type of value on right hand side of variable declaration. [type-error]`},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			diagnostics := interpreter.NewParser(test.source, "test.lang", globalDefs, moduleDefs).Lint()
+			if len(diagnostics) != len(test.diagnostics) {
+				t.Fatalf("Expected %d diagnostics, got %v", len(test.diagnostics), diagnostics)
+			}
+			for i, diagnostic := range diagnostics {
+				if diagnostic.String() != test.diagnostics[i] {
+					t.Errorf("Expected %s, got %s", test.diagnostics[i], diagnostic)
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import "main/interpreter/environment"
 
 // Node that returns true if LeftSide and RightSide are both true
 type And struct {
+	environment.Position
 	LeftSide  environment.Node
 	RightSide environment.Node
 }
@@ -6,6 +6,7 @@ import (
 
 // Node that assigns a value to an array index
 type ArrayAssignment[Element any] struct {
+	environment.Position
 	ArrayIndex *ArrayIndex[Element]
 	Value      environment.Node
 }
@@ -20,3 +21,12 @@ func (n *ArrayAssignment[E]) Eval(env *environment.Environment) any {
 func (n *ArrayAssignment[E]) References() []string {
 	return append(n.ArrayIndex.References(), n.Value.References()...)
 }
+
+// Gets the operations for the arrays used by the node
+func (n *ArrayAssignment[E]) ArrayOperations() ArrayOperations {
+	return newArrayOperations[E]()
+}
+
+func (n *ArrayAssignment[E]) AssignmentDetails() (arrayIndex ArrayIndexNode, value environment.Node) {
+	return n.ArrayIndex, n.Value
+}
@@ -7,6 +7,7 @@ import (
 
 // Node that accesses a value at an array index
 type ArrayIndex[Element any] struct {
+	environment.Position
 	Array environment.Node
 	Index environment.Node
 }
@@ -23,8 +24,8 @@ func (n *ArrayIndex[E]) References() []string {
 func (n *ArrayIndex[E]) GetArrayAndValidatedIndex(env *environment.Environment) ([]E, uint64) {
 	index := n.GetIndexVal(env)
 	array := n.Array.Eval(env).([]E)
-	if index > uint64(len(array)) {
-		env.Panic("Index out of array bounds")
+	if index >= uint64(len(array)) {
+		env.PanicAt(n.Position, "Index out of array bounds")
 	}
 	return array, index
 }
@@ -37,10 +38,19 @@ func (n *ArrayIndex[T]) GetIndexVal(env *environment.Environment) uint64 {
 	if indexKind == reflect.Int || indexKind == reflect.Int16 || indexKind == reflect.Int32 || indexKind == reflect.Int64 {
 		index := indexVal.Int()
 		if index < 0 {
-			env.Panic("Array index cannot be less than 0")
+			env.PanicAt(n.Position, "Array index cannot be less than 0")
 		}
 		return uint64(index)
 	} else {
 		return indexVal.Uint()
 	}
 }
+
+// Gets the operations for the arrays used by the node
+func (n *ArrayIndex[E]) ArrayOperations() ArrayOperations {
+	return newArrayOperations[E]()
+}
+
+func (n *ArrayIndex[E]) IndexDetails() (array environment.Node, index environment.Node) {
+	return n.Array, n.Index
+}
@@ -6,10 +6,12 @@ import (
 
 // Node that initializes a new array
 type ArrayInitialization[T any] struct {
+	environment.Position
 	Elements []environment.Node
 }
 
 func (n *ArrayInitialization[T]) Eval(env *environment.Environment) any {
+	env.AllocateArray(len(n.Elements))
 	array := make([]T, len(n.Elements))
 	for pos, el := range n.Elements {
 		if el == nil {
@@ -23,7 +25,18 @@ func (n *ArrayInitialization[T]) Eval(env *environment.Environment) any {
 func (n *ArrayInitialization[T]) References() []string {
 	refs := make([]string, 0)
 	for _, el := range n.Elements {
-		refs = append(refs, el.References()...)
+		if el != nil {
+			refs = append(refs, el.References()...)
+		}
 	}
 	return refs
 }
+
+// Gets the operations for the arrays used by the node
+func (n *ArrayInitialization[T]) ArrayOperations() ArrayOperations {
+	return newArrayOperations[T]()
+}
+
+func (n *ArrayInitialization[T]) ElementNodes() []environment.Node {
+	return n.Elements
+}
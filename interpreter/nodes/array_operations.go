@@ -0,0 +1,58 @@
+package nodes
+
+import "main/interpreter/environment"
+
+// Operations on the arrays used by a generic node that can be performed without knowing the element type,
+// this lets the bytecode compiler handle array nodes without a case for every possible element type
+type ArrayOperations struct {
+	Make func(length int) any
+	Len  func(array any) int
+	Get  func(array any, index int) any
+	Set  func(array any, index int, value any)
+}
+
+// The generic array nodes implement these interfaces for any type of element, so that their fields can be read by a type
+// switch without a case for every possible element type
+
+type ArrayInitializationNode interface {
+	ArrayOperations() ArrayOperations
+	ElementNodes() []environment.Node
+}
+
+type ArrayIndexNode interface {
+	environment.Positioned
+	ArrayOperations() ArrayOperations
+	IndexDetails() (array environment.Node, index environment.Node)
+}
+
+type ArrayAssignmentNode interface {
+	ArrayOperations() ArrayOperations
+	AssignmentDetails() (arrayIndex ArrayIndexNode, value environment.Node)
+}
+
+type LoopArrayNode interface {
+	ArrayOperations() ArrayOperations
+	LoopDetails() (valIdentifier string, indexIdentifier string, array environment.Node, inner *Block)
+}
+
+type DestructureNode interface {
+	ArrayOperations() ArrayOperations
+	DestructureDetails() (identifiers []string, indexes []int, value environment.Node)
+}
+
+func newArrayOperations[E any]() ArrayOperations {
+	return ArrayOperations{
+		Make: func(length int) any {
+			return make([]E, length)
+		},
+		Len: func(array any) int {
+			return len(array.([]E))
+		},
+		Get: func(array any, index int) any {
+			return array.([]E)[index]
+		},
+		Set: func(array any, index int, value any) {
+			array.([]E)[index] = value.(E)
+		},
+	}
+}
@@ -6,14 +6,16 @@ import (
 
 // Node that assigns a value to an identifier in the current environment or in the parent environment with a certain depth
 type Assignment struct {
+	environment.Position
 	Identifier string
+	Slot       int
 	NewValue   environment.Node
 	Depth      int
 }
 
 func (a *Assignment) Eval(env *environment.Environment) any {
 	newVal := a.NewValue.Eval(env)
-	env.SetWithDepth(a.Identifier, newVal, a.Depth)
+	env.SetWithDepth(a.Identifier, a.Slot, newVal, a.Depth)
 	return newVal
 }
 
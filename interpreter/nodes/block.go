@@ -4,11 +4,17 @@ import "main/interpreter/environment"
 
 // Node that represents a nested code block within the program such as a function body
 type Block struct {
+	environment.Position
 	Nodes []environment.Node
+	// Computed the first time the block is executed
+	liveness *environment.Liveness
 }
 
 func (n *Block) Eval(env *environment.Environment) any {
-	env.Execute(n.Nodes)
+	if n.liveness == nil {
+		n.liveness = environment.NewLiveness(n.Nodes)
+	}
+	env.Execute(n.Nodes, n.liveness)
 	return nil
 }
 
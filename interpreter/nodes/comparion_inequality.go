@@ -14,6 +14,7 @@ const (
 
 // Node that represents a numerical inequality comparison (also supports == on numbers)
 type InequalityComparison[T int8 | int16 | int32 | int64 | uint8 | uint16 | uint32 | uint64 | float32 | float64] struct {
+	environment.Position
 	Type      ComparisonType
 	LeftSide  environment.Node
 	RightSide environment.Node
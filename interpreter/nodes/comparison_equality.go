@@ -4,6 +4,7 @@ import "main/interpreter/environment"
 
 // Node that checks that two values are equal
 type EqualityComparison struct {
+	environment.Position
 	LeftSide  environment.Node
 	RightSide environment.Node
 }
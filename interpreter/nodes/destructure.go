@@ -0,0 +1,33 @@
+package nodes
+
+import "main/interpreter/environment"
+
+// Node that declares an identifier for each of the elements of an array (or properties of a struct instance) at the indexes
+type Destructure[Element any] struct {
+	environment.Position
+	Identifiers []string
+	Slots       []int
+	Indexes     []int
+	Value       environment.Node
+}
+
+func (n *Destructure[E]) Eval(env *environment.Environment) any {
+	elements := n.Value.Eval(env).([]E)
+	for i, identifier := range n.Identifiers {
+		env.Set(identifier, n.Slots[i], elements[n.Indexes[i]])
+	}
+	return nil
+}
+
+func (n *Destructure[E]) References() []string {
+	return append(n.Value.References(), n.Identifiers...)
+}
+
+// Gets the operations for the arrays used by the node
+func (n *Destructure[E]) ArrayOperations() ArrayOperations {
+	return newArrayOperations[E]()
+}
+
+func (n *Destructure[E]) DestructureDetails() (identifiers []string, indexes []int, value environment.Node) {
+	return n.Identifiers, n.Indexes, n.Value
+}
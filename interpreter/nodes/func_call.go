@@ -2,32 +2,46 @@ package nodes
 
 import (
 	"main/interpreter/environment"
-	"reflect"
 )
 
 // Node that calls a function with arguments
 type FuncCall struct {
+	environment.Position
+	// Arguments passed to the function, nil arguments were omitted and will use their default value
 	Args     []environment.Node
 	Function environment.Node
 }
 
 func (n *FuncCall) Eval(env *environment.Environment) any {
-	funcVal := reflect.ValueOf(n.Function.Eval(env))
-	args := make([]reflect.Value, len(n.Args))
-	for i, arg := range n.Args {
-		args[i] = reflect.ValueOf(arg.Eval(env))
+	function, args := n.evalCall(env)
+	if _, ok := function.(environment.NativeFunc); ok {
+		// Go functions panic without the position of the call
+		defer env.RecoverAt(n.Position)
 	}
-	out := funcVal.Call(args)
-	if len(out) > 0 {
-		return out[0].Interface()
+	return function.Call(env, args)
+}
+
+// Evaluates the function and the arguments of the call, ready for the function to be called from the environment
+func (n *FuncCall) evalCall(env *environment.Environment) (environment.Callable, []any) {
+	function := n.Function.Eval(env).(environment.Callable)
+	args := make([]any, len(n.Args))
+	for i, arg := range n.Args {
+		if arg == nil {
+			args[i] = DefaultArg{}
+			continue
+		}
+		args[i] = arg.Eval(env)
 	}
-	return nil
+	env.SetCallSite(n.Position)
+	return function, args
 }
 
 func (n *FuncCall) References() []string {
 	refs := n.Function.References()
 	for _, arg := range n.Args {
-		refs = append(refs, arg.References()...)
+		if arg != nil {
+			refs = append(refs, arg.References()...)
+		}
 	}
 	return refs
 }
@@ -6,42 +6,132 @@ import (
 
 // Node that declares a function in the current environment
 type FuncDeclaration struct {
-	Name     string
-	Line     int
+	environment.Position
+	Name string
+	// The slot the function is declared in, unless it is anonymous or a method
+	Slot int
+	// Methods are stored by the struct or type they belong to instead of being declared in the environment
+	IsMethod bool
 	Inner    *Block
+	// Names of the arguments, which are declared in the first slots of the function's environment
 	ArgNames []string
+	// Nodes for the default values of arguments, with nil for arguments that are required.
+	// They are evaluated in the environment the function was declared in.
+	Defaults []environment.Node
+	// Set if the function is variadic, converts the trailing arguments in to the array passed as the last argument
+	PackVariadic VariadicPacker
+	// The identifiers referenced by the function, computed the first time it is declared
+	refs []string
 }
 
-func (n *FuncDeclaration) Eval(env *environment.Environment) any {
-	fn := func(args ...any) any {
-		innerEnv := env.NewChild(environment.Call{
-			Name: n.Name + "()",
-			File: env.Call.File,
-			Line: n.Line,
-		})
-
-		for i, arg := range args {
-			innerEnv.Set(n.ArgNames[i], arg)
+// Packs the trailing arguments passed to a variadic function in to the array of it's last argument
+type VariadicPacker interface {
+	PackArray(values []any) any
+}
+
+// Passed in place of an argument that was omitted by the caller so that the default value is used instead
+type DefaultArg struct{}
+
+// A function declared by a script, along with the environment it was declared in
+type Function struct {
+	declaration *FuncDeclaration
+	env         *environment.Environment
+}
+
+// A call in tail position that is returned in place of the function's result, so that the function returning it can make
+// the call without nesting it's Go stack frame
+type tailCall struct {
+	function environment.Callable
+	args     []any
+	// The environment the call was made from, which only Go functions are called from since calls to functions
+	// declared by scripts replace it
+	caller *environment.Environment
+	// The position of the call, for the errors of Go functions
+	position environment.Position
+}
+
+// Calls the function, along with the functions it calls in tail position. Those calls replace the call of the function
+// in the call stack, so tail recursion runs in constant stack space.
+func (f *Function) Call(caller *environment.Environment, args []any) any {
+	for {
+		result := f.call(caller, args)
+		tail, ok := result.(*tailCall)
+		if !ok {
+			return result
+		}
+		function := tail.function
+		args = tail.args
+		if method, ok := function.(*boundMethod); ok {
+			function = method.method
+			args = append([]any{method.self}, args...)
 		}
+		if f, ok = function.(*Function); !ok {
+			return tail.callOther(function, args)
+		}
+	}
+}
 
-		var returnVal any
-		innerEnv.SetReturnCallback(func(v any) {
-			returnVal = v
-		})
+// Calls a function that isn't declared by a script in place of the function that returned the call
+func (t *tailCall) callOther(function environment.Callable, args []any) any {
+	if _, ok := function.(environment.NativeFunc); ok {
+		defer t.caller.RecoverAt(t.position)
+	}
+	return function.Call(t.caller, args)
+}
 
-		n.Inner.Eval(innerEnv)
+func (f *Function) call(caller *environment.Environment, args []any) any {
+	n := f.declaration
+	env := f.env
+	innerEnv := env.NewCall(n.Name+"()", caller)
 
-		env.GetCurrentExecutionEnv().ProfileFunctionCall(innerEnv.GetProfileResult())
-		return returnVal
+	fixedArgs := len(n.ArgNames)
+	if n.PackVariadic != nil {
+		fixedArgs--
+		innerEnv.AllocateArray(len(args) - fixedArgs)
+		innerEnv.Set(n.ArgNames[fixedArgs], fixedArgs, n.PackVariadic.PackArray(args[fixedArgs:]))
 	}
+	for i := 0; i < fixedArgs; i++ {
+		if _, ok := args[i].(DefaultArg); ok {
+			innerEnv.Set(n.ArgNames[i], i, n.Defaults[i].Eval(env))
+		} else {
+			innerEnv.Set(n.ArgNames[i], i, args[i])
+		}
+	}
+
+	var returnVal any
+	innerEnv.SetReturnCallback(func(v any) {
+		returnVal = v
+	})
+
+	n.Inner.Eval(innerEnv)
+
+	env.GetCurrentExecutionEnv().ProfileFunctionCall(innerEnv.GetProfileResult())
+	return returnVal
+}
+
+func (f *Function) String() string {
+	return "fn " + f.declaration.Name + "()"
+}
+
+func (n *FuncDeclaration) Eval(env *environment.Environment) any {
+	fn := &Function{declaration: n, env: env}
+	if n.refs == nil {
+		n.refs = n.References()
+	}
+	env.Capture(n.refs)
 	// Check that the function is not an anonymous functions without a name
-	if n.Name != "" {
-		env.Set(n.Name, fn)
-		env.AttachReferences(n.Name, n.Inner.References())
+	if n.Name != "" && !n.IsMethod {
+		env.Set(n.Name, n.Slot, fn)
 	}
 	return fn
 }
 
 func (n *FuncDeclaration) References() []string {
-	return n.Inner.References()
+	refs := n.Inner.References()
+	for _, defaultVal := range n.Defaults {
+		if defaultVal != nil {
+			refs = append(refs, defaultVal.References()...)
+		}
+	}
+	return refs
 }
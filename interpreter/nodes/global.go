@@ -0,0 +1,17 @@
+package nodes
+
+import "main/interpreter/environment"
+
+// Node that gets a value passed to the interpreter as a global, such as print
+type Global struct {
+	environment.Position
+	Name string
+}
+
+func (n *Global) Eval(env *environment.Environment) any {
+	return env.GetGlobal(n.Name)
+}
+
+func (n *Global) References() []string {
+	return []string{}
+}
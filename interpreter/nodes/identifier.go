@@ -4,13 +4,16 @@ import (
 	"main/interpreter/environment"
 )
 
-// Node that gets a value by it's identifier
+// Node that gets a value by it's identifier, which the parser resolves to a slot of the environment at a depth
 type Identifier struct {
-	Name string
+	environment.Position
+	Name  string
+	Slot  int
+	Depth int
 }
 
 func (i *Identifier) Eval(env *environment.Environment) any {
-	return env.Get(i.Name)
+	return env.Get(i.Slot, i.Depth)
 }
 
 func (i *Identifier) References() []string {
@@ -4,6 +4,7 @@ import "main/interpreter/environment"
 
 // Node that runs an inner block if Condition is true, if Else is set that will be run if Condition is not true
 type IfStatement struct {
+	environment.Position
 	Condition environment.Node
 	Inner     *Block
 	Else      environment.Node
@@ -13,6 +14,9 @@ func (n *IfStatement) Eval(env *environment.Environment) any {
 	if n.Condition.Eval(env).(bool) {
 		childEnv := env.NewChild(environment.Call{})
 		n.Inner.Eval(childEnv)
+	} else if block, ok := n.Else.(*Block); ok {
+		// An else block has it's own scope in the same way as the if block
+		block.Eval(env.NewChild(environment.Call{}))
 	} else if n.Else != nil {
 		n.Else.Eval(env)
 	}
@@ -4,12 +4,14 @@ import "main/interpreter/environment"
 
 // Node that imports a module in to the current environment
 type Import struct {
+	environment.Position
 	Module     string
 	Identifier string
+	Slot       int
 }
 
 func (n *Import) Eval(env *environment.Environment) any {
-	env.Set(n.Identifier, env.GetBuiltInModule(n.Module))
+	env.Set(n.Identifier, n.Slot, env.GetBuiltInModule(n.Module))
 	return nil
 }
 
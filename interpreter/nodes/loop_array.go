@@ -6,6 +6,7 @@ import (
 
 // Node that iterates through an array, and runs inner for each iteration
 type LoopArray[Element any] struct {
+	environment.Position
 	ValIdentifier   string
 	IndexIdentifier string
 	Array           environment.Node
@@ -13,10 +14,11 @@ type LoopArray[Element any] struct {
 }
 
 func (n *LoopArray[Element]) Eval(env *environment.Environment) any {
+	var childEnv *environment.Environment
 	for index, value := range n.Array.Eval(env).([]Element) {
-		childEnv := env.NewChild(environment.Call{})
-		childEnv.Set(n.ValIdentifier, value)
-		childEnv.Set(n.IndexIdentifier, int64(index))
+		childEnv = env.NewLoopChild(childEnv)
+		childEnv.Set(n.ValIdentifier, 0, value)
+		childEnv.Set(n.IndexIdentifier, 1, int64(index))
 		n.Inner.Eval(childEnv)
 	}
 	return nil
@@ -25,3 +27,12 @@ func (n *LoopArray[Element]) Eval(env *environment.Environment) any {
 func (n *LoopArray[Element]) References() []string {
 	return append(n.Array.References(), n.Inner.References()...)
 }
+
+// Gets the operations for the arrays used by the node
+func (n *LoopArray[Element]) ArrayOperations() ArrayOperations {
+	return newArrayOperations[Element]()
+}
+
+func (n *LoopArray[Element]) LoopDetails() (valIdentifier string, indexIdentifier string, array environment.Node, inner *Block) {
+	return n.ValIdentifier, n.IndexIdentifier, n.Array, n.Inner
+}
@@ -7,6 +7,7 @@ import (
 
 // Node that iterates through a range from start to end and runs inner on each iteration
 type LoopRange struct {
+	environment.Position
 	ValIdentifier string
 	Start         environment.Node
 	End           environment.Node
@@ -16,9 +17,10 @@ type LoopRange struct {
 func (n *LoopRange) Eval(env *environment.Environment) any {
 	startVal := getLoopRangeVal(n.Start, env)
 	endVal := getLoopRangeVal(n.End, env)
+	var childEnv *environment.Environment
 	for i := startVal; i < endVal; i++ {
-		childEnv := env.NewChild(environment.Call{})
-		childEnv.Set(n.ValIdentifier, i)
+		childEnv = env.NewLoopChild(childEnv)
+		childEnv.Set(n.ValIdentifier, 0, i)
 		n.Inner.Eval(childEnv)
 	}
 	return nil
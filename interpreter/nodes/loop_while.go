@@ -6,13 +6,15 @@ import (
 
 // Node that loops through Inner until Condition returns false when evaluated
 type LoopWhile struct {
+	environment.Position
 	Condition environment.Node
 	Inner     *Block
 }
 
 func (n *LoopWhile) Eval(env *environment.Environment) any {
+	var childEnv *environment.Environment
 	for !env.IsBroken && n.Condition.Eval(env).(bool) {
-		childEnv := env.NewChild(environment.Call{})
+		childEnv = env.NewLoopChild(childEnv)
 		n.Inner.Eval(childEnv)
 	}
 	return nil
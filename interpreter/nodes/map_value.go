@@ -4,6 +4,7 @@ import "main/interpreter/environment"
 
 // Node that gets a value from a map using it's key
 type MapValue[KeyType comparable, ValueType any] struct {
+	environment.Position
 	Map environment.Node
 	Key environment.Node
 }
@@ -15,6 +15,7 @@ const (
 
 // Node that performs a maths operation on a value
 type MathsOperation[T int8 | int16 | int32 | int64 | uint8 | uint16 | uint32 | uint64 | float32 | float64] struct {
+	environment.Position
 	Operation MathsOperationType
 	LeftSide  environment.Node
 	RightSide environment.Node
@@ -30,6 +31,8 @@ func (n *MathsOperation[T]) Eval(env *environment.Environment) any {
 	case MathsMultiplication:
 		return lhs * rhs
 	case MathsDivision:
+		// Integer division by zero panics
+		defer env.RecoverAt(n.Position)
 		return lhs / rhs
 	}
 	return 0
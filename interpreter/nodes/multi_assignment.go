@@ -0,0 +1,31 @@
+package nodes
+
+import "main/interpreter/environment"
+
+// Node that assigns values to multiple identifiers, all of the values are evaluated before any are assigned
+type MultiAssignment struct {
+	environment.Position
+	Identifiers []string
+	Slots       []int
+	Depths      []int
+	Values      []environment.Node
+}
+
+func (n *MultiAssignment) Eval(env *environment.Environment) any {
+	newVals := make([]any, len(n.Values))
+	for i, value := range n.Values {
+		newVals[i] = value.Eval(env)
+	}
+	for i, identifier := range n.Identifiers {
+		env.SetWithDepth(identifier, n.Slots[i], newVals[i], n.Depths[i])
+	}
+	return nil
+}
+
+func (n *MultiAssignment) References() []string {
+	refs := make([]string, 0)
+	for _, value := range n.Values {
+		refs = append(refs, value.References()...)
+	}
+	return append(refs, n.Identifiers...)
+}
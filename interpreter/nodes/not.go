@@ -4,6 +4,7 @@ import "main/interpreter/environment"
 
 // Node that returns opposite of a boolean value
 type Not struct {
+	environment.Position
 	Value environment.Node
 }
 
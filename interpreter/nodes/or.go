@@ -4,6 +4,7 @@ import "main/interpreter/environment"
 
 // Node that returns true if either LeftSide or RightSide is true or both
 type Or struct {
+	environment.Position
 	LeftSide  environment.Node
 	RightSide environment.Node
 }
@@ -4,10 +4,20 @@ import "main/interpreter/environment"
 
 // Node that returns a value in the current environment
 type Return struct {
+	environment.Position
 	Value environment.Node
+	// Set if the value is a function call, which is in tail position since nothing is left to do once it returns
+	TailCall bool
 }
 
 func (n *Return) Eval(env *environment.Environment) any {
+	if n.TailCall {
+		// The call is made by the function returning, once it's environment and Go stack frame are no longer used
+		call := n.Value.(*FuncCall)
+		function, args := call.evalCall(env)
+		env.Return(&tailCall{function: function, args: args, caller: env, position: call.Position})
+		return nil
+	}
 	env.Return(n.Value.Eval(env))
 	return nil
 }
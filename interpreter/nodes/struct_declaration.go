@@ -5,7 +5,9 @@ import (
 )
 
 type StructDeclaration struct {
+	environment.Position
 	Name    string
+	Slot    int
 	Methods []environment.Node
 }
 
@@ -14,15 +16,13 @@ type StructDeclaration struct {
 // All validation should have been done ahead of time by the parser
 
 func (n *StructDeclaration) Eval(env *environment.Environment) any {
-	env.Set(n.Name, func(properties ...any) {
-		methodEnv := env.NewChild(env.Call)
-
-		for _, method := range n.Methods {
-			properties = append(properties, method.Eval(methodEnv))
-		}
+	// Methods are passed the instance as their first argument so they only need to be created once
+	methods := make([]any, len(n.Methods))
+	for i, method := range n.Methods {
+		methods[i] = method.Eval(env)
+	}
 
-		methodEnv.Set("self", properties)
-	})
+	env.Set(n.Name, n.Slot, &structConstructor{name: n.Name, methods: methods})
 	return nil
 }
 
@@ -33,3 +33,19 @@ func (n *StructDeclaration) References() []string {
 	}
 	return refs
 }
+
+// Creates instances of a struct from the properties it is called with
+type structConstructor struct {
+	name    string
+	methods []any
+}
+
+func (c *structConstructor) Call(_ *environment.Environment, properties []any) any {
+	instance := make([]any, len(properties), len(properties)+len(c.methods))
+	copy(instance, properties)
+	return append(instance, c.methods...)
+}
+
+func (c *structConstructor) String() string {
+	return "struct " + c.name
+}
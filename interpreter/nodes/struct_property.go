@@ -2,13 +2,16 @@ package nodes
 
 import (
 	"main/interpreter/environment"
-	"reflect"
 )
 
+// Node that gets a property of a struct instance by it's index
 type StructProperty struct {
+	environment.Position
 	Struct   environment.Node
 	Index    int
 	IsMethod bool
+	// Whether or not the property can be assigned to, only used by the parser
+	Readonly bool
 	Name     string
 }
 
@@ -17,19 +20,7 @@ func (n *StructProperty) Eval(env *environment.Environment) any {
 	val := instance[n.Index]
 	// If the value is a method, a proxy function is used to set the instance as the first argument (self arg)
 	if n.IsMethod {
-		return func(argVals ...any) any {
-			function := reflect.ValueOf(val)
-			args := make([]reflect.Value, len(argVals)+1)
-			args[0] = reflect.ValueOf(instance)
-			for i, arg := range argVals {
-				args[i+1] = reflect.ValueOf(arg)
-			}
-			out := function.Call(args)
-			if len(out) > 0 {
-				return out[0].Interface()
-			}
-			return nil
-		}
+		return bindMethod(val, instance)
 	}
 	return val
 }
@@ -37,3 +28,21 @@ func (n *StructProperty) Eval(env *environment.Environment) any {
 func (n *StructProperty) References() []string {
 	return n.Struct.References()
 }
+
+// A method with the value it was accessed on bound as the first argument (self arg)
+type boundMethod struct {
+	method environment.Callable
+	self   any
+}
+
+// Creates a proxy function that calls a method with self as the first argument
+func bindMethod(method any, self any) *boundMethod {
+	return &boundMethod{method: method.(environment.Callable), self: self}
+}
+
+func (m *boundMethod) Call(env *environment.Environment, args []any) any {
+	withSelf := make([]any, len(args)+1)
+	withSelf[0] = m.self
+	copy(withSelf[1:], args)
+	return m.method.Call(env, withSelf)
+}
@@ -0,0 +1,21 @@
+package nodes
+
+import "main/interpreter/environment"
+
+// Node that assigns a value to a property of a struct instance
+type StructPropertyAssignment struct {
+	environment.Position
+	Property *StructProperty
+	Value    environment.Node
+}
+
+func (n *StructPropertyAssignment) Eval(env *environment.Environment) any {
+	newVal := n.Value.Eval(env)
+	instance := n.Property.Struct.Eval(env).([]any)
+	instance[n.Property.Index] = newVal
+	return newVal
+}
+
+func (n *StructPropertyAssignment) References() []string {
+	return append(n.Property.References(), n.Value.References()...)
+}
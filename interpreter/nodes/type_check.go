@@ -0,0 +1,23 @@
+package nodes
+
+import "main/interpreter/environment"
+
+// A type that values are checked against at runtime, created by the parser from the definition of the type
+type RuntimeType interface {
+	Matches(value any) bool
+}
+
+// Node that checks whether a value is of a type at runtime
+type TypeCheck struct {
+	environment.Position
+	Value environment.Node
+	Type  RuntimeType
+}
+
+func (n *TypeCheck) Eval(env *environment.Environment) any {
+	return n.Type.Matches(n.Value.Eval(env))
+}
+
+func (n *TypeCheck) References() []string {
+	return n.Value.References()
+}
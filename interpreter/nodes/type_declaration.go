@@ -0,0 +1,36 @@
+package nodes
+
+import (
+	"main/interpreter/environment"
+)
+
+// Node that declares the methods of a distinct type.
+// Values of distinct types are stored as their underlying value, so the methods are stored under the name of the type.
+type TypeDeclaration struct {
+	environment.Position
+	Name    string
+	Slot    int
+	Methods []environment.Node
+}
+
+func (n *TypeDeclaration) Eval(env *environment.Environment) any {
+	// Aliases and types without methods have nothing to declare at runtime
+	if len(n.Methods) == 0 {
+		return nil
+	}
+
+	methods := make([]any, len(n.Methods))
+	for i, method := range n.Methods {
+		methods[i] = method.Eval(env)
+	}
+	env.Set(n.Name, n.Slot, methods)
+	return nil
+}
+
+func (n *TypeDeclaration) References() []string {
+	refs := make([]string, 0)
+	for _, method := range n.Methods {
+		refs = append(refs, method.References()...)
+	}
+	return refs
+}
@@ -0,0 +1,23 @@
+package nodes
+
+import (
+	"main/interpreter/environment"
+)
+
+// Node that gets a method of a distinct type with the value it was accessed on bound as the first argument
+type TypeMethod struct {
+	environment.Position
+	Value environment.Node
+	// Evaluates to the methods of the type, which are stored under the name of the type
+	Methods environment.Node
+	Index   int
+}
+
+func (n *TypeMethod) Eval(env *environment.Environment) any {
+	self := n.Value.Eval(env)
+	return bindMethod(n.Methods.Eval(env).([]any)[n.Index], self)
+}
+
+func (n *TypeMethod) References() []string {
+	return append(n.Value.References(), n.Methods.References()...)
+}
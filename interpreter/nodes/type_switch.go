@@ -0,0 +1,45 @@
+package nodes
+
+import "main/interpreter/environment"
+
+// Node that runs the inner block of the first case that the type of a value matches, if no case matches Default is run if it is set
+type TypeSwitch struct {
+	environment.Position
+	Value   environment.Node
+	Cases   []TypeSwitchCase
+	Default *Block
+}
+
+// A case of a type switch, the value is set to Identifier in the first slot of the environment of the inner block
+type TypeSwitchCase struct {
+	Identifier string
+	Type       RuntimeType
+	Inner      *Block
+}
+
+func (n *TypeSwitch) Eval(env *environment.Environment) any {
+	val := n.Value.Eval(env)
+	for _, typeCase := range n.Cases {
+		if typeCase.Type.Matches(val) {
+			childEnv := env.NewChild(environment.Call{})
+			childEnv.Set(typeCase.Identifier, 0, val)
+			typeCase.Inner.Eval(childEnv)
+			return nil
+		}
+	}
+	if n.Default != nil {
+		n.Default.Eval(env.NewChild(environment.Call{}))
+	}
+	return nil
+}
+
+func (n *TypeSwitch) References() []string {
+	refs := n.Value.References()
+	for _, typeCase := range n.Cases {
+		refs = append(refs, typeCase.Inner.References()...)
+	}
+	if n.Default != nil {
+		refs = append(refs, n.Default.References()...)
+	}
+	return refs
+}
@@ -6,6 +6,7 @@ import (
 
 // Node that represents a literal value
 type Value struct {
+	environment.Position
 	Value any
 }
 
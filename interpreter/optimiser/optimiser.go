@@ -0,0 +1,538 @@
+package optimiser
+
+// The optimiser rewrites the AST of a program between parsing and executing it, so that either engine does less work
+// at runtime without the program behaving any differently. Nodes are visited with reflection so that every node,
+// including generic ones, can be optimised without the optimiser listing all of them.
+
+import (
+	"main/interpreter/environment"
+	"main/interpreter/nodes"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type Level uint8
+
+const (
+	// The AST is executed as it was parsed
+	LevelNone Level = iota
+	// Folds constants, removes branches that can never run, inlines small functions and hoists expressions that don't
+	// change between the iterations of a loop out of it
+	LevelDefault
+)
+
+// The maximum number of nodes in the expression returned by a function for calls of it to be inlined
+const maxInlineNodes = 16
+
+var (
+	nodeType     = reflect.TypeOf((*environment.Node)(nil)).Elem()
+	blockType    = reflect.TypeOf((*nodes.Block)(nil))
+	nodesPkgPath = reflect.TypeOf(nodes.Block{}).PkgPath()
+	stringType   = reflect.TypeOf("")
+	stringsType  = reflect.TypeOf([]string(nil))
+)
+
+// The fields of nodes that hold the names of the identifiers they declare or assign to. Array loops and
+// destructuring are generic, so their identifiers are found by the names of their fields.
+var identifierFields = []string{"Identifier", "Identifiers", "ValIdentifier", "IndexIdentifier", "ArgNames"}
+
+type optimiser struct {
+	// The number of times each identifier is declared or assigned to by the program. An identifier that is only
+	// declared once always holds the same value after it's declaration, since nothing can shadow it or assign to it.
+	assignments map[string]int
+	// The functions that calls can be replaced by the expression the function returns, by name
+	inlinable map[string]*nodes.FuncDeclaration
+	// The number of identifiers declared for hoisted expressions, used to give each of them a unique name
+	hoisted int
+}
+
+// Optimises the AST of a program at a level, the nodes of the AST are changed in place
+func Optimise(ast []environment.Node, level Level) []environment.Node {
+	if level == LevelNone {
+		return ast
+	}
+
+	o := &optimiser{assignments: make(map[string]int), inlinable: make(map[string]*nodes.FuncDeclaration)}
+	var functions []*nodes.FuncDeclaration
+	for _, node := range ast {
+		walk(node, func(node environment.Node) {
+			for _, name := range assignedNames(node) {
+				o.assignments[name]++
+			}
+			if function, ok := node.(*nodes.FuncDeclaration); ok {
+				functions = append(functions, function)
+			}
+		})
+	}
+	for _, function := range functions {
+		if o.canInline(function) {
+			o.inlinable[function.Name] = function
+		}
+	}
+	return o.optimiseStatements(ast)
+}
+
+// Optimises the statements of a block, dropping the statements that are removed
+func (o *optimiser) optimiseStatements(statements []environment.Node) []environment.Node {
+	optimised := statements[:0]
+	for _, statement := range statements {
+		if statement = o.optimiseNode(statement); statement != nil {
+			optimised = append(optimised, statement)
+		}
+	}
+	return optimised
+}
+
+// Optimises a node after optimising it's children, returning the node that replaces it or nil if it is a statement
+// that does nothing
+func (o *optimiser) optimiseNode(node environment.Node) environment.Node {
+	if block, ok := node.(*nodes.Block); ok {
+		block.Nodes = o.optimiseStatements(block.Nodes)
+		return block
+	}
+	for _, slot := range childSlots(node) {
+		setSlot(slot, o.optimiseNode(slot.Interface().(environment.Node)))
+	}
+
+	switch n := node.(type) {
+	case *nodes.IfStatement:
+		return optimiseIf(n)
+	case *nodes.LoopWhile:
+		if isConstant(n.Condition, false) {
+			return nil
+		}
+	case *nodes.Return:
+		// A call that has been inlined is no longer a tail call
+		_, n.TailCall = n.Value.(*nodes.FuncCall)
+	case *nodes.FuncCall:
+		if inlined := o.inlineCall(n); inlined != nil {
+			return inlined
+		}
+	case *nodes.And:
+		if constant, ok := n.LeftSide.(*nodes.Value); ok {
+			if constant.Value.(bool) {
+				return n.RightSide
+			}
+			return constant
+		}
+	case *nodes.Or:
+		if constant, ok := n.LeftSide.(*nodes.Value); ok {
+			if constant.Value.(bool) {
+				return constant
+			}
+			return n.RightSide
+		}
+	}
+	if isLoop(node) {
+		return o.hoistInvariants(node)
+	}
+	return fold(node)
+}
+
+// Removes the branches of an if statement that can never run since it's condition is constant
+func optimiseIf(n *nodes.IfStatement) environment.Node {
+	condition, ok := n.Condition.(*nodes.Value)
+	if !ok {
+		if elseIf, ok := n.Else.(*nodes.IfStatement); ok && isConstant(elseIf.Condition, true) {
+			n.Else = elseIf.Inner
+		}
+		return n
+	}
+	if condition.Value.(bool) {
+		n.Else = nil
+		return n
+	}
+	switch otherwise := n.Else.(type) {
+	case nil:
+		return nil
+	case *nodes.Block:
+		// The else block has it's own environment in the same way as the inner block
+		return &nodes.IfStatement{
+			Position:  n.Position,
+			Condition: &nodes.Value{Position: condition.Position, Value: true},
+			Inner:     otherwise,
+		}
+	}
+	return n.Else
+}
+
+// Evaluates a node ahead of time if it only computes a value from constants
+func fold(node environment.Node) environment.Node {
+	if !isPure(node) {
+		return node
+	}
+	for _, slot := range childSlots(node) {
+		if _, ok := slot.Interface().(*nodes.Value); !ok {
+			return node
+		}
+	}
+	value, ok := evaluate(node)
+	if !ok {
+		return node
+	}
+	return &nodes.Value{Position: positionOf(node), Value: value}
+}
+
+// Evaluates a node of constants, failing if it panics such as by dividing an integer by zero so that the panic
+// happens when the program is run instead
+func evaluate(node environment.Node) (value any, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	return node.Eval(nil), true
+}
+
+// Whether or not calls of a function can be replaced by the expression it returns, which they can if the function
+// is only declared once, isn't variadic and returns an expression of it's arguments that has no side effects. The
+// expression can't panic either, since the runtime error would no longer be in the function.
+func (o *optimiser) canInline(n *nodes.FuncDeclaration) bool {
+	if n.Name == "" || n.IsMethod || n.PackVariadic != nil || o.assignments[n.Name] != 1 || len(n.Inner.Nodes) != 1 {
+		return false
+	}
+	returned, ok := n.Inner.Nodes[0].(*nodes.Return)
+	if !ok {
+		return false
+	}
+
+	size, safe := 0, true
+	walk(returned.Value, func(node environment.Node) {
+		size++
+		switch node := node.(type) {
+		case *nodes.Value:
+		case *nodes.Identifier:
+			safe = safe && node.Depth == 0 && node.Slot < len(n.ArgNames) && n.ArgNames[node.Slot] == node.Name
+		default:
+			safe = safe && isPure(node) && !canPanic(node)
+		}
+	})
+	return safe && size <= maxInlineNodes
+}
+
+// Replaces a call with the expression returned by the function it calls, returning nil if it can't be inlined
+func (o *optimiser) inlineCall(n *nodes.FuncCall) environment.Node {
+	callee, ok := n.Function.(*nodes.Identifier)
+	if !ok {
+		return nil
+	}
+	function, ok := o.inlinable[callee.Name]
+	if !ok || len(n.Args) != len(function.ArgNames) {
+		return nil
+	}
+	for _, arg := range n.Args {
+		switch arg.(type) {
+		case *nodes.Value, *nodes.Identifier:
+		default:
+			// Arguments are evaluated once before the call, so only arguments without side effects can be evaluated
+			// where the function uses them instead. Omitted arguments are nil, which use their default value.
+			return nil
+		}
+	}
+	returned := function.Inner.Nodes[0].(*nodes.Return).Value
+	return o.optimiseNode(substitute(returned, n.Args))
+}
+
+// Copies an expression returned by a function, replacing the arguments of the function with the arguments of a call
+func substitute(node environment.Node, args []environment.Node) environment.Node {
+	if identifier, ok := node.(*nodes.Identifier); ok {
+		// Functions are only inlined if the identifiers they use are all arguments
+		return clone(args[identifier.Slot])
+	}
+	copied := clone(node)
+	for _, slot := range childSlots(copied) {
+		setSlot(slot, substitute(slot.Interface().(environment.Node), args))
+	}
+	return copied
+}
+
+// Copies a node, along with the slices of it's children, without copying the children themselves
+func clone(node environment.Node) environment.Node {
+	value := reflect.ValueOf(node).Elem()
+	copied := reflect.New(value.Type())
+	copied.Elem().Set(value)
+	for i := 0; i < copied.Elem().NumField(); i++ {
+		field := copied.Elem().Field(i)
+		if field.Kind() == reflect.Slice && field.CanSet() && !field.IsNil() {
+			slice := reflect.MakeSlice(field.Type(), field.Len(), field.Len())
+			reflect.Copy(slice, field)
+			field.Set(slice)
+		}
+	}
+	return copied.Interface().(environment.Node)
+}
+
+// An expression in a loop that evaluates to the same value on every iteration
+type invariant struct {
+	slot reflect.Value
+	// The number of environments the expression is nested in below the environment the loop runs in
+	level int
+}
+
+// Moves the expressions that evaluate to the same value on every iteration of a loop out of it. They are assigned to
+// identifiers in a new environment around the loop, so they are evaluated once each time the loop is run.
+func (o *optimiser) hoistInvariants(loop environment.Node) environment.Node {
+	declared := make(map[string]bool)
+	walk(loop, func(node environment.Node) {
+		for _, name := range assignedNames(node) {
+			declared[name] = true
+		}
+	})
+
+	var invariants []invariant
+	_, isWhile := loop.(*nodes.LoopWhile)
+	for _, slot := range childSlots(loop) {
+		// The values a range or array loop iterates over are already only evaluated once
+		if block, ok := slot.Interface().(*nodes.Block); ok {
+			invariants = o.findInvariants(block, 1, declared, invariants)
+		} else if isWhile && o.isInvariant(slot.Interface().(environment.Node), declared) {
+			invariants = append(invariants, invariant{slot: slot, level: 0})
+		}
+	}
+	if len(invariants) == 0 {
+		return loop
+	}
+
+	// The identifiers in the loop that are declared outside of it are now one more environment away
+	shiftDepths(loop, 0, make(map[environment.Node]bool))
+	position := positionOf(loop)
+	wrapper := &nodes.Block{}
+	for slot, found := range invariants {
+		name := "$hoisted" + strconv.Itoa(o.hoisted)
+		o.hoisted++
+		expression := found.slot.Interface().(environment.Node)
+		walk(expression, func(node environment.Node) {
+			if identifier, ok := node.(*nodes.Identifier); ok {
+				identifier.Depth -= found.level
+			}
+		})
+		wrapper.Nodes = append(wrapper.Nodes, &nodes.Assignment{
+			Position:   position,
+			Identifier: name,
+			Slot:       slot,
+			NewValue:   expression,
+		})
+		setSlot(found.slot, &nodes.Identifier{Position: positionOf(expression), Name: name, Slot: slot, Depth: found.level})
+	}
+	wrapper.Nodes = append(wrapper.Nodes, loop)
+	return &nodes.IfStatement{
+		Position:  position,
+		Condition: &nodes.Value{Position: position, Value: true},
+		Inner:     wrapper,
+	}
+}
+
+// Finds the largest invariant expressions in a node that is nested in a number of environments below the loop
+func (o *optimiser) findInvariants(node environment.Node, level int, declared map[string]bool, found []invariant) []invariant {
+	for _, slot := range childSlots(node) {
+		switch child := slot.Interface().(environment.Node).(type) {
+		case *nodes.Block:
+			found = o.findInvariants(child, level+1, declared, found)
+		case *nodes.FuncDeclaration:
+			// The body of a function isn't run by the iteration that declares it
+		default:
+			if o.isInvariant(child, declared) {
+				found = append(found, invariant{slot: slot, level: level})
+			} else {
+				found = o.findInvariants(child, level, declared, found)
+			}
+		}
+	}
+	return found
+}
+
+// Whether or not an expression can be evaluated before a loop instead of on each iteration. It can be if it only
+// uses constants and identifiers that are declared once outside of the loop, and evaluating it can't panic.
+func (o *optimiser) isInvariant(node environment.Node, declared map[string]bool) bool {
+	if !isPure(node) {
+		return false
+	}
+	invariant := true
+	walk(node, func(node environment.Node) {
+		switch node := node.(type) {
+		case *nodes.Value:
+		case *nodes.Identifier:
+			invariant = invariant && o.assignments[node.Name] == 1 && !declared[node.Name]
+		default:
+			invariant = invariant && isPure(node) && !canPanic(node)
+		}
+	})
+	return invariant
+}
+
+// Moves the identifiers in a node that are declared outside of it one environment further away, since an
+// environment is being added around the node. The level is the number of environments the node is nested in below
+// the environment it is run in.
+func shiftDepths(node environment.Node, level int, shifted map[environment.Node]bool) {
+	if shifted[node] {
+		return
+	}
+	shifted[node] = true
+	switch n := node.(type) {
+	case *nodes.Identifier:
+		if n.Depth >= level {
+			n.Depth++
+		}
+	case *nodes.Assignment:
+		if n.Depth >= level {
+			n.Depth++
+		}
+	case *nodes.MultiAssignment:
+		for i := range n.Depths {
+			if n.Depths[i] >= level {
+				n.Depths[i]++
+			}
+		}
+	}
+	for _, slot := range childSlots(node) {
+		child := slot.Interface().(environment.Node)
+		if _, ok := child.(*nodes.Block); ok {
+			// Every block has it's own environment
+			shiftDepths(child, level+1, shifted)
+		} else {
+			shiftDepths(child, level, shifted)
+		}
+	}
+}
+
+// Whether or not a node only computes a value from the values of it's children, without any side effects
+func isPure(node environment.Node) bool {
+	switch node.(type) {
+	case *nodes.Not, *nodes.And, *nodes.Or, *nodes.EqualityComparison:
+		return true
+	}
+	// Maths operations and comparisons of numbers are generic, so they are matched by the name of their type
+	nodeType := reflect.TypeOf(node)
+	if nodeType.Kind() != reflect.Pointer || nodeType.Elem().PkgPath() != nodesPkgPath {
+		return false
+	}
+	name := nodeType.Elem().Name()
+	return strings.HasPrefix(name, "MathsOperation[") || strings.HasPrefix(name, "InequalityComparison[")
+}
+
+// Whether or not evaluating a pure node can panic, which only dividing an integer by zero can do
+func canPanic(node environment.Node) bool {
+	switch node.(type) {
+	case *nodes.MathsOperation[float32], *nodes.MathsOperation[float64]:
+		return false
+	}
+	operation := reflect.ValueOf(node).Elem().FieldByName("Operation")
+	return operation.IsValid() && operation.Interface() == nodes.MathsDivision
+}
+
+func isConstant(node environment.Node, value bool) bool {
+	constant, ok := node.(*nodes.Value)
+	return ok && constant.Value == value
+}
+
+// Whether or not a node is a loop, array loops are generic so they are matched by having an array and an inner block
+func isLoop(node environment.Node) bool {
+	switch node.(type) {
+	case *nodes.LoopWhile, *nodes.LoopRange:
+		return true
+	}
+	value := reflect.ValueOf(node)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	return value.Elem().FieldByName("Inner").IsValid() && value.Elem().FieldByName("Array").IsValid()
+}
+
+// Gets the identifiers a node declares or assigns to
+func assignedNames(node environment.Node) []string {
+	var names []string
+	switch n := node.(type) {
+	case *nodes.FuncDeclaration:
+		if n.Name != "" && !n.IsMethod {
+			names = append(names, n.Name)
+		}
+	case *nodes.StructDeclaration:
+		names = append(names, n.Name)
+	case *nodes.TypeDeclaration:
+		names = append(names, n.Name)
+	case *nodes.TypeSwitch:
+		for _, typeCase := range n.Cases {
+			names = append(names, typeCase.Identifier)
+		}
+	}
+
+	value := reflect.ValueOf(node)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return names
+	}
+	for _, name := range identifierFields {
+		field := value.Elem().FieldByName(name)
+		switch {
+		case !field.IsValid():
+		case field.Type() == stringType:
+			names = append(names, field.String())
+		case field.Type() == stringsType:
+			names = append(names, field.Interface().([]string)...)
+		}
+	}
+	return names
+}
+
+// Calls a function for a node and every node nested in it
+func walk(node environment.Node, fn func(node environment.Node)) {
+	fn(node)
+	for _, slot := range childSlots(node) {
+		walk(slot.Interface().(environment.Node), fn)
+	}
+}
+
+// Gets the fields of a node, and the elements of it's slices, that hold it's children. Nil children are skipped.
+func childSlots(node environment.Node) []reflect.Value {
+	value := reflect.ValueOf(node)
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	var slots []reflect.Value
+	collectSlots(value.Elem(), &slots)
+	return slots
+}
+
+func collectSlots(value reflect.Value, slots *[]reflect.Value) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+		switch {
+		case field.Type() == nodeType || (field.Kind() == reflect.Pointer && field.Type().Implements(nodeType)):
+			// Fields of a type of node, such as the array index of an array assignment, are only replaced by a node of
+			// the same type
+			if !field.IsNil() {
+				*slots = append(*slots, field)
+			}
+		case field.Kind() == reflect.Slice && (field.Type().Elem() == nodeType || field.Type().Elem() == blockType):
+			for j := 0; j < field.Len(); j++ {
+				if !field.Index(j).IsNil() {
+					*slots = append(*slots, field.Index(j))
+				}
+			}
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct:
+			// Such as the cases of a type switch
+			for j := 0; j < field.Len(); j++ {
+				collectSlots(field.Index(j), slots)
+			}
+		}
+	}
+}
+
+// Replaces the child in a slot, a nil node removes it
+func setSlot(slot reflect.Value, node environment.Node) {
+	if node == nil {
+		slot.Set(reflect.Zero(slot.Type()))
+	} else {
+		slot.Set(reflect.ValueOf(node))
+	}
+}
+
+func positionOf(node environment.Node) environment.Position {
+	if positioned, ok := node.(environment.Positioned); ok {
+		return positioned.GetPosition()
+	}
+	return environment.Position{}
+}
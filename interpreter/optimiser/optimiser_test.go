@@ -0,0 +1,107 @@
+package optimiser_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"main/interpreter"
+	"main/interpreter/environment"
+	"main/interpreter/nodes"
+	"main/interpreter/optimiser"
+	"strings"
+	"testing"
+)
+
+// Parses a program, optimises it at a level and runs it with an engine, returning what it printed
+func run(t *testing.T, source string, level optimiser.Level, engine interpreter.Engine) (string, []environment.Node, *interpreter.RuntimeError) {
+	var output bytes.Buffer
+	globalDefs, globals := interpreter.BindValues(map[string]any{
+		"print": func(args ...any) {
+			fmt.Fprintln(&output, args...)
+		},
+	})
+	ast, diagnostics := interpreter.NewParser(source, "test.lang", globalDefs, nil).Parse()
+	if interpreter.HasErrors(diagnostics) {
+		t.Fatalf("Expected the program to parse, got %v", diagnostics)
+	}
+	ast = optimiser.Optimise(ast, level)
+	_, _, err := interpreter.Execute(context.Background(), ast, "test.lang", engine, false, environment.GCOptions{}, environment.Limits{}, globals, nil)
+	return output.String(), ast, err
+}
+
+// Counts the expressions of the top level statements that were hoisted out of a loop, which are assigned to
+// identifiers at the start of the block the optimiser wraps the loop in
+func hoisted(statements []environment.Node) int {
+	count := 0
+	for _, statement := range statements {
+		wrapper, ok := statement.(*nodes.IfStatement)
+		if !ok {
+			continue
+		}
+		for _, node := range wrapper.Inner.Nodes {
+			if assignment, ok := node.(*nodes.Assignment); ok && strings.HasPrefix(assignment.Identifier, "$hoisted") {
+				count++
+			}
+		}
+		count += hoisted(wrapper.Inner.Nodes)
+	}
+	return count
+}
+
+// Runs programs with and without optimisations on both engines, checking they print the same output and fail with
+// the same error
+func TestOptimisedBehaviour(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		source string
+		output string
+		// The runtime error of the program, if it fails
+		err string
+		// The number of expressions hoisted out of the loops at the top level of the program
+		hoisted int
+	}{
+		{
+			name:   "division by zero in a folded expression",
+			source: "print(1 + 1)\nvar x = 10 / (5 - 5)\nprint(x)\n",
+			output: "2\n",
+			err:    "runtime error: integer divide by zero (line 2, column 9)",
+		},
+		{
+			// The divisions can't be hoisted since they would fail before the loops run, unlike the multiplications and
+			// the condition of the while loop
+			name:    "loop that runs zero times around a trapping expression",
+			source:  "var zero int64 = 0\nvar n int64 = 2\nfor i range 0 {\n\tprint(n * 3, 10 / zero)\n}\nvar count int64 = 0\nwhile count > 0 {\n\tprint(n * 3, 10 / zero)\n}\nprint(\"done\")\n",
+			output:  "done\n",
+			hoisted: 3,
+		},
+		{
+			name:   "inlining with a shadowed argument name",
+			source: "fn double(x: int64): int64 {\n\treturn x * 2\n}\nfn sum(x: int64, y: int64): int64 {\n\treturn x + y\n}\nvar x int64 = 1\nvar y int64 = 10\nfn f(x: int64): int64 {\n\tvar y = x + 100\n\treturn double(y) + sum(y, x)\n}\nprint(f(5), sum(y, x), double(x))\nfor x range 2 {\n\tprint(sum(x, y))\n}\n",
+			output: "320 11 2\n10\n11\n",
+		},
+		{
+			name:    "nested loops",
+			source:  "var n int64 = 3\nvar total int64 = 0\nvar cells = [0, 0, 0, 0, 0, 0]\nfor i range 3 {\n\tvar row = i * 2\n\tfor j range 2 {\n\t\tcells[row + j] = n * 2 + i * j\n\t\ttotal = total + n * 4 + row\n\t}\n}\nprint(total, cells)\n",
+			output:  "84 [6 6 6 7 6 8]\n",
+			hoisted: 2,
+		},
+	} {
+		for engineName, engine := range map[string]interpreter.Engine{"tree": interpreter.EngineTreeWalker, "bytecode": interpreter.EngineBytecode} {
+			t.Run(test.name+"/"+engineName, func(t *testing.T) {
+				output, _, err := run(t, test.source, optimiser.LevelNone, engine)
+				optimisedOutput, optimised, optimisedErr := run(t, test.source, optimiser.LevelDefault, engine)
+				if output != test.output || optimisedOutput != test.output {
+					t.Errorf("Expected the output %q, got %q without optimisations and %q with them", test.output, output, optimisedOutput)
+				}
+				for _, err := range []*interpreter.RuntimeError{err, optimisedErr} {
+					if (err == nil && test.err != "") || (err != nil && err.Error() != test.err) {
+						t.Errorf("Expected the error %q, got %v", test.err, err)
+					}
+				}
+				if count := hoisted(optimised); count != test.hoisted {
+					t.Errorf("Expected %d hoisted expressions, got %d", test.hoisted, count)
+				}
+			})
+		}
+	}
+}
@@ -4,9 +4,6 @@ import (
 	"fmt"
 	"main/interpreter/environment"
 	"main/interpreter/nodes"
-	"os"
-
-	"github.com/logrusorgru/aurora/v4"
 )
 
 type Parser struct {
@@ -14,59 +11,195 @@ type Parser struct {
 	filePath       string
 	currentTypeEnv *TypeEnvironment
 	modules        map[string]map[string]TypeDef
+	globals        []string
+	// Whether or not the parser is collecting the top-level declarations before the program is parsed
+	hoisting    bool
+	diagnostics []Diagnostic
+	// Records the declarations and uses of identifiers when the program is being analysed, otherwise nil
+	analysis *Analysis
+	// Whether or not the lint rules are checked as the program is parsed
+	linting bool
+	// The positions of the name and arguments of the last function signature that was parsed, so that they can be
+	// declared once the function is
+	functionName environment.Position
+	argPositions []environment.Position
 }
 
+// Panicked with once a diagnostic has been recorded for an error, so that parsing can continue from the next statement
+type parseError struct{}
+
 func NewParser(content string, filePath string, globals map[string]TypeDef, modules map[string]map[string]TypeDef) *Parser {
-	p := &Parser{
+	p := newParser(content, filePath, NewTypeEnvironment(nil, nil, 0), modules)
+	for name, def := range globals {
+		// Globals are bound by the host before the program, so they are immutable from line 0
+		p.currentTypeEnv.SetImmutable(name, def, 0)
+		p.globals = append(p.globals, name)
+	}
+	return p
+}
+
+// Creates a parser that declares the top-level identifiers of the program in an existing type environment
+func newParser(content string, filePath string, typeEnv *TypeEnvironment, modules map[string]map[string]TypeDef) *Parser {
+	return &Parser{
 		lexer:          NewLexer(content),
 		filePath:       filePath,
-		currentTypeEnv: NewTypeEnvironment(nil, nil, 0),
+		currentTypeEnv: typeEnv,
 		modules:        modules,
 	}
-
-	for name, def := range globals {
-		p.currentTypeEnv.Set(name, def)
-	}
-	return p
 }
 
-// Creates abstract syntax tree
-func (p *Parser) Parse() []environment.Node {
+// Creates abstract syntax tree, along with diagnostics for the problems found in the program.
+// The program shouldn't be run if any of the diagnostics are errors.
+func (p *Parser) Parse() ([]environment.Node, []Diagnostic) {
+	p.hoistDeclarations()
+
+	// Globals are bound to the slots the parser gave them before the program runs
 	ast := make([]environment.Node, 0)
+	for _, name := range p.globals {
+		slot, _ := p.currentTypeEnv.GetSlot(name)
+		ast = append(ast, &nodes.Assignment{
+			Identifier: name,
+			Slot:       slot,
+			NewValue:   &nodes.Global{Name: name},
+		})
+	}
 	for {
-		node := p.ParseNext(false)
-		if node == nil {
+		node, more := p.parseNextRecovering(false)
+		if !more {
 			break
 		}
-		ast = append(ast, node)
+		if node != nil {
+			ast = append(ast, node)
+		}
 	}
-	return ast
+	return ast, p.diagnostics
 }
 
-func (p *Parser) ParseNext(inBlock bool) environment.Node {
-	token := p.lexer.NextOrExit()
-	if token.Type == TokenNewLine || token.Type == TokenSemiColon {
-		return p.ParseNext(inBlock)
+// Parses source code that is a single expression such as 1 + x, returning it's node and definition along with the
+// diagnostics of the expression. The node is nil if the expression has errors.
+func (p *Parser) ParseExpression() (node environment.Node, def TypeDef, diagnostics []Diagnostic) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.recoverError(r)
+			node, def, diagnostics = nil, nil, p.diagnostics
+		}
+	}()
+
+	node, def = p.ParseValue(nil)
+	// The expression can be followed by empty lines
+	token := p.ExpectToken(TokenEOF, TokenNewLine)
+	for token.Type == TokenNewLine {
+		token = p.ExpectToken(TokenEOF, TokenNewLine)
 	}
-	if token.Type == TokenRightBrace && inBlock {
-		return nil
+	return node, def, p.diagnostics
+}
+
+// Parses the next statement, recording a diagnostic and skipping to the end of the statement if it has an error.
+// Returns false once there are no statements left.
+func (p *Parser) parseNextRecovering(inBlock bool) (node environment.Node, more bool) {
+	typeEnv := p.currentTypeEnv
+	defer func() {
+		if r := recover(); r != nil {
+			p.recoverError(r)
+			p.currentTypeEnv = typeEnv
+			p.skipStatement(inBlock)
+			node, more = nil, true
+		}
+	}()
+
+	node = p.ParseNext(inBlock)
+	return node, node != nil
+}
+
+// Records a diagnostic for an error that was recovered from. Any other panic is a bug in the parser, which is reported
+// as an error of the statement so that tools using the parser aren't ended by it.
+func (p *Parser) recoverError(r any) {
+	switch err := r.(type) {
+	case parseError:
+	case *LexerError:
+		p.addDiagnostic(Diagnostic{
+			Position: environment.Position{Line: err.Line, Column: err.Column},
+			Code:     DiagnosticLexerError,
+			Severity: SeverityError,
+			Message:  err.Error(),
+		})
+	default:
+		p.reportError(DiagnosticInternalError, "Internal error while parsing the statement: ", r)
 	}
+}
 
-	// Check for comment
-	if token.Type == TokenForwardSlash {
-		if p.lexer.PeekOrExit().Type == TokenForwardSlash {
-			for {
-				if token, err := p.lexer.Next(); err == nil && token.Type == TokenNewLine || token.Type == TokenEOF {
-					return p.ParseNext(inBlock)
+// Skips the rest of a statement with an error, up to the end of the line or the end of the block it is in
+func (p *Parser) skipStatement(inBlock bool) {
+	depth := 0
+	// The error could have been at the token that ended the statement
+	token := p.lexer.LastToken()
+	for {
+		switch token.Type {
+		case TokenEOF:
+			return
+		case TokenNewLine, TokenSemiColon:
+			if depth == 0 {
+				return
+			}
+		case TokenLeftBrace:
+			depth++
+		case TokenRightBrace:
+			if depth == 0 {
+				if inBlock {
+					p.lexer.Unread(token)
 				}
+				return
 			}
+			depth--
+		}
+
+		// Lexer errors are skipped over since the error has already been recorded
+		var err error
+		if token, err = p.lexer.Next(); err != nil {
+			token = Token{}
 		}
 	}
+}
 
-	// If the current block has already returned, we don't want to read anymore statements
-	// and instead can recursively read through all tokens until the closing curly right brace
-	if p.currentTypeEnv.GetReturned() {
-		return p.ParseNext(inBlock)
+// Records a diagnostic, ignoring duplicates since declarations are parsed again after they are hoisted
+func (p *Parser) addDiagnostic(diagnostic Diagnostic) {
+	for _, existing := range p.diagnostics {
+		if existing == diagnostic {
+			return
+		}
+	}
+	p.diagnostics = append(p.diagnostics, diagnostic)
+}
+
+func (p *Parser) ParseNext(inBlock bool) environment.Node {
+	token := p.lexer.MustNext()
+	// If the current block has already returned, we don't want to read anymore statements and instead skip the
+	// tokens up to the closing curly right brace, along with the braces of any blocks in between
+	for depth := 0; ; token = p.lexer.MustNext() {
+		if token.Type == TokenNewLine || token.Type == TokenSemiColon {
+			continue
+		}
+		if token.Type == TokenEOF && inBlock {
+			// The block is ended here so that the rest of the program is still checked
+			p.reportError(DiagnosticSyntaxError, "Reached end of file before the end of the code block.")
+			return nil
+		}
+		if token.Type == TokenRightBrace && inBlock && depth == 0 {
+			return nil
+		}
+		if !p.currentTypeEnv.GetReturned() {
+			break
+		}
+
+		if !p.currentTypeEnv.unreachable {
+			p.currentTypeEnv.unreachable = true
+			p.reportWarning(DiagnosticUnreachableCode, "The code after the return statement is never run.")
+		}
+		if token.Type == TokenLeftBrace {
+			depth++
+		} else if token.Type == TokenRightBrace {
+			depth--
+		}
 	}
 
 	defer func() {
@@ -77,25 +210,39 @@ func (p *Parser) ParseNext(inBlock bool) environment.Node {
 			panic(err)
 		}
 		// Expect token ending the statement
-		token := p.ExpectToken(TokenEOF, TokenNewLine, TokenSemiColon, TokenForwardSlash, TokenRightBrace)
-		if token.Type == TokenForwardSlash || token.Type == TokenRightBrace {
-			p.lexer.Unread(token) // If the token is the start of a comment, it should be unread so the next call of ParseNext() reads the start of the comment
+		token := p.ExpectToken(TokenEOF, TokenNewLine, TokenSemiColon, TokenRightBrace)
+		if token.Type == TokenRightBrace {
+			p.lexer.Unread(token) // The closing brace ends the block the statement is in, which is read by ParseBlock
 		}
 	}()
 
+	return p.parseStatement(token)
+}
+
+// Parses the statement that starts with the token, giving it the span from the token to the end of the statement
+func (p *Parser) parseStatement(token Token) environment.Node {
+	return p.span(p.parseStatementNode(token), token.Position())
+}
+
+func (p *Parser) parseStatementNode(token Token) environment.Node {
 	switch token.Type {
 	case TokenVarDeclaration:
-		return p.ParseVarDeclaration()
+		return p.ParseVarDeclaration(false)
+	case TokenLetDeclaration:
+		return p.ParseVarDeclaration(true)
 	case TokenFunctionDeclaration:
 		return p.ParseFunctionDeclaration()
 	case TokenIfStatement:
 		return p.ParseIfStatement()
 	case TokenIdentifier:
-		typeDef, _ := p.currentTypeEnv.Get(token.Literal)
+		if p.lexer.MustPeek().Type == TokenComma {
+			return p.ParseMultiAssignment(token)
+		}
+		typeDef := p.lookup(token)
 		if typeDef == nil {
 			p.ThrowTypeError(token.Literal, " is not defined in this scope.")
 		}
-		node, _ := p.ParseOperator(p.ParseValueExpression(&nodes.Identifier{Name: token.Literal}, typeDef))
+		node, _ := p.ParseOperator(p.ParseValueExpression(p.span(p.newIdentifier(token.Literal), token.Position()), typeDef))
 		return node
 	case TokenReturnStatement:
 		returnType := p.currentTypeEnv.GetReturnType()
@@ -104,79 +251,255 @@ func (p *Parser) ParseNext(inBlock bool) environment.Node {
 		}
 
 		returnValue, returnValueDef := p.ParseValue(returnType)
-		if !returnValueDef.Equals(returnType) {
+		// Calls to functions that don't return a value have no definition
+		if returnValueDef == nil || !returnValueDef.Equals(returnType) {
 			p.ThrowTypeError("Incorrect type of value returned.")
 		}
 		p.currentTypeEnv.SetReturned()
+		_, tailCall := returnValue.(*nodes.FuncCall)
 		return &nodes.Return{
-			Value: returnValue,
+			Value:    returnValue,
+			TailCall: tailCall,
 		}
 	case TokenForStatement:
 		return p.ParseForStatement()
 	case TokenStructDeclaration:
 		return p.ParseStructDeclaration()
+	case TokenTypeDeclaration:
+		return p.ParseTypeDeclaration()
 	case TokenImportStatement:
 		return p.ParseImportStatement()
 	case TokenWhileStatement:
 		return p.ParseWhileStatement()
+	case TokenMatchStatement:
+		return p.ParseMatchStatement()
 	case TokenEOF:
 		return nil
 	default:
-		p.ThrowSyntaxError("Unexpected token \"", token.Literal, "\".")
+		p.ThrowSyntaxError("Unexpected ", token.describe(), ".")
 	}
 	// Return statement to make go happy even though it's unreachable since the throw will exit
 	return nil
 }
 
 // Parses a code block enclosed in {} into it's own AST
-func (p *Parser) ParseBlock(scopedVariables map[string]TypeDef, returnType TypeDef) *nodes.Block {
+// An identifier declared in the scope of a block before it's statements such as a function argument,
+// these are given the first slots of the block's environment in order
+type scopedVariable struct {
+	name string
+	def  TypeDef
+	// Where the variable is declared and what declares it, which are only known when the program is being analysed
+	position environment.Position
+	kind     DeclarationKind
+}
+
+func (p *Parser) ParseBlock(scopedVariables []scopedVariable, returnType TypeDef) *nodes.Block {
 	ast := make([]environment.Node, 0)
 	p.ExpectToken(TokenLeftBrace)
 
 	p.currentTypeEnv = p.currentTypeEnv.NewChild(returnType)
-	for name, valType := range scopedVariables {
-		p.currentTypeEnv.Set(name, valType)
-	}
+	p.beginScope()
+	p.declareScopedVariables(scopedVariables)
 
 	for {
-		token := p.ParseNext(true)
-		if token == nil {
+		node, more := p.parseNextRecovering(true)
+		if !more {
 			break
 		}
-		ast = append(ast, token)
+		if node != nil {
+			ast = append(ast, node)
+		}
 	}
 
+	// The block has been parsed so the error doesn't need to abandon the statement the block is in
 	if returnType != nil && !p.currentTypeEnv.GetReturned() {
-		p.ThrowTypeError("The function is missing a return statement.")
+		p.reportError(DiagnosticTypeError, "The function is missing a return statement.")
 	}
 
+	p.endScope()
 	p.currentTypeEnv = p.currentTypeEnv.GetParent()
 	return &nodes.Block{Nodes: ast}
 }
 
+// Collects the signatures of top-level functions and the shapes of top-level structs before the program is parsed
+// so that the order they are declared in doesn't matter
+func (p *Parser) hoistDeclarations() {
+	start := p.lexer.SavePos()
+	p.hoisting = true
+
+	// Types and structs are collected first since function signatures can use them.
+	// Types are collected without their methods first since the signatures of methods can use other types.
+	for _, withMethods := range []bool{false, true} {
+		p.forEachTopLevelDeclaration(TokenTypeDeclaration, func() {
+			line := p.lexer.GetCurrentLine()
+			name, def, _ := p.parseTypeDeclarationShape(withMethods)
+			p.currentTypeEnv.SetImmutable(name, def, line)
+		})
+		start.GoTo()
+	}
+	p.forEachTopLevelDeclaration(TokenStructDeclaration, func() {
+		line := p.lexer.GetCurrentLine()
+		name, def, _ := p.parseStructShape()
+		p.currentTypeEnv.SetImmutable(name, def, line)
+	})
+	start.GoTo()
+	p.forEachTopLevelDeclaration(TokenFunctionDeclaration, func() {
+		line := p.lexer.GetCurrentLine()
+		name, def, _ := p.ParseFunctionDef()
+		p.currentTypeEnv.SetImmutable(name, def, line)
+		p.declare(name, DeclarationFunction, p.functionName, def)
+		p.skipBlock()
+	})
+
+	p.hoisting = false
+	start.GoTo()
+}
+
+// Reads through the rest of the program calling parse each time a statement at the top-level starts with the token type
+func (p *Parser) forEachTopLevelDeclaration(tokenType TokenType, parse func()) {
+	depth := 0
+	statementStart := true
+	for {
+		// Tokens that can't be read are reported when the program is parsed
+		token, err := p.lexer.Next()
+		if err != nil {
+			continue
+		}
+		switch token.Type {
+		case TokenEOF:
+			return
+		case TokenLeftBrace:
+			depth++
+		case TokenRightBrace:
+			depth--
+		case tokenType:
+			if depth == 0 && statementStart {
+				p.hoistDeclaration(parse)
+				statementStart = false
+				continue
+			}
+		}
+		statementStart = token.Type == TokenNewLine || token.Type == TokenSemiColon
+	}
+}
+
+// Parses a top-level declaration while hoisting, recording a diagnostic if it has an error so that the rest of the
+// program can still be hoisted
+func (p *Parser) hoistDeclaration(parse func()) {
+	typeEnv := p.currentTypeEnv
+	defer func() {
+		if r := recover(); r != nil {
+			p.recoverError(r)
+			p.currentTypeEnv = typeEnv
+		}
+	}()
+	parse()
+}
+
+// Skips over a code block enclosed in {} without parsing it
+func (p *Parser) skipBlock() {
+	p.ExpectToken(TokenLeftBrace)
+	for depth := 1; depth > 0; {
+		switch p.lexer.MustNext().Type {
+		case TokenLeftBrace:
+			depth++
+		case TokenRightBrace:
+			depth--
+		case TokenEOF:
+			p.ThrowSyntaxError("Reached end of file before the end of the code block.")
+		}
+	}
+}
+
+// Creates a node that gets the value of an identifier from the slot it was declared in
+// Sets the span of a node from a start position to the end of the last token that was read
+func (p *Parser) span(node environment.Node, start environment.Position) environment.Node {
+	if positioned, ok := node.(environment.Positioned); ok {
+		end := p.lexer.LastToken()
+		positioned.SetPosition(environment.Position{
+			Line:      start.Line,
+			Column:    start.Column,
+			EndLine:   end.Line,
+			EndColumn: end.EndColumn,
+		})
+	}
+	return node
+}
+
+// Gets the position a node starts at, which is unknown for nodes that don't have a position
+func startOf(node environment.Node) environment.Position {
+	if positioned, ok := node.(environment.Positioned); ok {
+		return positioned.GetPosition()
+	}
+	return environment.Position{}
+}
+
+func (p *Parser) newIdentifier(name string) *nodes.Identifier {
+	slot, depth := p.currentTypeEnv.GetSlot(name)
+	return &nodes.Identifier{Name: name, Slot: slot, Depth: depth}
+}
+
+// Throws a type error if an identifier cannot be reassigned
+func (p *Parser) checkAssignable(name string) {
+	if line, ok := p.currentTypeEnv.GetImmutableLine(name); ok && line == 0 {
+		p.ThrowTypeError("Cannot assign to \"", name, "\" since it is a global, which is immutable.")
+	} else if ok {
+		p.ThrowTypeError("Cannot assign to \"", name, "\" since it is immutable, it was declared at line ", line, ".")
+	}
+}
+
+// Throws a type error if an identifier is already declared in the current scope and cannot be reassigned
+func (p *Parser) checkRedeclarable(name string) {
+	if line, ok := p.currentTypeEnv.getLocalImmutableLine(name); ok && line == 0 {
+		p.ThrowTypeError("Cannot declare \"", name, "\" since it is already declared as a global, which is immutable.")
+	} else if ok {
+		p.ThrowTypeError("Cannot declare \"", name, "\" since it is already declared as immutable at line ", line, ".")
+	}
+}
+
 func (p *Parser) ExpectToken(tokenType ...TokenType) Token {
-	token := p.lexer.NextOrExit()
+	token := p.lexer.MustNext()
 	for _, allowedType := range tokenType {
 		if token.Type == allowedType {
 			return token
 		}
 	}
-	p.ThrowSyntaxError("Unexpected token \"", token.Literal, "\".")
+	p.ThrowSyntaxError("Unexpected ", token.describe(), ".")
 	return Token{}
 }
 
-// Displays a formatted syntax error message
+// Records a syntax error at the last token that was read and abandons the statement being parsed
 func (p *Parser) ThrowSyntaxError(msg ...any) {
-	fmt.Println(aurora.Red("[ERROR]"), aurora.Gray(5, "Syntax error at line "+fmt.Sprint(p.lexer.GetCurrentLine())+":"))
-	fmt.Println(" ", aurora.Gray(3, ">"), aurora.Red(fmt.Sprint(msg...)))
-	fmt.Println(" ", aurora.Gray(18, "in "+p.filePath))
-	os.Exit(1)
+	p.throw(DiagnosticSyntaxError, msg...)
 }
 
-// Displays a formatted type error message
+// Records a type error at the last token that was read and abandons the statement being parsed
 func (p *Parser) ThrowTypeError(msg ...any) {
-	fmt.Println(aurora.Red("[ERROR]"), aurora.Gray(5, "Type error at line "+fmt.Sprint(p.lexer.GetCurrentLine())+":"))
-	fmt.Println(" ", aurora.Gray(3, ">"), aurora.Red(fmt.Sprint(msg...)))
-	fmt.Println(" ", aurora.Gray(18, "in "+p.filePath))
-	os.Exit(1)
+	p.throw(DiagnosticTypeError, msg...)
+}
+
+func (p *Parser) throw(code DiagnosticCode, msg ...any) {
+	p.reportError(code, msg...)
+	panic(parseError{})
+}
+
+// Records a warning at the last token that was read, which doesn't stop the program from being run
+func (p *Parser) reportWarning(code DiagnosticCode, msg ...any) {
+	p.addDiagnostic(Diagnostic{
+		Position: p.lexer.LastToken().Position(),
+		Code:     code,
+		Severity: SeverityWarning,
+		Message:  fmt.Sprint(msg...),
+	})
+}
+
+// Records an error at the last token that was read without abandoning the statement being parsed
+func (p *Parser) reportError(code DiagnosticCode, msg ...any) {
+	token := p.lexer.LastToken()
+	p.addDiagnostic(Diagnostic{
+		Position: token.Position(),
+		Code:     code,
+		Severity: SeverityError,
+		Message:  fmt.Sprint(msg...),
+	})
 }
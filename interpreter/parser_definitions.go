@@ -1,52 +1,208 @@
 package interpreter
 
-import "strconv"
+import (
+	"main/interpreter/environment"
+	"strconv"
+)
 
-func (p *Parser) ParseFunctionDef() (name string, argDefs []TypeDef, argNames []string, returnType TypeDef) {
-	name = p.ExpectToken(TokenIdentifier).Literal
+// Parses the name, arguments and return type of a function.
+// Default values are returned in the order of the arguments, with nil for arguments that don't have one.
+func (p *Parser) ParseFunctionDef() (name string, def FuncDef, defaults []environment.Node) {
+	nameToken := p.ExpectToken(TokenIdentifier)
+	name = nameToken.Literal
 	p.ExpectToken(TokenLeftBracket)
+	argPositions := make([]environment.Position, 0)
+	// The positions are set once the signature has been parsed, since argument types can include other signatures
+	defer func() {
+		p.functionName, p.argPositions = nameToken.Position(), argPositions
+	}()
 
-	argDefs = make([]TypeDef, 0)
-	argNames = make([]string, 0)
+	def = NewFuncDef(make([]TypeDef, 0), false, nil)
+	def.ArgNames = make([]string, 0)
+	defaults = make([]environment.Node, 0)
 	for i := 0; ; i++ {
 		token := p.ExpectToken(TokenIdentifier, TokenRightBracket)
 		if token.Type == TokenRightBracket {
 			break
 		}
+		if def.Variadic {
+			p.ThrowSyntaxError("A variadic argument must be the last argument of a function.")
+		}
 
 		p.ExpectToken(TokenColon)
-		argDefs = append(argDefs, p.ParseTypeDef())
-		argNames = append(argNames, token.Literal)
+		// Check for ... which marks the argument as variadic
+		if p.lexer.MustPeek().Type == TokenPeriod {
+			p.ExpectToken(TokenPeriod)
+			p.ExpectToken(TokenPeriod)
+			p.ExpectToken(TokenPeriod)
+			def.Variadic = true
+		}
+		argDef := p.ParseTypeDef()
+		def.Args = append(def.Args, argDef)
+		def.ArgNames = append(def.ArgNames, token.Literal)
+		argPositions = append(argPositions, token.Position())
+
+		token = p.ExpectToken(TokenComma, TokenRightBracket, TokenEquals)
+		if token.Type == TokenEquals && p.hoisting {
+			// Default values are only parsed once the parser reaches the function, since they can use values
+			// that have not been declared yet whilst the top-level declarations are being collected
+			p.skipDefaultValue()
+			defaults = append(defaults, nil)
+			def.OptionalArgs++
+			token = p.ExpectToken(TokenComma, TokenRightBracket)
+		} else if token.Type == TokenEquals {
+			if def.Variadic {
+				p.ThrowSyntaxError("A variadic argument cannot have a default value.")
+			}
+			defaultVal, defaultDef := p.ParseValue(argDef)
+			if defaultDef == nil || !defaultDef.Equals(argDef) {
+				p.ThrowTypeError("Incorrect type of default value for argument \"", def.ArgNames[i], "\".")
+			}
+			defaults = append(defaults, defaultVal)
+			def.OptionalArgs++
+			token = p.ExpectToken(TokenComma, TokenRightBracket)
+		} else {
+			if def.OptionalArgs > 0 && !def.Variadic {
+				p.ThrowSyntaxError("Argument \"", def.ArgNames[i], "\" must have a default value since it follows an argument with a default value.")
+			}
+			defaults = append(defaults, nil)
+		}
 
-		token = p.ExpectToken(TokenComma, TokenRightBracket)
 		if token.Type == TokenRightBracket {
 			break
 		}
 	}
 
-	token := p.lexer.NextOrExit()
+	token := p.lexer.MustNext()
 	if token.Type == TokenColon {
-		returnType = p.ParseTypeDef()
+		def.ReturnType = p.ParseTypeDef()
 	} else {
 		p.lexer.Unread(token)
 	}
 	return
 }
 
+// Skips over the default value of an argument up to the comma or bracket that ends it
+func (p *Parser) skipDefaultValue() {
+	depth := 0
+	for {
+		token := p.lexer.MustNext()
+		switch token.Type {
+		case TokenLeftBracket, TokenLeftSquareBracket, TokenLeftBrace:
+			depth++
+		case TokenRightBracket, TokenRightSquareBracket, TokenRightBrace:
+			depth--
+		case TokenEOF:
+			p.ThrowSyntaxError("Reached end of file before the end of the function arguments.")
+		}
+		if depth < 0 || (depth == 0 && token.Type == TokenComma) {
+			p.lexer.Unread(token)
+			return
+		}
+	}
+}
+
+// Gets the types of arguments as they are declared in the function body, where a variadic argument is an array
+func (def FuncDef) getArgScope() []scopedVariable {
+	args := make([]scopedVariable, len(def.Args))
+	for i, name := range def.ArgNames {
+		args[i] = scopedVariable{name: name, def: def.Args[i]}
+	}
+	if def.Variadic {
+		args[len(args)-1].def = NewArrayDef(def.Args[len(def.Args)-1], -1)
+	}
+	return args
+}
+
+// Parses a type definition, including unions of types separated by | such as int64 | string
 func (p *Parser) ParseTypeDef() TypeDef {
+	def := p.parseSingleTypeDef()
+
+	types := []TypeDef{def}
+	for {
+		token := p.lexer.MustNext()
+		if token.Type != TokenBar {
+			p.lexer.Unread(token)
+			break
+		}
+		// A second bar is the || operator which ends the type definition
+		if p.lexer.MustPeek().Type == TokenBar {
+			p.lexer.Unread(token)
+			break
+		}
+		types = append(types, p.parseSingleTypeDef())
+	}
+	if len(types) == 1 {
+		return def
+	}
+	return p.newUnionDef(types)
+}
+
+// Creates a union of definitions, checking that the types of it's values can be told apart at runtime
+func (p *Parser) newUnionDef(types []TypeDef) UnionDef {
+	memberDefs := make([]TypeDef, 0, len(types))
+	for _, def := range types {
+		// Unions inside of unions are flattened in to a single union
+		if unionDef, ok := def.(UnionDef); ok {
+			memberDefs = append(memberDefs, unionDef.Types...)
+		} else {
+			memberDefs = append(memberDefs, def)
+		}
+	}
+
+	for i, def := range memberDefs {
+		if def.GetGenericType() == TypeAny {
+			p.ThrowTypeError("A union cannot contain the any type.")
+		}
+		for _, otherDef := range memberDefs[:i] {
+			if !isDistinctAtRuntime(def, otherDef) {
+				p.ThrowTypeError("Types in a union must be stored as different types at runtime, so they can be told apart.")
+			}
+		}
+	}
+	return NewUnionDef(memberDefs)
+}
+
+// Parses a type definition that isn't a union
+func (p *Parser) parseSingleTypeDef() TypeDef {
 	// Expect a token of a type
-	token := p.ExpectToken(TokenTypeInt8, TokenTypeInt16, TokenTypeInt32, TokenTypeInt64, TokenTypeUint8, TokenTypeUint16, TokenTypeUint32, TokenTypeUint64, TokenTypeFloat32, TokenTypeFloat64, TokenTypeString, TokenTypeBool, TokenTypeMap, TokenLeftSquareBracket, TokenFunctionDeclaration)
+	token := p.ExpectToken(TokenTypeInt8, TokenTypeInt16, TokenTypeInt32, TokenTypeInt64, TokenTypeUint8, TokenTypeUint16, TokenTypeUint32, TokenTypeUint64, TokenTypeFloat32, TokenTypeFloat64, TokenTypeString, TokenTypeBool, TokenTypeMap, TokenTypeAny, TokenLeftSquareBracket, TokenFunctionDeclaration, TokenIdentifier, TokenLeftBracket)
 
 	switch token.Type {
+	case TokenLeftBracket:
+		// Brackets allow for unions to be used as the type of array elements or map values
+		def := p.ParseTypeDef()
+		p.ExpectToken(TokenRightBracket)
+		return def
+
+	case TokenIdentifier:
+		def := p.lookup(token)
+		switch def := def.(type) {
+		case StructDef:
+			if def.Type == TypeStruct {
+				return def.InstanceDef()
+			}
+		case TypeDeclarationDef:
+			return def.Def
+		}
+		p.ThrowTypeError(token.Literal, " is not a type.")
+
+	case TokenTypeAny:
+		return GenericTypeDef{TypeAny}
+
 	case TokenFunctionDeclaration:
-		_, argDefs, _, returnType := p.ParseFunctionDef()
-		return NewFuncDef(argDefs, false, returnType)
+		_, def, _ := p.ParseFunctionDef()
+		// Functions are called with the default values of the function being called, which may not have any
+		if def.OptionalArgs > 0 {
+			p.reportError(DiagnosticTypeError, "The arguments of a function type cannot have default values.")
+		}
+		return def
 
 	case TokenTypeMap:
 		p.ExpectToken(TokenLeftSquareBracket)
 		keyType := p.ParseTypeDef()
 		p.ExpectToken(TokenRightSquareBracket)
-		valueType := p.ParseTypeDef()
+		valueType := p.parseSingleTypeDef()
 
 		return NewMapDef(keyType, valueType)
 
@@ -60,7 +216,7 @@ func (p *Parser) ParseTypeDef() TypeDef {
 			}
 			p.ExpectToken(TokenRightSquareBracket)
 		}
-		return NewArrayDef(p.ParseTypeDef(), size)
+		return NewArrayDef(p.parseSingleTypeDef(), size)
 	}
 
 	return GenericTypeDef{
@@ -5,16 +5,26 @@ import (
 	"main/interpreter/nodes"
 )
 
-func (p *Parser) ParseVarDeclaration() environment.Node {
-	token := p.ExpectToken(TokenIdentifier)
+// Parses a variable declaration, if immutable is true the variable is declared with let and cannot be reassigned.
+// Variables don't have a zero value, so a declaration without a value (let y int64) is an error.
+func (p *Parser) ParseVarDeclaration(immutable bool) environment.Node {
+	line := p.lexer.GetCurrentLine()
+	token := p.ExpectToken(TokenIdentifier, TokenLeftSquareBracket, TokenLeftBrace)
+	if token.Type != TokenIdentifier {
+		return p.ParseDestructuringDeclaration(token, immutable)
+	}
+	identifierToken := token
 	identifier := token.Literal
+	p.checkRedeclarable(identifier)
 
-	token = p.lexer.NextOrExit()
+	token = p.lexer.MustNext()
 	var typeDef TypeDef = GenericTypeDef{TypeNil}
 	if token.Type != TokenEquals {
 		p.lexer.Unread(token) // Unread token so it can be parsed as the type
 		typeDef = p.ParseTypeDef()
-		p.ExpectToken(TokenEquals)
+		if token := p.lexer.MustNext(); token.Type != TokenEquals {
+			p.ThrowSyntaxError("Expected a value for ", identifier, ", since variables must be given a value when they are declared.")
+		}
 	}
 
 	valNode, valType := p.ParseValue(typeDef)
@@ -22,54 +32,219 @@ func (p *Parser) ParseVarDeclaration() environment.Node {
 		p.ThrowTypeError("Incorrect type of value on right hand side of variable declaration.")
 	}
 
-	p.currentTypeEnv.Set(identifier, valType)
+	// If a type is declared it is used for the variable, since it can be broader than the value (such as a union)
+	if typeDef.GetGenericType() != TypeNil {
+		valType = typeDef
+	}
+	if immutable {
+		p.currentTypeEnv.SetImmutable(identifier, valType, line)
+	} else {
+		p.currentTypeEnv.Set(identifier, valType)
+	}
+	p.declare(identifier, DeclarationVariable, identifierToken.Position(), valType)
 
+	slot, _ := p.currentTypeEnv.GetSlot(identifier)
 	return &nodes.Assignment{
 		Identifier: identifier,
+		Slot:       slot,
 		NewValue:   valNode,
 		Depth:      0,
 	}
 }
 
-func (p *Parser) ParseFunctionDeclaration() environment.Node {
-	funcName, argDefs, argNames, returnType := p.ParseFunctionDef()
+// Parses a variable declaration that declares a variable for each element of a fixed size array (var [a, b] = pair)
+// or for properties of a struct by their names (var {name, age} = pet). _ can be used to skip an element.
+func (p *Parser) ParseDestructuringDeclaration(openingToken Token, immutable bool) environment.Node {
+	line := p.lexer.GetCurrentLine()
+	closingTokenType := TokenRightBrace
+	if openingToken.Type == TokenLeftSquareBracket {
+		closingTokenType = TokenRightSquareBracket
+	}
 
-	p.currentTypeEnv.Set(funcName, NewFuncDef(
-		argDefs,
-		false,
-		returnType,
-	))
+	names := make([]string, 0)
+	positions := make([]environment.Position, 0)
+	for {
+		token := p.ExpectToken(TokenIdentifier)
+		names = append(names, token.Literal)
+		positions = append(positions, token.Position())
+		if token := p.ExpectToken(TokenComma, closingTokenType); token.Type == closingTokenType {
+			break
+		}
+	}
+	p.ExpectToken(TokenEquals)
+	val, def := p.ParseValue(nil)
+
+	var generator TypeNodeGenerator
+	indexes := make([]int, len(names))
+	defs := make([]TypeDef, len(names))
+	if openingToken.Type == TokenLeftSquareBracket {
+		arrayDef, ok := def.(ArrayDef)
+		if !ok {
+			p.ThrowTypeError("Only arrays can be destructured with [].")
+		} else if arrayDef.Size == -1 {
+			p.ThrowTypeError("Only arrays with a fixed size can be destructured, such as [2]int64.")
+		} else if arrayDef.Size != len(names) {
+			p.ThrowTypeError("Cannot destructure an array of size ", arrayDef.Size, " in to ", len(names), " variables.")
+		}
+		generator = GetGenericTypeNode(arrayDef.ElementType)
+		if generator == nil {
+			p.ThrowTypeError("Arrays of this type cannot be destructured.")
+		}
+		for i := range names {
+			indexes[i] = i
+			defs[i] = arrayDef.ElementType
+		}
+	} else {
+		structDef, ok := def.(StructDef)
+		if !ok || structDef.Type != TypeStructInstance {
+			p.ThrowTypeError("Only structs can be destructured with {}.")
+		}
+		// Struct instances are arrays of their properties
+		generator = TypeNodeGeneratorAny[any]{}
+		for i, name := range names {
+			index, ok := structDef.Properties[name]
+			if !ok {
+				p.ThrowTypeError("Property ", name, " does not exist on struct ", structDef.Name, ".")
+			} else if index >= structDef.DataProperties {
+				p.ThrowTypeError("Methods cannot be destructured from a struct.")
+			}
+			indexes[i] = index
+			defs[i] = structDef.PropertyDefs[index]
+		}
+	}
+
+	identifiers := make([]string, 0, len(names))
+	slots := make([]int, 0, len(names))
+	identifierIndexes := make([]int, 0, len(names))
+	for i, name := range names {
+		if name == "_" {
+			continue
+		}
+		for _, identifier := range identifiers {
+			if identifier == name {
+				p.ThrowSyntaxError("Variable \"", name, "\" is declared more than once.")
+			}
+		}
+		p.checkRedeclarable(name)
+		if immutable {
+			p.currentTypeEnv.SetImmutable(name, defs[i], line)
+		} else {
+			p.currentTypeEnv.Set(name, defs[i])
+		}
+		p.declare(name, DeclarationVariable, positions[i], defs[i])
+		slot, _ := p.currentTypeEnv.GetSlot(name)
+		identifiers = append(identifiers, name)
+		slots = append(slots, slot)
+		identifierIndexes = append(identifierIndexes, indexes[i])
+	}
 
-	args := make(map[string]TypeDef, len(argDefs))
-	for i, name := range argNames {
-		args[name] = argDefs[i]
+	return generator.GetDestructure(identifiers, slots, identifierIndexes, val)
+}
+
+// Parses an assignment of multiple values to multiple variables (a, b = b, a).
+// All of the values are evaluated before any are assigned, so variables can be swapped.
+func (p *Parser) ParseMultiAssignment(firstToken Token) environment.Node {
+	identifierTokens := []Token{firstToken}
+	for {
+		if token := p.ExpectToken(TokenComma, TokenEquals); token.Type == TokenEquals {
+			break
+		}
+		identifierTokens = append(identifierTokens, p.ExpectToken(TokenIdentifier))
 	}
-	inner := p.ParseBlock(args, returnType)
+	identifiers := make([]string, len(identifierTokens))
+	for i, token := range identifierTokens {
+		identifiers[i] = token.Literal
+	}
+
+	node := &nodes.MultiAssignment{
+		Identifiers: identifiers,
+		Slots:       make([]int, len(identifiers)),
+		Depths:      make([]int, len(identifiers)),
+		Values:      make([]environment.Node, len(identifiers)),
+	}
+	for i, identifier := range identifiers {
+		for _, otherIdentifier := range identifiers[:i] {
+			if otherIdentifier == identifier {
+				p.ThrowSyntaxError("Variable \"", identifier, "\" is assigned more than once.")
+			}
+		}
+		def := p.lookup(identifierTokens[i])
+		if def == nil {
+			p.ThrowTypeError(identifier, " is not defined in this scope.")
+		}
+		p.checkAssignable(identifier)
 
+		if i != 0 {
+			p.ExpectToken(TokenComma)
+		}
+		val, valDef := p.ParseValue(def)
+		if valDef == nil || !valDef.Equals(def) {
+			p.ThrowTypeError("Cannot assign new type to variable \"", identifier, "\".")
+		}
+		node.Values[i] = val
+		node.Slots[i], node.Depths[i] = p.currentTypeEnv.GetSlot(identifier)
+	}
+	return node
+}
+
+func (p *Parser) ParseFunctionDeclaration() environment.Node {
+	line := p.lexer.GetCurrentLine()
+	funcName, funcDef, defaults := p.ParseFunctionDef()
+
+	p.currentTypeEnv.SetImmutable(funcName, funcDef, line)
+	declaration := p.declare(funcName, DeclarationFunction, p.functionName, funcDef)
+
+	inner := p.ParseBlock(argScope(funcDef, p.argPositions), funcDef.ReturnType)
+	p.endDeclaration(declaration)
+
+	slot, _ := p.currentTypeEnv.GetSlot(funcName)
 	return &nodes.FuncDeclaration{
-		Name:     funcName,
-		ArgNames: argNames,
-		Inner:    inner,
-		Line:     p.lexer.GetCurrentLine(),
+		Name:         funcName,
+		Slot:         slot,
+		ArgNames:     funcDef.ArgNames,
+		Defaults:     defaults,
+		PackVariadic: p.getVariadicPacker(funcDef),
+		Inner:        inner,
+		Position:     environment.Position{Line: p.lexer.GetCurrentLine()},
 	}
 }
 
+// Packs variadic arguments in to an array of the generic type of the last argument, the generic type is kept so that
+// the packer can be cached
+type variadicPacker struct {
+	TypeNodeGenerator
+	elementType GenericType
+}
+
+// Gets what packs the variadic arguments of a function in to an array at runtime
+func (p *Parser) getVariadicPacker(def FuncDef) nodes.VariadicPacker {
+	if !def.Variadic {
+		return nil
+	}
+	elementType := def.Args[len(def.Args)-1].GetGenericType()
+	generator := GetGenericTypeNode(GenericTypeDef{elementType})
+	if generator == nil {
+		p.ThrowTypeError("Variadic arguments of this type are not supported.")
+	}
+	return &variadicPacker{generator, elementType}
+}
+
 func (p *Parser) ParseIfStatement() environment.Node {
 	val, valDef := p.ParseValue(nil)
 	if !valDef.Equals(GenericTypeDef{TypeBool}) {
 		p.ThrowTypeError("If statement must be followed by a bool value.")
 	}
-	inner := p.ParseBlock(make(map[string]TypeDef), nil)
+	inner := p.ParseBlock(nil, nil)
 
 	var elseNode environment.Node
 	// Check for else statement
-	if token := p.lexer.NextOrExit(); token.Type == TokenElseStatement {
+	if token := p.lexer.MustNext(); token.Type == TokenElseStatement {
 		token = p.ExpectToken(TokenIfStatement, TokenLeftBrace)
 		if token.Type == TokenIfStatement {
 			elseNode = p.ParseIfStatement()
 		} else {
 			p.lexer.Unread(token)
-			elseNode = p.ParseBlock(make(map[string]TypeDef), nil)
+			elseNode = p.ParseBlock(nil, nil)
 		}
 	} else {
 		p.lexer.Unread(token)
@@ -83,35 +258,44 @@ func (p *Parser) ParseIfStatement() environment.Node {
 }
 
 func (p *Parser) ParseImportStatement() environment.Node {
-	module := p.ExpectToken(TokenString).Literal
+	moduleToken := p.ExpectToken(TokenString)
+	module := moduleToken.Literal
 
 	moduleDef := p.modules[module]
 	if moduleDef == nil {
 		p.ThrowSyntaxError("Module \"", module, "\" does not exist")
 	}
+	line := p.lexer.GetCurrentLine()
 	identifier := module
-	if token := p.lexer.NextOrExit(); token.Type == TokenAsStatement {
-		identifier = p.ExpectToken(TokenIdentifier).Literal
+	position := moduleToken.Position()
+	if token := p.lexer.MustNext(); token.Type == TokenAsStatement {
+		identifierToken := p.ExpectToken(TokenIdentifier)
+		identifier, position = identifierToken.Literal, identifierToken.Position()
 	} else {
 		p.lexer.Unread(token)
 	}
 
-	p.currentTypeEnv.Set(identifier, NewModuleDef(moduleDef))
+	p.currentTypeEnv.SetImmutable(identifier, NewModuleDef(moduleDef), line)
+	p.declare(identifier, DeclarationImport, position, NewModuleDef(moduleDef))
+	slot, _ := p.currentTypeEnv.GetSlot(identifier)
 	return &nodes.Import{
 		Module:     module,
 		Identifier: identifier,
+		Slot:       slot,
 	}
 }
 
 func (p *Parser) ParseForStatement() environment.Node {
 	// Should support commas i.e. v, i range ["a", "b"] (left side value, right side index)
-	valIdent := p.ExpectToken(TokenIdentifier).Literal
+	valToken := p.ExpectToken(TokenIdentifier)
+	valIdent := valToken.Literal
 
-	indexIdent := ""
+	indexToken := Token{}
 	if token := p.ExpectToken(TokenComma, TokenRangeStatement); token.Type == TokenComma {
-		indexIdent = p.ExpectToken(TokenIdentifier).Literal
+		indexToken = p.ExpectToken(TokenIdentifier)
 		p.ExpectToken(TokenRangeStatement)
 	}
+	indexIdent := indexToken.Literal
 
 	iterableValue, def := p.ParseValue(nil)
 
@@ -135,7 +319,7 @@ func (p *Parser) ParseForStatement() environment.Node {
 			ValIdentifier: valIdent,
 			Start:         startVal,
 			End:           endVal,
-			Inner:         p.ParseBlock(map[string]TypeDef{valIdent: GenericTypeDef{TypeInt64}}, nil),
+			Inner:         p.ParseBlock([]scopedVariable{{valIdent, GenericTypeDef{TypeInt64}, valToken.Position(), DeclarationVariable}}, nil),
 		}
 	}
 
@@ -144,7 +328,10 @@ func (p *Parser) ParseForStatement() environment.Node {
 		p.ThrowTypeError("Right hand side of range loop must either be an integer or array.")
 	}
 	return GetGenericTypeNode(arrayDef.ElementType).GetLoopArray(valIdent, indexIdent, iterableValue, p.ParseBlock(
-		map[string]TypeDef{valIdent: arrayDef.ElementType, indexIdent: GenericTypeDef{Type: TypeInt64}},
+		[]scopedVariable{
+			{valIdent, arrayDef.ElementType, valToken.Position(), DeclarationVariable},
+			{indexIdent, GenericTypeDef{Type: TypeInt64}, indexToken.Position(), DeclarationVariable},
+		},
 		nil,
 	))
 }
@@ -156,6 +343,74 @@ func (p *Parser) ParseWhileStatement() environment.Node {
 	}
 	return &nodes.LoopWhile{
 		Condition: val,
-		Inner:     p.ParseBlock(map[string]TypeDef{}, nil),
+		Inner:     p.ParseBlock(nil, nil),
+	}
+}
+
+// Parses a match statement which runs the arm for the type of a value that is only known at runtime.
+// Each arm declares a variable that holds the value as the type of the arm, or _ can be used as the default arm.
+func (p *Parser) ParseMatchStatement() environment.Node {
+	val, def := p.ParseValue(nil)
+	p.ExpectToken(TokenLeftBrace)
+
+	node := &nodes.TypeSwitch{
+		Value: val,
+		Cases: make([]nodes.TypeSwitchCase, 0),
+	}
+	for {
+		token := p.ExpectToken(TokenIdentifier, TokenNewLine, TokenComma, TokenRightBrace)
+		if token.Type == TokenNewLine || token.Type == TokenComma {
+			continue
+		} else if token.Type == TokenRightBrace {
+			break
+		}
+
+		if token.Literal == "_" {
+			if node.Default != nil {
+				p.ThrowSyntaxError("A match statement can only have one default arm.")
+			}
+			p.ExpectToken(TokenEquals)
+			p.ExpectToken(TokenGreaterThan)
+			node.Default = p.parseMatchArm(nil)
+			continue
+		}
+
+		p.ExpectToken(TokenColon)
+		caseDef := p.ParseTypeDef()
+		if !canBeOfType(def, caseDef) {
+			p.ThrowTypeError("Value in match statement can never be of the type of this arm.")
+		}
+		if def.GetGenericType() == TypeAny && !isIdentifiableAtRuntime(caseDef) {
+			// The rest of the statement can still be parsed
+			p.reportError(DiagnosticTypeError, "Values of type any can't be checked against structs, named types, functions, or arrays and maps of any or unions, since they can't be told apart at runtime.")
+		}
+		p.ExpectToken(TokenEquals)
+		p.ExpectToken(TokenGreaterThan)
+		node.Cases = append(node.Cases, nodes.TypeSwitchCase{
+			Identifier: token.Literal,
+			Type:       newRuntimeTypeCheck(caseDef),
+			Inner:      p.parseMatchArm([]scopedVariable{{token.Literal, caseDef, token.Position(), DeclarationParameter}}),
+		})
+	}
+	return node
+}
+
+// Parses the body of a match arm, which is either a block or a single statement
+func (p *Parser) parseMatchArm(scopedVariables []scopedVariable) *nodes.Block {
+	if token := p.lexer.MustPeek(); token.Type == TokenLeftBrace {
+		return p.ParseBlock(scopedVariables, nil)
+	}
+
+	p.currentTypeEnv = p.currentTypeEnv.NewChild(nil)
+	p.beginScope()
+	p.declareScopedVariables(scopedVariables)
+	statement := p.parseStatement(p.lexer.MustNext())
+	p.endScope()
+	p.currentTypeEnv = p.currentTypeEnv.GetParent()
+
+	// The statement can be ended by the end of the line, a comma before the next arm or the end of the match statement
+	if token := p.ExpectToken(TokenNewLine, TokenComma, TokenRightBrace); token.Type == TokenRightBrace {
+		p.lexer.Unread(token)
 	}
+	return &nodes.Block{Nodes: []environment.Node{statement}}
 }
@@ -12,122 +12,191 @@ import (
 // This then creates an array with the number of elements of the number of properties and methods.
 // The parser has to match property and method names to the index that they are expected to be at during runtime
 
-type structMethodDeclaration struct {
+type methodDeclaration struct {
 	name         string
 	def          FuncDef
-	argNames     []string
-	args         map[string]TypeDef
+	defaults     []environment.Node
 	codeBlockPos LexerPos
+	// The declaration of the method and the positions of it's arguments, when the program is being analysed
+	declaration  *Declaration
+	argPositions []environment.Position
 }
 
 func (p *Parser) ParseStructDeclaration() environment.Node {
-	name := p.ExpectToken(TokenIdentifier).Literal
+	line := p.lexer.GetCurrentLine()
+	name, def, methodDeclarations := p.parseStructShape()
+
+	// The struct is set before the methods are parsed so that methods can create new instances of it
+	p.currentTypeEnv.SetImmutable(name, def, line)
+
+	slot, _ := p.currentTypeEnv.GetSlot(name)
+	return &nodes.StructDeclaration{
+		Name:    name,
+		Slot:    slot,
+		Methods: p.parseMethods(methodDeclarations, def.InstanceDef()),
+	}
+}
+
+// Parses the signature of a method, skipping the body so that it can be parsed after the rest of the declaration
+func (p *Parser) parseMethodDeclaration() methodDeclaration {
+	methodName, funcDef, defaults := p.ParseFunctionDef()
+	declaration := methodDeclaration{
+		name:         methodName,
+		def:          funcDef,
+		defaults:     defaults,
+		codeBlockPos: p.lexer.SavePos(),
+		declaration:  p.declareMember(methodName, DeclarationMethod, p.functionName, funcDef),
+		argPositions: p.argPositions,
+	}
+	p.skipBlock()
+	p.endDeclaration(declaration.declaration)
+	return declaration
+}
+
+// Parses the bodies of methods, methods are called with the value they belong to as their first argument
+func (p *Parser) parseMethods(methodDeclarations []methodDeclaration, selfDef TypeDef) []environment.Node {
+	methods := make([]environment.Node, len(methodDeclarations))
+	for i, methodDeclaration := range methodDeclarations {
+		revertPos := methodDeclaration.codeBlockPos.GoTo()
+
+		args := append([]scopedVariable{{name: "self", def: selfDef}}, argScope(methodDeclaration.def, methodDeclaration.argPositions)...)
+		innerBlock := p.ParseBlock(args, methodDeclaration.def.ReturnType)
+		methods[i] = &nodes.FuncDeclaration{
+			Name:         methodDeclaration.name,
+			IsMethod:     true,
+			Position:     environment.Position{Line: methodDeclaration.codeBlockPos.Line},
+			Inner:        innerBlock,
+			ArgNames:     append([]string{"self"}, methodDeclaration.def.ArgNames...),
+			Defaults:     append([]environment.Node{nil}, methodDeclaration.defaults...),
+			PackVariadic: p.getVariadicPacker(methodDeclaration.def),
+		}
+
+		revertPos()
+	}
+	return methods
+}
+
+// Parses the properties and method signatures of a struct declaration.
+// The bodies of methods are skipped and their positions are returned so they can be parsed once the shape of the struct is known.
+func (p *Parser) parseStructShape() (string, StructDef, []methodDeclaration) {
+	nameToken := p.ExpectToken(TokenIdentifier)
+	name := nameToken.Literal
 
 	p.ExpectToken(TokenLeftBrace)
 	def := NewStructDef(make(map[string]int), make([]TypeDef, 0), name)
 
-	methodDeclarations := make([]structMethodDeclaration, 0)
+	methodDeclarations := make([]methodDeclaration, 0)
+	properties := make([]*Declaration, 0)
 
-	i := 0
 	for {
-		// Expect comma between struct values
-		if i != 0 {
-			p.ExpectToken()
-		}
-
-		token := p.ExpectToken(TokenRightBrace, TokenIdentifier, TokenFunctionDeclaration, TokenNewLine)
-		if token.Type == TokenNewLine {
+		token := p.ExpectToken(TokenRightBrace, TokenIdentifier, TokenReadonly, TokenFunctionDeclaration, TokenNewLine, TokenComma)
+		// Properties can be separated by either new lines or commas
+		if token.Type == TokenNewLine || token.Type == TokenComma {
 			continue
 		} else if token.Type == TokenRightBrace {
 			break
-		} else if token.Type == TokenIdentifier {
+		} else if token.Type == TokenIdentifier || token.Type == TokenReadonly {
+			// Readonly properties can only be set when the struct is created
+			if token.Type == TokenReadonly {
+				token = p.ExpectToken(TokenIdentifier)
+				def.ReadonlyProperties[token.Literal] = true
+			}
+			if _, ok := def.Properties[token.Literal]; ok {
+				p.ThrowTypeError("Property ", token.Literal, " is declared more than once on struct ", name, ".")
+			}
 			p.ExpectToken(TokenColon)
 			propertyDef := p.ParseTypeDef()
-			def.Properties[token.Literal] = i
+			def.Properties[token.Literal] = len(def.PropertyDefs)
 			def.PropertyDefs = append(def.PropertyDefs, propertyDef)
-		} else {
-			methodName, argDefs, argNames, returnType := p.ParseFunctionDef()
-
-			funcDef := NewFuncDef(argDefs, false, returnType)
-			args := make(map[string]TypeDef, len(argDefs))
-			for i, name := range argNames {
-				args[name] = argDefs[i]
+			if property := p.declareMember(token.Literal, DeclarationProperty, token.Position(), propertyDef); property != nil {
+				properties = append(properties, property)
 			}
-			def.Properties[methodName] = i
-			def.PropertyDefs = append(def.PropertyDefs, funcDef)
-			methodDeclarations = append(methodDeclarations, structMethodDeclaration{
-				name:         methodName,
-				def:          funcDef,
-				args:         args,
-				argNames:     argNames,
-				codeBlockPos: p.lexer.SavePos(),
-			})
+		} else {
+			methodDeclarations = append(methodDeclarations, p.parseMethodDeclaration())
 		}
-		i++
 	}
 
-	methods := make([]environment.Node, len(methodDeclarations))
-	for i, methodDeclaration := range methodDeclarations {
-		revertPos := methodDeclaration.codeBlockPos.GoTo()
-
-		methodDeclaration.args["self"] = def
-		innerBlock := p.ParseBlock(methodDeclaration.args, methodDeclaration.def.ReturnType)
-		methods[i] = &nodes.FuncDeclaration{
-			Name:     methodDeclaration.name,
-			Line:     methodDeclaration.codeBlockPos.Line,
-			Inner:    innerBlock,
-			ArgNames: methodDeclaration.argNames,
+	// Methods are stored after all of the data properties at runtime
+	def.DataProperties = len(def.PropertyDefs)
+	for _, methodDeclaration := range methodDeclarations {
+		if _, ok := def.Properties[methodDeclaration.name]; ok {
+			p.ThrowTypeError("Property ", methodDeclaration.name, " is declared more than once on struct ", name, ".")
 		}
-
-		revertPos()
+		def.Properties[methodDeclaration.name] = len(def.PropertyDefs)
+		def.PropertyDefs = append(def.PropertyDefs, methodDeclaration.def)
 	}
 
-	p.currentTypeEnv.Set(name, def)
-
-	return &nodes.StructDeclaration{
-		Name:    name,
-		Methods: methods,
-	}
+	declaration := p.declare(name, DeclarationStruct, nameToken.Position(), def)
+	setMembers(declaration, properties, methodDeclarations)
+	p.endDeclaration(declaration)
+	return name, def, methodDeclarations
 }
 
-func (p *Parser) ParseStructInitialization(name string, def StructDef) environment.Node {
+// Parses the creation of a new struct instance, properties can either all be named or all be passed in order
+func (p *Parser) ParseStructInitialization(name string, def StructDef) (environment.Node, TypeDef) {
+	p.ExpectToken(TokenLeftBrace)
+
 	namedProperties := false
 	unnamedProperties := false
 
-	values := make([]environment.Node, len(def.PropertyDefs))
+	values := make([]environment.Node, def.DataProperties)
+
+	for position := 0; ; {
+		token := p.lexer.MustNext()
+		if token.Type == TokenNewLine || token.Type == TokenComma {
+			continue
+		} else if token.Type == TokenRightBrace {
+			break
+		}
 
-	for i := 0; ; i++ {
-		val, _ := p.ParseValue(def.PropertyDefs[i])
-		token := p.ExpectToken(TokenComma, TokenColon, TokenNewLine, TokenRightBrace)
-		if token.Type == TokenColon {
+		var propertyIndex int
+		if token.Type == TokenIdentifier && p.lexer.MustPeek().Type == TokenColon {
+			p.lexer.Next()
 			if unnamedProperties {
 				p.ThrowSyntaxError("Cannot use mix of named and unnamed parameters")
 			}
-
 			namedProperties = true
-			propertyNameNode, ok := val.(*nodes.Identifier)
-			if !ok {
-				p.ThrowTypeError("Left side of struct property must be an identifier")
+
+			index, ok := def.Properties[token.Literal]
+			if !ok || index >= def.DataProperties {
+				p.ThrowTypeError("Property ", token.Literal, " does not exist on struct ", def.Name, ".")
+			} else if values[index] != nil {
+				p.ThrowTypeError("Property ", token.Literal, " is set more than once.")
+			}
+			propertyIndex = index
+		} else {
+			p.lexer.Unread(token)
+			if namedProperties {
+				p.ThrowSyntaxError("Expected colon after identifier for named property struct initialization")
 			}
-			val, valDef := p.ParseValue(def.PropertyDefs[i])
-			propertyIndex := def.Properties[propertyNameNode.Name]
-			if !valDef.Equals(def.PropertyDefs[propertyIndex]) {
-				p.ThrowTypeError("Incorrect struct type for ", propertyNameNode.Name)
+			unnamedProperties = true
+
+			if position >= def.DataProperties {
+				p.ThrowTypeError("Too many properties passed to struct ", def.Name, ".")
 			}
-			values[propertyIndex] = val
-		} else if namedProperties {
-			p.ThrowSyntaxError("Expected colon after identifier for named property struct initialization")
-		} else if token.Type == TokenRightBrace {
+			propertyIndex = position
+			position++
+		}
+
+		val, valDef := p.ParseValue(def.PropertyDefs[propertyIndex])
+		if valDef == nil || !valDef.Equals(def.PropertyDefs[propertyIndex]) {
+			p.ThrowTypeError("Incorrect struct type for ", def.GetPropertyName(propertyIndex))
+		}
+		values[propertyIndex] = val
+
+		if token := p.ExpectToken(TokenComma, TokenNewLine, TokenRightBrace); token.Type == TokenRightBrace {
 			break
-		} else {
+		}
+	}
 
+	for i, val := range values {
+		if val == nil {
+			p.ThrowTypeError("Missing value for property ", def.GetPropertyName(i), " of struct ", def.Name, ".")
 		}
 	}
 
 	return &nodes.FuncCall{
-		Args: values,
-		Function: &nodes.Identifier{
-			Name: name,
-		},
-	}
+		Args:     values,
+		Function: p.newIdentifier(name),
+	}, def.InstanceDef()
 }
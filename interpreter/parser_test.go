@@ -0,0 +1,341 @@
+package interpreter_test
+
+import (
+	"main/interpreter"
+	"main/interpreter/environment"
+	standardlibrary "main/standard_library"
+	keyvalue "main/standard_library/key_value.go"
+	"sort"
+	"testing"
+)
+
+// Parses programs with errors, checking that the parser collects the diagnostics of each of them instead of crashing
+func TestParseDiagnostics(t *testing.T) {
+	globalDefs, _ := interpreter.BindValues(standardlibrary.Globals)
+	type expected struct {
+		line   int
+		column int
+		code   interpreter.DiagnosticCode
+	}
+	for _, test := range []struct {
+		name        string
+		source      string
+		diagnostics []expected
+	}{
+		{
+			name:        "end of file after return",
+			source:      "fn f(): int64 {\n\treturn 1\n",
+			diagnostics: []expected{{3, 1, interpreter.DiagnosticSyntaxError}},
+		},
+		{
+			name:        "end of file in block after return",
+			source:      "fn f(): int64 {\n\treturn 1\n\tif true {\n\t\tprint(1)\n\t}\n",
+			diagnostics: []expected{{3, 2, interpreter.DiagnosticUnreachableCode}, {6, 1, interpreter.DiagnosticSyntaxError}},
+		},
+		{
+			name:        "end of file in top-level block",
+			source:      "if true {\n\tprint(1)\n",
+			diagnostics: []expected{{3, 1, interpreter.DiagnosticSyntaxError}},
+		},
+		{
+			name:        "blocks after return",
+			source:      "fn f(): int64 {\n\treturn 1\n\tif true {\n\t\tprint(1)\n\t}\n\tprint(2)\n}\nprint(f())\n",
+			diagnostics: []expected{{3, 2, interpreter.DiagnosticUnreachableCode}},
+		},
+		{
+			name:   "return without a value",
+			source: "fn f(): int64 {\n\treturn print(1)\n}\nprint(f())\n",
+			diagnostics: []expected{
+				{2, 16, interpreter.DiagnosticTypeError},
+				// The return statement had an error, so the function is never known to return
+				{3, 1, interpreter.DiagnosticTypeError},
+			},
+		},
+		{
+			// Types that fail to be declared are declared as any so that each use isn't reported as well
+			name:        "alias of a missing type",
+			source:      "type Id = Missing\nvar a Id = 1\nprint(a)\nfn f(x: Id): Id {\n\treturn x\n}\n",
+			diagnostics: []expected{{1, 11, interpreter.DiagnosticTypeError}},
+		},
+		{
+			name:        "distinct type of an array",
+			source:      "type Point [int64]\nfn f(p: Point) {\n}\nvar p Point = [1]\nprint(p)\n",
+			diagnostics: []expected{{1, 13, interpreter.DiagnosticSyntaxError}},
+		},
+		{
+			name:        "alias of a missing type in a function",
+			source:      "fn f() {\n\ttype Id = Missing\n\tvar a Id = 1\n\tprint(a)\n}\n",
+			diagnostics: []expected{{2, 12, interpreter.DiagnosticTypeError}},
+		},
+		{
+			name:        "method declared twice",
+			source:      "type C float64 {\n\tfn a() {\n\t}\n\tfn a() {\n\t}\n}\nvar c = C(1.0)\nc.a()\n",
+			diagnostics: []expected{{5, 2, interpreter.DiagnosticTypeError}},
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, diagnostics := interpreter.NewParser(test.source, "test.lang", globalDefs, nil).Parse()
+			// Declarations are hoisted before the program is parsed, so the diagnostics aren't in the order of the program
+			sort.SliceStable(diagnostics, func(i, j int) bool {
+				if diagnostics[i].Line != diagnostics[j].Line {
+					return diagnostics[i].Line < diagnostics[j].Line
+				}
+				return diagnostics[i].Column < diagnostics[j].Column
+			})
+			if len(diagnostics) != len(test.diagnostics) {
+				t.Fatalf("Expected %d diagnostics, got %v", len(test.diagnostics), diagnostics)
+			}
+			for i, diagnostic := range diagnostics {
+				want := test.diagnostics[i]
+				if diagnostic.Line != want.line || diagnostic.Column != want.column || diagnostic.Code != want.code {
+					t.Errorf("Expected %s at %d:%d, got %s", want.code, want.line, want.column, diagnostic)
+				}
+			}
+		})
+	}
+}
+
+// Parses programs with a syntax error, checking the message of the error
+func TestParseErrorMessages(t *testing.T) {
+	globalDefs, _ := interpreter.BindValues(standardlibrary.Globals)
+	for _, test := range []struct {
+		name    string
+		source  string
+		line    int
+		column  int
+		message string
+	}{
+		{
+			// Variables don't have a zero value to be declared with
+			name:    "let without a value",
+			source:  "let y int64\n",
+			line:    1,
+			column:  12,
+			message: "Expected a value for y, since variables must be given a value when they are declared.",
+		},
+		{
+			name:    "var without a value",
+			source:  "var s string\n",
+			line:    1,
+			column:  13,
+			message: "Expected a value for s, since variables must be given a value when they are declared.",
+		},
+		{
+			name:    "unexpected token",
+			source:  "struct A {\n\tb: int64\n}\nvar a = A{b: 1}\nprint(a.)\n",
+			line:    5,
+			column:  9,
+			message: "Unexpected token \")\".",
+		},
+		{
+			name:    "unexpected end of line",
+			source:  "struct A {\n\tb: int64\n}\nvar a = A{b: 1}\nprint(a.\n",
+			line:    5,
+			column:  9,
+			message: "Unexpected end of line.",
+		},
+		{
+			name:    "unexpected end of file",
+			source:  "struct A {\n\tb: int64\n}\nvar a = A{b: 1}\nprint(a.",
+			line:    5,
+			column:  9,
+			message: "Unexpected end of file.",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, diagnostics := interpreter.NewParser(test.source, "test.lang", globalDefs, nil).Parse()
+			if len(diagnostics) != 1 {
+				t.Fatalf("Expected 1 diagnostic, got %v", diagnostics)
+			}
+			diagnostic := diagnostics[0]
+			if diagnostic.Code != interpreter.DiagnosticSyntaxError || diagnostic.Message != test.message {
+				t.Errorf("Expected the syntax error %q, got %s", test.message, diagnostic)
+			}
+			if diagnostic.Line != test.line || diagnostic.Column != test.column {
+				t.Errorf("Expected the error at %d:%d, got %d:%d", test.line, test.column, diagnostic.Line, diagnostic.Column)
+			}
+		})
+	}
+}
+
+// Parses and runs functions with variadic, default and named arguments, checking the output of the programs that parse
+// and the first diagnostic of the ones that don't
+func TestFunctionArguments(t *testing.T) {
+	functions := "fn sum(start: int64, values: ...int64): int64 {\n\tvar total = start\n\tfor value range values {\n\t\ttotal = total + value\n\t}\n\treturn total\n}\n" +
+		"fn greet(name: string, greeting: string = \"hello\", mark: string = \"!\") {\n\tprint(greeting, name, mark)\n}\n"
+	for _, test := range []struct {
+		name    string
+		source  string
+		output  string
+		message string
+	}{
+		{
+			name:   "variadic arguments",
+			source: "print(sum(1), sum(1, 2), sum(1, 2, 3))\n",
+			output: "1 3 6\n",
+		},
+		{
+			name:   "default values",
+			source: "greet(\"a\")\ngreet(\"b\", \"hi\")\ngreet(\"c\", \"hi\", \"?\")\n",
+			output: "hello a !\nhi b !\nhi c ?\n",
+		},
+		{
+			name:   "named arguments",
+			source: "greet(mark: \"?\", name: \"a\")\ngreet(\"b\", mark: \".\")\nprint(sum(start: 4))\n",
+			output: "hello a ?\nhello b .\n4\n",
+		},
+		{
+			name:   "function type",
+			source: "fn apply(f: fn t(x: int64, rest: ...int64): int64): int64 {\n\treturn f(1, 2)\n}\nprint(apply(sum))\n",
+			output: "3\n",
+		},
+		{
+			name:    "variadic argument before another argument",
+			source:  "fn f(values: ...int64, last: int64) {\n}\n",
+			message: "A variadic argument must be the last argument of a function.",
+		},
+		{
+			name:    "variadic argument with a default value",
+			source:  "fn f(values: ...int64 = 1) {\n}\n",
+			message: "A variadic argument cannot have a default value.",
+		},
+		{
+			name:    "required argument after a default value",
+			source:  "fn f(a: int64 = 1, b: int64) {\n}\n",
+			message: "Argument \"b\" must have a default value since it follows an argument with a default value.",
+		},
+		{
+			name:    "default value of the wrong type",
+			source:  "fn f(a: int64 = \"a\") {\n}\n",
+			message: "Incorrect type of default value for argument \"a\".",
+		},
+		{
+			name:    "default value in a function type",
+			source:  "fn call(f: fn t(x: int64 = 1): int64): int64 {\n\treturn f()\n}\n",
+			message: "The arguments of a function type cannot have default values.",
+		},
+		{
+			name:    "unknown named argument",
+			source:  "greet(\"a\", tone: \"?\")\n",
+			message: "Function does not have an argument named \"tone\".",
+		},
+		{
+			name:    "named argument passed twice",
+			source:  "greet(\"a\", name: \"b\")\n",
+			message: "Argument \"name\" is passed more than once.",
+		},
+		{
+			name:    "positional argument after a named argument",
+			source:  "greet(name: \"a\", \"hi\")\n",
+			message: "Positional arguments cannot follow named arguments.",
+		},
+		{
+			name:    "missing argument",
+			source:  "greet(greeting: \"hi\")\n",
+			message: "Missing argument \"name\" in function call.",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if test.message != "" {
+				globalDefs, _ := interpreter.BindValues(standardlibrary.Globals)
+				_, diagnostics := interpreter.NewParser(functions+test.source, "test.lang", globalDefs, nil).Parse()
+				if len(diagnostics) == 0 || diagnostics[0].Message != test.message {
+					t.Errorf("Expected the error %q, got %v", test.message, diagnostics)
+				}
+				return
+			}
+			for engineName, engine := range engines {
+				output, err := run(t, functions+test.source, engine, environment.Limits{})
+				if err != nil {
+					t.Fatalf("Expected the program to run with the %s engine, got %v", engineName, err)
+				}
+				if output != test.output {
+					t.Errorf("Expected the %s engine to print %q, got %q", engineName, test.output, output)
+				}
+			}
+		})
+	}
+}
+
+// Assigns to and redeclares identifiers that are immutable, checking the error of each
+func TestImmutableAssignment(t *testing.T) {
+	globalDefs, _ := interpreter.BindValues(standardlibrary.Globals)
+	keyValueDefs, _ := interpreter.BindValues(keyvalue.Module)
+	moduleDefs := map[string]map[string]interpreter.TypeDef{"key_value": keyValueDefs}
+	for _, test := range []struct {
+		name    string
+		source  string
+		message string
+	}{
+		{
+			name:    "let binding",
+			source:  "let x = 1\nx = 2\n",
+			message: "Cannot assign to \"x\" since it is immutable, it was declared at line 1.",
+		},
+		{
+			name:    "let binding redeclared",
+			source:  "let x = 1\nvar x = 2\n",
+			message: "Cannot declare \"x\" since it is already declared as immutable at line 1.",
+		},
+		{
+			name:    "destructured let binding",
+			source:  "var pair [2]int64 = [1, 2]\nlet [a, b] = pair\nb = a\n",
+			message: "Cannot assign to \"b\" since it is immutable, it was declared at line 2.",
+		},
+		{
+			name:    "let binding in multiple assignment",
+			source:  "let x = 1\nvar y = 2\nx, y = y, x\n",
+			message: "Cannot assign to \"x\" since it is immutable, it was declared at line 1.",
+		},
+		{
+			name:    "readonly property",
+			source:  "struct Account {\n\treadonly id: int64\n\tbalance: int64\n}\nvar account = Account{id: 1, balance: 2}\naccount.balance = 3\naccount.id = 4\n",
+			message: "Cannot assign to property id since it is readonly.",
+		},
+		{
+			name:    "import",
+			source:  "import \"key_value\"\nkey_value = key_value\n",
+			message: "Cannot assign to \"key_value\" since it is immutable, it was declared at line 1.",
+		},
+		{
+			name:    "import with an alias",
+			source:  "import \"key_value\" as kv\nkv = kv\n",
+			message: "Cannot assign to \"kv\" since it is immutable, it was declared at line 1.",
+		},
+		{
+			name:    "function",
+			source:  "fn f(): int64 {\n\treturn 1\n}\nfn g(): int64 {\n\treturn 2\n}\nf = g\n",
+			message: "Cannot assign to \"f\" since it is immutable, it was declared at line 1.",
+		},
+		{
+			name:    "function declared in a function",
+			source:  "fn outer() {\n\tfn inner() {\n\t}\n\tinner = outer\n}\n",
+			message: "Cannot assign to \"inner\" since it is immutable, it was declared at line 2.",
+		},
+		{
+			name:    "function redeclared",
+			source:  "fn f() {\n}\nvar f = 1\n",
+			message: "Cannot declare \"f\" since it is already declared as immutable at line 1.",
+		},
+		{
+			name:    "global",
+			source:  "print = print\n",
+			message: "Cannot assign to \"print\" since it is a global, which is immutable.",
+		},
+		{
+			name:    "global redeclared",
+			source:  "var print = 1\n",
+			message: "Cannot declare \"print\" since it is already declared as a global, which is immutable.",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, diagnostics := interpreter.NewParser(test.source, "test.lang", globalDefs, moduleDefs).Parse()
+			if len(diagnostics) != 1 {
+				t.Fatalf("Expected 1 diagnostic, got %v", diagnostics)
+			}
+			if diagnostic := diagnostics[0]; diagnostic.Code != interpreter.DiagnosticTypeError || diagnostic.Message != test.message {
+				t.Errorf("Expected the type error %q, got %s", test.message, diagnostic)
+			}
+		})
+	}
+}
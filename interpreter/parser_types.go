@@ -0,0 +1,117 @@
+package interpreter
+
+import (
+	"main/interpreter/environment"
+	"main/interpreter/nodes"
+)
+
+// Type declarations either declare an alias, which is another name for an existing type (type UserId = uint64),
+// or a distinct type (type Celsius float64) which has the same representation as the underlying type at runtime
+// but can only be mixed with it through an explicit conversion such as Celsius(value) or float64(value).
+// Distinct types can also have methods, declared in a block after the underlying type the same way as on structs.
+
+func (p *Parser) ParseTypeDeclaration() environment.Node {
+	line := p.lexer.GetCurrentLine()
+	name, declarationDef, methodDeclarations := p.parseTypeDeclarationShape(true)
+
+	// The type is set before the methods are parsed so that methods can use it
+	p.currentTypeEnv.SetImmutable(name, declarationDef, line)
+
+	slot, _ := p.currentTypeEnv.GetSlot(name)
+	return &nodes.TypeDeclaration{
+		Name:    name,
+		Slot:    slot,
+		Methods: p.parseMethods(methodDeclarations, declarationDef.Def),
+	}
+}
+
+// Parses a type declaration along with the signatures of its methods, the bodies of methods are skipped
+// and their positions are returned so they can be parsed once the type has been declared.
+// If withMethods is false, the methods block is skipped entirely.
+func (p *Parser) parseTypeDeclarationShape(withMethods bool) (string, TypeDeclarationDef, []methodDeclaration) {
+	line := p.lexer.GetCurrentLine()
+	nameToken := p.ExpectToken(TokenIdentifier)
+	name := nameToken.Literal
+	defer func() {
+		if r := recover(); r != nil {
+			// The type is declared as any so that it's uses aren't reported as errors as well as the declaration
+			p.currentTypeEnv.SetImmutable(name, NewTypeDeclarationDef(GenericTypeDef{TypeAny}), line)
+			panic(r)
+		}
+	}()
+
+	if token := p.lexer.MustNext(); token.Type == TokenEquals {
+		declarationDef := NewTypeDeclarationDef(p.ParseTypeDef())
+		p.endDeclaration(p.declare(name, DeclarationType, nameToken.Position(), declarationDef))
+		return name, declarationDef, make([]methodDeclaration, 0)
+	} else {
+		p.lexer.Unread(token)
+	}
+
+	underlyingDef, ok := p.ParseTypeDef().(GenericTypeDef)
+	if !ok || underlyingDef.Type == TypeAny {
+		p.ThrowTypeError("Distinct types can only be declared with a number, string or bool as the underlying type.")
+	}
+	def := NewNamedTypeDef(name, underlyingDef)
+
+	methodDeclarations := make([]methodDeclaration, 0)
+	if token := p.lexer.MustNext(); token.Type != TokenLeftBrace {
+		p.lexer.Unread(token)
+		p.endDeclaration(p.declare(name, DeclarationType, nameToken.Position(), NewTypeDeclarationDef(def)))
+		return name, NewTypeDeclarationDef(def), methodDeclarations
+	} else if !withMethods {
+		p.lexer.Unread(token)
+		p.skipBlock()
+		return name, NewTypeDeclarationDef(def), methodDeclarations
+	}
+	for {
+		token := p.ExpectToken(TokenRightBrace, TokenFunctionDeclaration, TokenNewLine)
+		if token.Type == TokenNewLine {
+			continue
+		} else if token.Type == TokenRightBrace {
+			break
+		} else {
+			methodDeclaration := p.parseMethodDeclaration()
+			if _, ok := def.Methods[methodDeclaration.name]; ok {
+				// The method has already been skipped, so the rest of the type can still be parsed
+				p.reportError(DiagnosticTypeError, "Method ", methodDeclaration.name, " is declared more than once on type ", name, ".")
+				continue
+			}
+			def.Methods[methodDeclaration.name] = len(def.MethodDefs)
+			def.MethodDefs = append(def.MethodDefs, methodDeclaration.def)
+			methodDeclarations = append(methodDeclarations, methodDeclaration)
+		}
+	}
+
+	declaration := p.declare(name, DeclarationType, nameToken.Position(), NewTypeDeclarationDef(def))
+	setMembers(declaration, nil, methodDeclarations)
+	p.endDeclaration(declaration)
+	return name, NewTypeDeclarationDef(def), methodDeclarations
+}
+
+// Parses an explicit conversion of a value in brackets to a type, this is only allowed between types that have the same representation
+func (p *Parser) ParseConversion(def TypeDef) (environment.Node, TypeDef) {
+	p.ExpectToken(TokenLeftBracket)
+	val, valDef := p.ParseValue(def)
+	p.ExpectToken(TokenRightBracket)
+
+	if valDef == nil || (!valDef.Equals(def) && !hasSameRepresentation(valDef, def)) {
+		p.ThrowTypeError("Cannot convert value to a different type.")
+	}
+	return p.ParseValueExpression(val, def)
+}
+
+// Checks if two definitions are either a distinct type or the underlying type of one and represent the same generic type
+func hasSameRepresentation(def TypeDef, other TypeDef) bool {
+	switch def.(type) {
+	case GenericTypeDef, NamedTypeDef:
+	default:
+		return false
+	}
+	switch other.(type) {
+	case GenericTypeDef, NamedTypeDef:
+	default:
+		return false
+	}
+	return def.GetGenericType() == other.GetGenericType()
+}
@@ -9,7 +9,7 @@ import (
 // Parses everything that follows a value to parse the full value expression.
 // This includes things such as function calls, key access, comparisons, operations etc
 func (p *Parser) ParseValueExpression(value environment.Node, def TypeDef) (environment.Node, TypeDef) {
-	token := p.lexer.NextOrExit()
+	token := p.lexer.MustNext()
 	switch token.Type {
 	case TokenLeftBracket:
 		funcDef, ok := def.(FuncDef)
@@ -17,56 +17,12 @@ func (p *Parser) ParseValueExpression(value environment.Node, def TypeDef) (envi
 			p.ThrowTypeError("Cannot call a non-function value")
 		}
 
-		args := make([]environment.Node, len(funcDef.Args))
-		i := 0
-		for ; ; i++ {
-			if token := p.lexer.PeekOrExit(); token.Type == TokenRightBracket {
-				p.lexer.Next()
-				break
-			} else if token.Type == TokenNewLine { // Allow new lines between arguments
-				p.lexer.Next()
-				i--
-				continue
-			}
-
-			var argDef TypeDef
-			// If the function is variadic, there can be an infinite number of args of the last arg type
-			if i >= len(args) && funcDef.Variadic {
-				args = append(args, nil)
-				argDef = funcDef.Args[len(funcDef.Args)-1]
-			} else if i >= len(args) {
-				p.ThrowTypeError("Too many arguments passed to function.")
-			} else {
-				argDef = funcDef.Args[i]
-			}
-
-			val, valDef := p.ParseValue(argDef)
-			if valDef == nil { // If the value parsed is a function with no return type, valDef can be nil
-				p.ThrowTypeError("Cannot use non-value expression as a function argument.")
-			}
-			if !valDef.Equals(argDef) {
-				p.ThrowTypeError("Incorrect type passed for argument ", i+1, " of function call.")
-			}
+		args := p.ParseFunctionCallArgs(funcDef)
 
-			args[i] = val
-			token := p.ExpectToken(TokenRightBracket, TokenComma, TokenNewLine)
-			if token.Type == TokenRightBracket {
-				break
-			} else if token.Type == TokenNewLine {
-				// If a comma is expected, it must be put before a new line
-				if i < len(funcDef.Args) {
-					p.ThrowTypeError("Expected comma after function argument.")
-				}
-			}
-		}
-		if i+1 < len(funcDef.Args) {
-			p.ThrowTypeError("Not enough arguments passed to function.")
-		}
-
-		return p.ParseValueExpression(&nodes.FuncCall{
+		return p.ParseValueExpression(p.span(&nodes.FuncCall{
 			Args:     args,
 			Function: value,
-		}, funcDef.ReturnType)
+		}, startOf(value)), funcDef.ReturnType)
 
 	case TokenLeftSquareBracket:
 		index, indexDef := p.ParseValue(nil)
@@ -78,24 +34,48 @@ func (p *Parser) ParseValueExpression(value environment.Node, def TypeDef) (envi
 			p.ThrowTypeError("Cannot access index on non-array value.")
 		}
 		p.ExpectToken(TokenRightSquareBracket)
-		return GetGenericTypeNode(arrayDef.ElementType).GetArrayIndex(value, index), arrayDef.ElementType
+		return p.span(GetGenericTypeNode(arrayDef.ElementType).GetArrayIndex(value, index), startOf(value)), arrayDef.ElementType
 
 	case TokenPeriod:
+		if namedDef, ok := def.(NamedTypeDef); ok {
+			// The methods are read from the declaration of the type since the definition may have been
+			// copied before all of the methods were known
+			if declarationDef, ok := p.currentTypeEnv.GetTypeDeclaration(namedDef.Name); ok {
+				namedDef = declarationDef
+			}
+			methodToken := p.ExpectToken(TokenIdentifier)
+			methodName := methodToken.Literal
+			methodIndex, ok := namedDef.Methods[methodName]
+			if !ok {
+				p.ThrowTypeError("Method ", methodName, " does not exist on type ", namedDef.Name, ".")
+			}
+			p.referenceMember(namedDef.Name, methodToken, namedDef.MethodDefs[methodIndex])
+			return p.ParseValueExpression(p.span(&nodes.TypeMethod{
+				Value:   value,
+				Methods: p.newIdentifier(namedDef.Name),
+				Index:   methodIndex,
+			}, startOf(value)), namedDef.MethodDefs[methodIndex])
+		}
+
 		structDef, ok := def.(StructDef)
-		if ok {
-			propertyName := p.ExpectToken(TokenIdentifier).Literal
+		if ok && structDef.Type == TypeStructInstance {
+			propertyToken := p.ExpectToken(TokenIdentifier)
+			propertyName := propertyToken.Literal
 			propertyIndex, ok := structDef.Properties[propertyName]
 			if !ok {
 				p.ThrowTypeError("Property ", propertyName, " does not exist on struct ", structDef.Name, ".")
 			}
 			propertyDef := structDef.PropertyDefs[propertyIndex]
+			p.referenceMember(structDef.Name, propertyToken, propertyDef)
 
 			// Structs are arrays that have property names mapped to indexes whilst parsing
-			return p.ParseValueExpression(&nodes.StructProperty{
+			return p.ParseValueExpression(p.span(&nodes.StructProperty{
 				Struct:   value,
 				Index:    propertyIndex,
-				IsMethod: propertyDef.GetGenericType() == TypeFunc,
-			}, propertyDef)
+				IsMethod: propertyIndex >= structDef.DataProperties,
+				Readonly: structDef.ReadonlyProperties[propertyName],
+				Name:     propertyName,
+			}, startOf(value)), propertyDef)
 		}
 
 		moduleDef, ok := def.(ModuleDef)
@@ -103,26 +83,122 @@ func (p *Parser) ParseValueExpression(value environment.Node, def TypeDef) (envi
 			p.ThrowTypeError("Properties and methods can only be accessed on modules and structs.")
 		}
 
-		property := p.ExpectToken(TokenIdentifier).Literal
+		propertyToken := p.ExpectToken(TokenIdentifier)
+		property := propertyToken.Literal
 		propertyDef, ok := moduleDef.Properties[property]
 		if !ok {
 			p.ThrowTypeError("Property ", property, " does not exist on module ", value.(*nodes.Identifier).Name, ".")
 		}
+		p.referenceMember("", propertyToken, propertyDef)
 
 		// Modules are just represented as maps of property keys to values at runtime so a map access node can be used to
-		return p.ParseValueExpression(&nodes.MapValue[string, any]{
+		return p.ParseValueExpression(p.span(&nodes.MapValue[string, any]{
 			Map: value,
 			Key: &nodes.Value{Value: property},
-		}, propertyDef)
+		}, startOf(value)), propertyDef)
 	}
 
 	p.lexer.Unread(token)
 	return value, def
 }
 
+// Parses the arguments of a function call up to the closing bracket.
+// Named arguments are moved to the position of the argument they name and arguments that
+// are omitted are left as nil so that the function uses their default value.
+func (p *Parser) ParseFunctionCallArgs(funcDef FuncDef) []environment.Node {
+	fixedArgs := funcDef.FixedArgs()
+	args := make([]environment.Node, fixedArgs)
+	passed := make([]bool, fixedArgs)
+
+	usedNamedArgs := false
+	position := 0
+	for argCount := 1; ; argCount++ {
+		token := p.lexer.MustNext()
+		if token.Type == TokenNewLine { // Allow new lines between arguments
+			argCount--
+			continue
+		} else if token.Type == TokenRightBracket {
+			break
+		}
+
+		argIndex := position
+		// Check for a named argument in the form name: value
+		if token.Type == TokenIdentifier && p.lexer.MustPeek().Type == TokenColon {
+			p.lexer.Next()
+			argIndex = -1
+			for i, name := range funcDef.ArgNames {
+				if name == token.Literal && i < fixedArgs {
+					argIndex = i
+				}
+			}
+			if argIndex == -1 {
+				p.ThrowTypeError("Function does not have an argument named \"", token.Literal, "\".")
+			} else if passed[argIndex] {
+				p.ThrowTypeError("Argument \"", token.Literal, "\" is passed more than once.")
+			}
+			usedNamedArgs = true
+		} else {
+			p.lexer.Unread(token)
+			if usedNamedArgs {
+				p.ThrowSyntaxError("Positional arguments cannot follow named arguments.")
+			}
+			position++
+		}
+
+		var argDef TypeDef
+		// If the function is variadic, there can be an infinite number of args of the last arg type
+		if argIndex >= fixedArgs && funcDef.Variadic {
+			argDef = funcDef.Args[fixedArgs]
+		} else if argIndex >= fixedArgs {
+			p.ThrowTypeError("Too many arguments passed to function.")
+		} else {
+			argDef = funcDef.Args[argIndex]
+		}
+
+		val, valDef := p.ParseValue(argDef)
+		if valDef == nil { // If the value parsed is a function with no return type, valDef can be nil
+			p.ThrowTypeError("Cannot use non-value expression as a function argument.")
+		}
+		if !valDef.Equals(argDef) {
+			p.ThrowTypeError("Incorrect type passed for argument ", argCount, " of function call.")
+		}
+
+		if argIndex >= fixedArgs {
+			args = append(args, val)
+		} else {
+			args[argIndex] = val
+			passed[argIndex] = true
+		}
+
+		token = p.ExpectToken(TokenRightBracket, TokenComma, TokenNewLine)
+		if token.Type == TokenRightBracket {
+			break
+		} else if token.Type == TokenNewLine {
+			// If another argument follows, the comma must be put before the new line
+			for token.Type == TokenNewLine {
+				token = p.lexer.MustNext()
+			}
+			if token.Type != TokenRightBracket {
+				p.ThrowSyntaxError("Expected comma after function argument.")
+			}
+			break
+		}
+	}
+
+	for i := 0; i < fixedArgs-funcDef.OptionalArgs; i++ {
+		if !passed[i] {
+			if usedNamedArgs {
+				p.ThrowTypeError("Missing argument \"", funcDef.ArgNames[i], "\" in function call.")
+			}
+			p.ThrowTypeError("Not enough arguments passed to function.")
+		}
+	}
+	return args
+}
+
 // Parses maths operations, respecting the correct order of operations
 func (p *Parser) ParseMathsOperations(value environment.Node, def TypeDef, onlyMultiplication bool) (environment.Node, TypeDef) {
-	token := p.lexer.NextOrExit()
+	token := p.lexer.MustNext()
 	operationType := token.Type
 	if operationType != TokenPlus && operationType != TokenDash && operationType != TokenAsterisk && operationType != TokenForwardSlash {
 		p.lexer.Unread(token)
@@ -156,14 +232,14 @@ func (p *Parser) ParseMathsOperations(value environment.Node, def TypeDef, onlyM
 			panic("Non-operation token passed as operationType token")
 		}
 
-		// Read the next operation
-		token = p.lexer.NextOrExit()
-		if token.Type == TokenAsterisk || token.Type == TokenForwardSlash {
-			p.lexer.Unread(token)
+		// Multiplication and division are done before the operation, so they become the right hand side
+		if p.lexer.MustPeek().Type == TokenAsterisk || p.lexer.MustPeek().Type == TokenForwardSlash {
 			rhsVal, _ = p.ParseMathsOperations(rhsVal, def, true)
-			token = p.lexer.NextOrExit()
 		}
-		value = GetGenericTypeNode(def).GetMathsOperation(operation, value, rhsVal)
+		value = p.span(GetGenericTypeNode(def).GetMathsOperation(operation, value, rhsVal), startOf(value))
+
+		// Read the next operation
+		token = p.lexer.MustNext()
 		if token.Type != TokenAsterisk && token.Type != TokenForwardSlash && token.Type != TokenPlus && token.Type != TokenDash {
 			p.lexer.Unread(token)
 			return value, def
@@ -173,7 +249,7 @@ func (p *Parser) ParseMathsOperations(value environment.Node, def TypeDef, onlyM
 }
 
 func (p *Parser) ParseOperator(value environment.Node, def TypeDef) (environment.Node, TypeDef) {
-	token := p.lexer.NextOrExit()
+	token := p.lexer.MustNext()
 
 	switch token.Type {
 	case TokenAmpersand:
@@ -188,10 +264,10 @@ func (p *Parser) ParseOperator(value environment.Node, def TypeDef) (environment
 			p.ThrowTypeError("Right hand side of && operation must be a boolean value.")
 		}
 
-		return &nodes.And{
+		return p.span(&nodes.And{
 			LeftSide:  value,
 			RightSide: rhsVal,
-		}, GenericTypeDef{TypeBool}
+		}, startOf(value)), GenericTypeDef{TypeBool}
 
 	case TokenBar:
 		p.ExpectToken(TokenBar)
@@ -205,55 +281,71 @@ func (p *Parser) ParseOperator(value environment.Node, def TypeDef) (environment
 			p.ThrowTypeError("Right hand side of || operation must be a boolean value.")
 		}
 
-		return &nodes.Or{
+		return p.span(&nodes.Or{
 			LeftSide:  value,
 			RightSide: rhsVal,
-		}, GenericTypeDef{TypeBool}
+		}, startOf(value)), GenericTypeDef{TypeBool}
 
 	case TokenEquals:
 		// Check for comparison
-		if p.lexer.PeekOrExit().Type == TokenEquals {
+		if p.lexer.MustPeek().Type == TokenEquals {
 			p.lexer.Next()
 			rhsVal, rhsValDef := p.ParseCalculatedValue(def)
 			if !rhsValDef.Equals(def) {
 				p.ThrowTypeError("Right hand side of comparison must be the same type as the left hand side.")
 			}
-			return p.ParseOperator(&nodes.EqualityComparison{LeftSide: value, RightSide: rhsVal}, GenericTypeDef{TypeBool})
+			return p.ParseOperator(p.span(&nodes.EqualityComparison{LeftSide: value, RightSide: rhsVal}, startOf(value)), GenericTypeDef{TypeBool})
 		}
 
 		// Check for assignment
 		if ident, ok := value.(*nodes.Identifier); ok {
+			p.checkAssignable(ident.Name)
 			newVal, newValDef := p.ParseValue(def)
-			if !def.Equals(newValDef) {
+			if !newValDef.Equals(def) {
 				p.ThrowTypeError("Cannot assign new type to variable \"", ident.Name, "\".")
 			}
 
-			_, depth := p.currentTypeEnv.Get(ident.Name)
-
-			return &nodes.Assignment{
+			return p.span(&nodes.Assignment{
 				Identifier: ident.Name,
+				Slot:       ident.Slot,
 				NewValue:   newVal,
-				Depth:      depth,
-			}, def
+				Depth:      ident.Depth,
+			}, startOf(value)), def
 		}
 
-		genericTypeNode := GetGenericTypeNode(def)
-		if arrayNode, indexNode, ok := genericTypeNode.ArrayIndexDetails(value); ok {
-			// Assignment to element of array
+		if property, ok := value.(*nodes.StructProperty); ok {
+			if property.IsMethod {
+				p.ThrowTypeError("Cannot assign to method ", property.Name, " of a struct.")
+			} else if property.Readonly {
+				p.ThrowTypeError("Cannot assign to property ", property.Name, " since it is readonly.")
+			}
 			newVal, newValDef := p.ParseValue(def)
-			if !newValDef.Equals(def) {
-				p.ThrowTypeError("Incorrect type in array element assignment.")
+			if newValDef == nil || !newValDef.Equals(def) {
+				p.ThrowTypeError("Incorrect type in struct property assignment.")
+			}
+			return p.span(&nodes.StructPropertyAssignment{
+				Property: property,
+				Value:    newVal,
+			}, startOf(value)), def
+		}
+
+		if genericTypeNode := GetGenericTypeNode(def); genericTypeNode != nil {
+			if _, _, ok := genericTypeNode.ArrayIndexDetails(value); ok {
+				// Assignment to element of array
+				newVal, newValDef := p.ParseValue(def)
+				if !newValDef.Equals(def) {
+					p.ThrowTypeError("Incorrect type in array element assignment.")
+				}
+				return p.span(genericTypeNode.GetArrayAssignment(value, newVal), startOf(value)), def
 			}
-			return genericTypeNode.GetArrayAssignment(arrayNode, indexNode, newVal), def
-		} else {
-			p.ThrowSyntaxError("Left hand side of assignment is not assignable.")
 		}
+		p.ThrowSyntaxError("Left hand side of assignment is not assignable.")
 
 	case TokenGreaterThan, TokenLessThan:
 		if !def.IsNumber() {
 			p.ThrowTypeError("Cannot perform comparison on non-number.")
 		}
-		nextToken := p.lexer.PeekOrExit()
+		nextToken := p.lexer.MustPeek()
 		var comparison nodes.ComparisonType
 		if token.Type == TokenGreaterThan && nextToken.Type == TokenEquals {
 			p.lexer.Next()
@@ -270,7 +362,21 @@ func (p *Parser) ParseOperator(value environment.Node, def TypeDef) (environment
 		if !rhsValDef.Equals(def) {
 			p.ThrowTypeError("Right hand side of comparison must be the same type as the left hand side.")
 		}
-		return p.ParseOperator(GetGenericTypeNode(def).GetInequalityComparison(comparison, value, rhsVal), GenericTypeDef{TypeBool})
+		return p.ParseOperator(p.span(GetGenericTypeNode(def).GetInequalityComparison(comparison, value, rhsVal), startOf(value)), GenericTypeDef{TypeBool})
+
+	case TokenIsOperator:
+		typeDef := p.ParseTypeDef()
+		if !canBeOfType(def, typeDef) {
+			p.ThrowTypeError("Value can never be of the type it is checked against.")
+		}
+		if def.GetGenericType() == TypeAny && !isIdentifiableAtRuntime(typeDef) {
+			// The rest of the statement can still be parsed
+			p.reportError(DiagnosticTypeError, "Values of type any can't be checked against structs, named types, functions, or arrays and maps of any or unions, since they can't be told apart at runtime.")
+		}
+		return p.ParseOperator(p.span(&nodes.TypeCheck{
+			Value: value,
+			Type:  newRuntimeTypeCheck(typeDef),
+		}, startOf(value)), GenericTypeDef{TypeBool})
 
 	case TokenExclamationMark:
 		p.ExpectToken(TokenEquals)
@@ -278,9 +384,9 @@ func (p *Parser) ParseOperator(value environment.Node, def TypeDef) (environment
 		if !rhsValDef.Equals(def) {
 			p.ThrowTypeError("Right hand side of comparison must be the same type as the left hand side.")
 		}
-		return p.ParseOperator(&nodes.Not{
-			Value: &nodes.EqualityComparison{LeftSide: value, RightSide: rhsVal},
-		}, GenericTypeDef{TypeBool})
+		return p.ParseOperator(p.span(&nodes.Not{
+			Value: p.span(&nodes.EqualityComparison{LeftSide: value, RightSide: rhsVal}, startOf(value)),
+		}, startOf(value)), GenericTypeDef{TypeBool})
 	}
 
 	p.lexer.Unread(token)
@@ -289,42 +395,54 @@ func (p *Parser) ParseOperator(value environment.Node, def TypeDef) (environment
 
 // Parses a value of any type, without accounting for logical operations that follow it.
 func (p *Parser) ParsePartialValue(implicitType TypeDef) (environment.Node, TypeDef) {
-	token := p.ExpectToken(TokenString, TokenNumber, TokenIdentifier, TokenTrue, TokenFalse, TokenDash, TokenLeftBracket, TokenLeftSquareBracket, TokenNewLine, TokenExclamationMark)
+	token := p.ExpectToken(TokenString, TokenNumber, TokenIdentifier, TokenTrue, TokenFalse, TokenDash, TokenLeftBracket, TokenLeftSquareBracket, TokenNewLine, TokenExclamationMark,
+		TokenTypeInt8, TokenTypeInt16, TokenTypeInt32, TokenTypeInt64, TokenTypeUint8, TokenTypeUint16, TokenTypeUint32, TokenTypeUint64, TokenTypeFloat32, TokenTypeFloat64, TokenTypeString, TokenTypeBool)
 	switch token.Type {
+	case TokenTypeInt8, TokenTypeInt16, TokenTypeInt32, TokenTypeInt64, TokenTypeUint8, TokenTypeUint16, TokenTypeUint32, TokenTypeUint64, TokenTypeFloat32, TokenTypeFloat64, TokenTypeString, TokenTypeBool:
+		conversion, def := p.ParseConversion(GenericTypeDef{TypeTokenToPrimitiveType(token)})
+		return p.span(conversion, token.Position()), def
+
 	case TokenString:
-		return p.ParseValueExpression(&nodes.Value{Value: token.Literal}, GenericTypeDef{TypeString})
+		return p.ParseValueExpression(p.span(&nodes.Value{Value: token.Literal}, token.Position()), GenericTypeDef{TypeString})
 	case TokenTrue:
-		return p.ParseValueExpression(&nodes.Value{Value: true}, GenericTypeDef{TypeBool})
+		return p.ParseValueExpression(p.span(&nodes.Value{Value: true}, token.Position()), GenericTypeDef{TypeBool})
 	case TokenFalse:
-		return p.ParseValueExpression(&nodes.Value{Value: false}, GenericTypeDef{TypeBool})
+		return p.ParseValueExpression(p.span(&nodes.Value{Value: false}, token.Position()), GenericTypeDef{TypeBool})
 	case TokenNumber:
 		// Check for decimal point, in which case it's a float
-		if p.lexer.PeekOrExit().Type == TokenPeriod {
+		if p.lexer.MustPeek().Type == TokenPeriod {
 			p.lexer.Next()
 			decimalNum := p.ExpectToken(TokenNumber)
 			val, _ := strconv.ParseFloat(token.Literal+"."+decimalNum.Literal, 64)
 			if implicitType != nil {
 				if implicitVal := ConvertFloat64ToTypeDef(val, implicitType.GetGenericType()); implicitVal != nil {
-					return &nodes.Value{Value: implicitVal}, implicitType
+					return p.span(&nodes.Value{Value: implicitVal}, token.Position()), implicitType
 				}
 			}
-			return p.ParseValueExpression(&nodes.Value{Value: val}, GenericTypeDef{TypeFloat64})
+			return p.ParseValueExpression(p.span(&nodes.Value{Value: val}, token.Position()), GenericTypeDef{TypeFloat64})
 		}
 		val, _ := strconv.ParseInt(token.Literal, 10, 64)
 
 		if implicitType != nil {
 			if implicitVal := ConvertInt64ToTypeDef(val, implicitType.GetGenericType()); implicitVal != nil {
-				return p.ParseValueExpression(&nodes.Value{Value: implicitVal}, implicitType)
+				return p.ParseValueExpression(p.span(&nodes.Value{Value: implicitVal}, token.Position()), implicitType)
 			}
 		}
-		return p.ParseValueExpression(&nodes.Value{Value: val}, GenericTypeDef{TypeInt64})
+		return p.ParseValueExpression(p.span(&nodes.Value{Value: val}, token.Position()), GenericTypeDef{TypeInt64})
 
 	case TokenIdentifier:
-		typeDef, _ := p.currentTypeEnv.Get(token.Literal)
+		typeDef := p.lookup(token)
 		if typeDef == nil {
 			p.ThrowTypeError(token.Literal, " is not defined in this scope.")
 		}
-		return p.ParseValueExpression(&nodes.Identifier{Name: token.Literal}, typeDef)
+		if structDef, ok := typeDef.(StructDef); ok && structDef.Type == TypeStruct {
+			initialization, def := p.ParseStructInitialization(token.Literal, structDef)
+			return p.ParseValueExpression(p.span(initialization, token.Position()), def)
+		} else if declarationDef, ok := typeDef.(TypeDeclarationDef); ok {
+			conversion, def := p.ParseConversion(declarationDef.Def)
+			return p.span(conversion, token.Position()), def
+		}
+		return p.ParseValueExpression(p.span(p.newIdentifier(token.Literal), token.Position()), typeDef)
 
 	case TokenLeftBracket:
 		defer p.ExpectToken(TokenRightBracket)
@@ -335,7 +453,7 @@ func (p *Parser) ParsePartialValue(implicitType TypeDef) (environment.Node, Type
 		if def.GetGenericType() != TypeBool {
 			p.ThrowTypeError("Not operator must be used on a boolean value.")
 		}
-		return &nodes.Not{Value: val}, GenericTypeDef{TypeBool}
+		return p.span(&nodes.Not{Value: val}, token.Position()), GenericTypeDef{TypeBool}
 
 	case TokenLeftSquareBracket:
 		var elements []environment.Node
@@ -352,7 +470,11 @@ func (p *Parser) ParsePartialValue(implicitType TypeDef) (environment.Node, Type
 		}
 
 		for position := 0; ; position++ {
-			if p.lexer.PeekOrExit().Type == TokenRightBracket {
+			// Elements can be put on their own lines, with a comma after the last element
+			for p.lexer.MustPeek().Type == TokenNewLine {
+				p.lexer.Next()
+			}
+			if p.lexer.MustPeek().Type == TokenRightSquareBracket {
 				p.lexer.Next()
 				break
 			}
@@ -372,7 +494,16 @@ func (p *Parser) ParsePartialValue(implicitType TypeDef) (environment.Node, Type
 				elements[position] = element
 			}
 
-			if token = p.ExpectToken(TokenComma, TokenRightSquareBracket); token.Type == TokenRightSquareBracket {
+			if token = p.ExpectToken(TokenComma, TokenRightSquareBracket, TokenNewLine); token.Type == TokenRightSquareBracket {
+				break
+			} else if token.Type == TokenNewLine {
+				// If another element follows, the comma must be put before the new line
+				for token.Type == TokenNewLine {
+					token = p.lexer.MustNext()
+				}
+				if token.Type != TokenRightSquareBracket {
+					p.ThrowSyntaxError("Expected comma after array element.")
+				}
 				break
 			}
 		}
@@ -381,18 +512,18 @@ func (p *Parser) ParsePartialValue(implicitType TypeDef) (environment.Node, Type
 			// get the type from, an array with no elements and no explicit type definition is not allowed.
 			p.ThrowTypeError("An array of an unkown type cannot have 0 elements.")
 		}
-		return GetGenericTypeNode(elementType).GetArrayInitialization(elements), NewArrayDef(elementType, size)
+		return p.span(GetGenericTypeNode(elementType).GetArrayInitialization(elements), token.Position()), NewArrayDef(elementType, size)
 
 	case TokenDash:
 		val, def := p.ParseValue(nil)
 		if !def.IsNumber() {
 			p.ThrowTypeError("Cannot get negative value of non-number value.")
 		}
-		return GetGenericTypeNode(def).GetMathsOperation(
+		return p.span(GetGenericTypeNode(def).GetMathsOperation(
 			nodes.MathsSubtraction,
 			&nodes.Value{Value: ConvertInt64ToTypeDef(0, def.GetGenericType())},
 			val,
-		), def
+		), token.Position()), def
 	}
 	return p.ParseValue(implicitType)
 }
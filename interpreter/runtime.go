@@ -0,0 +1,304 @@
+package interpreter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"main/interpreter/environment"
+	"main/interpreter/nodes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// A Runtime hosts programs in a Go program. Values are registered along with the definition the parser checks them
+// against, so the types and values programs are given can't disagree.
+//
+// Every program run by a runtime shares the same top-level scope, so the functions and variables a program declares
+// can be used by programs run after it and by Go code through Get, Set and Call. Programs are run by the tree walker
+// since the virtual machine doesn't keep it's state between programs.
+type Runtime struct {
+	typeEnv    *TypeEnvironment
+	env        *environment.Environment
+	moduleDefs map[string]map[string]TypeDef
+	modules    map[string]map[string]any
+	limits     environment.Limits
+}
+
+// The functions and values of a module that programs can import
+type Module struct {
+	defs   map[string]TypeDef
+	values map[string]any
+}
+
+// An error in the source code of a program that stopped it from being run
+type ParseError struct {
+	FilePath    string
+	Diagnostics []Diagnostic
+}
+
+func (e *ParseError) Error() string {
+	var lines []string
+	for _, diagnostic := range e.Diagnostics {
+		if diagnostic.Severity == SeverityError {
+			lines = append(lines, e.FilePath+":"+diagnostic.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func NewRuntime() *Runtime {
+	modules := make(map[string]map[string]any)
+	return &Runtime{
+		typeEnv:    NewTypeEnvironment(nil, nil, 0),
+		env:        environment.New(nil, environment.Call{Name: "main"}, modules, nil, false),
+		moduleDefs: make(map[string]map[string]TypeDef),
+		modules:    modules,
+	}
+}
+
+// Sets how the garbage collector runs, which must be done before any programs are run
+func (r *Runtime) SetGCOptions(options environment.GCOptions) {
+	r.env.SetGCOptions(options)
+}
+
+// Sets the limits of each program run and function called by the runtime, the counts of the limits start again for
+// each of them
+func (r *Runtime) SetLimits(limits environment.Limits) {
+	r.limits = limits
+}
+
+// Declares a global that programs can use but not reassign, the value must be of the type in the definition. Go
+// functions are called with arguments of the types in the definition, so it can describe things that can't be derived
+// such as optional arguments. Registering a global again replaces it.
+func (r *Runtime) RegisterValue(name string, def TypeDef, value any) {
+	if value != nil && reflect.TypeOf(value).Kind() == reflect.Func {
+		value = environment.NewNative(value)
+	}
+	r.typeEnv.SetImmutable(name, def, 0)
+	slot, _ := r.typeEnv.GetSlot(name)
+	r.env.Set(name, slot, value)
+}
+
+// Declares a Go function that programs can call, with the definition derived from the function's Go type
+func (r *Runtime) RegisterFunc(name string, fn any) {
+	def, function := BindFunc(fn)
+	r.RegisterValue(name, def, function)
+}
+
+// Adds a module that programs can import, replacing any module with the same name
+func (r *Runtime) RegisterModule(name string, module *Module) {
+	r.moduleDefs[name] = module.defs
+	r.modules[name] = module.values
+}
+
+func NewModule() *Module {
+	return &Module{defs: make(map[string]TypeDef), values: make(map[string]any)}
+}
+
+// Adds a value to the module, which must be of the type in the definition
+func (m *Module) RegisterValue(name string, def TypeDef, value any) *Module {
+	if value != nil && reflect.TypeOf(value).Kind() == reflect.Func {
+		value = environment.NewNative(value)
+	}
+	m.defs[name] = def
+	m.values[name] = value
+	return m
+}
+
+// Adds a Go function to the module, with the definition derived from the function's Go type
+func (m *Module) RegisterFunc(name string, fn any) *Module {
+	def, function := BindFunc(fn)
+	return m.RegisterValue(name, def, function)
+}
+
+// Runs the program in a file, returning a *ParseError if the program has errors or a *RuntimeError if it panics
+func (r *Runtime) RunFile(path string) error {
+	return r.RunFileContext(context.Background(), path)
+}
+
+// Runs the program in a file until it finishes or the context is done, returning a *ParseError if the program has
+// errors or a *RuntimeError if it panics or is stopped
+func (r *Runtime) RunFileContext(ctx context.Context, path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return r.run(ctx, string(content), path)
+}
+
+// Runs a program from it's source code, returning a *ParseError if the program has errors or a *RuntimeError if it
+// panics
+func (r *Runtime) RunString(source string) error {
+	return r.RunStringContext(context.Background(), source)
+}
+
+// Runs a program from it's source code until it finishes or the context is done, returning a *ParseError if the
+// program has errors or a *RuntimeError if it panics or is stopped
+func (r *Runtime) RunStringContext(ctx context.Context, source string) error {
+	return r.run(ctx, source, "<string>")
+}
+
+func (r *Runtime) run(ctx context.Context, source string, filePath string) error {
+	// A program with errors doesn't run, so none of it's declarations are kept
+	snapshot := r.typeEnv.snapshot()
+	ast, diagnostics := newParser(source, filePath, r.typeEnv, r.moduleDefs).Parse()
+	if HasErrors(diagnostics) {
+		r.typeEnv.restore(snapshot)
+		return &ParseError{FilePath: filePath, Diagnostics: diagnostics}
+	}
+
+	// The garbage collector would free top-level identifiers once the program stops using them, but they can still
+	// be used by Go code and later programs
+	for name := range r.typeEnv.slots {
+		r.env.Pin(name)
+	}
+	r.env.Call.File = filePath
+	r.env.SetLimits(ctx, r.limits)
+	if err := executeTree(r.env, ast); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Gets the value of a top-level identifier, either one that was registered or one declared by a program
+func (r *Runtime) Get(name string) (any, bool) {
+	slot, depth := r.typeEnv.GetSlot(name)
+	if depth != 0 {
+		return nil, false
+	}
+	return r.env.Get(slot, 0), true
+}
+
+// Sets the value of a top-level identifier, which must already be declared and can't be immutable
+func (r *Runtime) Set(name string, value any) error {
+	def, depth := r.typeEnv.Get(name)
+	if depth != 0 {
+		return errors.New(name + " is not defined")
+	}
+	if _, ok := r.typeEnv.GetImmutableLine(name); ok {
+		return errors.New("cannot set " + name + " since it is immutable")
+	}
+	if !GetRuntimeTypeCheck(def)(value) {
+		return fmt.Errorf("cannot set %s to a value of type %T", name, value)
+	}
+	slot, _ := r.typeEnv.GetSlot(name)
+	r.env.Set(name, slot, value)
+	return nil
+}
+
+// Calls a top-level function with arguments of the types it takes, arguments with a default value can be left off the
+// end. Returns a *RuntimeError if the function panics.
+func (r *Runtime) Call(name string, args ...any) (any, error) {
+	return r.CallContext(context.Background(), name, args...)
+}
+
+// Calls a top-level function like Call, stopping it if the context is done
+func (r *Runtime) CallContext(ctx context.Context, name string, args ...any) (result any, err error) {
+	def, depth := r.typeEnv.Get(name)
+	funcDef, ok := def.(FuncDef)
+	if depth != 0 || !ok {
+		return nil, errors.New(name + " is not a function")
+	}
+	requiredArgs := funcDef.FixedArgs() - funcDef.OptionalArgs
+	if len(args) < requiredArgs || (!funcDef.Variadic && len(args) > funcDef.FixedArgs()) {
+		if requiredArgs == funcDef.FixedArgs() {
+			return nil, fmt.Errorf("%s takes %d arguments but was called with %d", name, funcDef.FixedArgs(), len(args))
+		}
+		return nil, fmt.Errorf("%s takes %d to %d arguments but was called with %d", name, requiredArgs, funcDef.FixedArgs(), len(args))
+	}
+	for i, arg := range args {
+		argDef := funcDef.Args[len(funcDef.Args)-1]
+		if i < funcDef.FixedArgs() {
+			argDef = funcDef.Args[i]
+		}
+		if !GetRuntimeTypeCheck(argDef)(arg) {
+			return nil, fmt.Errorf("argument %d of %s cannot be a value of type %T", i+1, name, arg)
+		}
+	}
+
+	// The arguments that were left off are given their default values, the same as when a program leaves them off.
+	// The capacity is limited so that the slice passed by the caller isn't changed.
+	args = args[:len(args):len(args)]
+	for len(args) < funcDef.FixedArgs() {
+		args = append(args, nodes.DefaultArg{})
+	}
+
+	function, _ := r.Get(name)
+	r.env.SetLimits(ctx, r.limits)
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = r.runtimeError(recovered)
+		}
+	}()
+	return function.(environment.Callable).Call(nil, args), nil
+}
+
+// Runs source code that is either a single expression or a program, such as a line entered in to a REPL. Returns the
+// value and definition of an expression, the definition is nil for programs. Returns a *ParseError if the source code
+// has errors or a *RuntimeError if it panics.
+func (r *Runtime) Eval(source string) (any, TypeDef, error) {
+	return r.EvalContext(context.Background(), source)
+}
+
+// Runs source code like Eval, stopping it if the context is done
+func (r *Runtime) EvalContext(ctx context.Context, source string) (value any, def TypeDef, err error) {
+	snapshot := r.typeEnv.snapshot()
+	node, def, diagnostics := newParser(source, "<string>", r.typeEnv, r.moduleDefs).ParseExpression()
+	if HasErrors(diagnostics) {
+		// Source code that isn't an expression is run as a program
+		r.typeEnv.restore(snapshot)
+		err := r.run(ctx, source, "<string>")
+		// If it isn't a program either, the errors of whichever was parsed further are the ones that are reported
+		var parseErr *ParseError
+		if errors.As(err, &parseErr) && isBefore(firstError(parseErr.Diagnostics), firstError(diagnostics)) {
+			return nil, nil, &ParseError{FilePath: "<string>", Diagnostics: diagnostics}
+		}
+		return nil, nil, err
+	}
+
+	r.env.SetLimits(ctx, r.limits)
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			value, def, err = nil, nil, r.runtimeError(recovered)
+		}
+	}()
+	return node.Eval(r.env), def, nil
+}
+
+// Gets the definition of an expression without evaluating it, returning a *ParseError if the expression has errors
+func (r *Runtime) TypeOf(source string) (TypeDef, error) {
+	snapshot := r.typeEnv.snapshot()
+	defer r.typeEnv.restore(snapshot)
+	_, def, diagnostics := newParser(source, "<string>", r.typeEnv, r.moduleDefs).ParseExpression()
+	if HasErrors(diagnostics) {
+		return nil, &ParseError{FilePath: "<string>", Diagnostics: diagnostics}
+	}
+	return def, nil
+}
+
+// Gets the position of the first error of diagnostics
+func firstError(diagnostics []Diagnostic) environment.Position {
+	for _, diagnostic := range diagnostics {
+		if diagnostic.Severity == SeverityError {
+			return diagnostic.Position
+		}
+	}
+	return environment.Position{}
+}
+
+// Creates the error for a panic recovered from while running Go code called by the runtime
+func (r *Runtime) runtimeError(recovered any) *RuntimeError {
+	current := r.env.GetCurrentExecutionEnv()
+	if current == nil {
+		current = r.env
+	}
+	err := current.NewRuntimeError(recovered)
+	r.env.ResetCurrentExecutionEnv()
+	return err
+}
@@ -0,0 +1,210 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"main/interpreter"
+	"main/interpreter/environment"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Creates a runtime with a print function writing to the returned buffer
+func newRuntime() (*interpreter.Runtime, *bytes.Buffer) {
+	var output bytes.Buffer
+	runtime := interpreter.NewRuntime()
+	runtime.RegisterFunc("print", func(args ...any) {
+		fmt.Fprintln(&output, args...)
+	})
+	return runtime, &output
+}
+
+// Runs programs using registered values, functions and modules
+func TestRuntimeRegister(t *testing.T) {
+	runtime, output := newRuntime()
+	runtime.RegisterValue("limit", int64Def, int64(3))
+	runtime.RegisterFunc("twice", func(x int) int {
+		return x * 2
+	})
+	runtime.RegisterModule("text", interpreter.NewModule().
+		RegisterValue("separator", stringDef, "-").
+		RegisterFunc("repeat", func(s string, count int) string {
+			result := ""
+			for i := 0; i < count; i++ {
+				result += s
+			}
+			return result
+		}))
+
+	if err := runtime.RunString("import \"text\"\nprint(twice(limit), text.repeat(\"ab\", limit), text.separator)\n"); err != nil {
+		t.Fatalf("Expected the program to run, got %v", err)
+	}
+	if output.String() != "6 ababab -\n" {
+		t.Errorf("Expected the output %q, got %q", "6 ababab -\n", output.String())
+	}
+
+	// Registered values are globals, which programs can't reassign
+	var parseErr *interpreter.ParseError
+	if err := runtime.RunString("limit = 4\n"); !errors.As(err, &parseErr) {
+		t.Errorf("Expected a parse error assigning to a registered value, got %v", err)
+	}
+}
+
+// Runs programs from strings and files, checking declarations are kept between programs unless a program has errors
+func TestRuntimeRun(t *testing.T) {
+	runtime, output := newRuntime()
+	if err := runtime.RunString("var count int64 = 1\n"); err != nil {
+		t.Fatalf("Expected the program to run, got %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "main.lang")
+	if err := os.WriteFile(path, []byte("count = count + 1\nprint(count)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runtime.RunFile(path); err != nil {
+		t.Fatalf("Expected the file to run, got %v", err)
+	}
+	if output.String() != "2\n" {
+		t.Errorf("Expected the output %q, got %q", "2\n", output.String())
+	}
+
+	var parseErr *interpreter.ParseError
+	if err := runtime.RunString("var other = 1\nprint(missing)\n"); !errors.As(err, &parseErr) || len(parseErr.Diagnostics) != 1 {
+		t.Fatalf("Expected a parse error with 1 diagnostic, got %v", err)
+	}
+	if _, ok := runtime.Get("other"); ok {
+		t.Error("Expected the declarations of a program with errors to be undone")
+	}
+
+	var runtimeErr *interpreter.RuntimeError
+	if err := runtime.RunString("print(1 / (count - 2))\n"); !errors.As(err, &runtimeErr) || runtimeErr.Message != "runtime error: integer divide by zero" {
+		t.Errorf("Expected a runtime error dividing by zero, got %v", err)
+	}
+}
+
+// Gets and sets the top-level identifiers of programs from Go
+func TestRuntimeGetSet(t *testing.T) {
+	runtime, _ := newRuntime()
+	if err := runtime.RunString("var name = \"ada\"\nlet id int64 = 1\nvar value int64 | string = 2\n"); err != nil {
+		t.Fatalf("Expected the program to run, got %v", err)
+	}
+
+	if value, ok := runtime.Get("name"); !ok || value != "ada" {
+		t.Errorf("Expected name to be ada, got %v", value)
+	}
+	if _, ok := runtime.Get("missing"); ok {
+		t.Error("Expected missing to not be declared")
+	}
+
+	if err := runtime.Set("name", "bob"); err != nil {
+		t.Errorf("Expected name to be set, got %v", err)
+	}
+	if value, _ := runtime.Get("name"); value != "bob" {
+		t.Errorf("Expected name to be bob, got %v", value)
+	}
+	if err := runtime.Set("value", "text"); err != nil {
+		t.Errorf("Expected a member of the union to be set, got %v", err)
+	}
+
+	for _, test := range []struct {
+		name    string
+		value   any
+		message string
+	}{
+		{"name", 1, "cannot set name to a value of type int"},
+		{"value", 1.5, "cannot set value to a value of type float64"},
+		{"id", int64(2), "cannot set id since it is immutable"},
+		{"print", 1, "cannot set print since it is immutable"},
+		{"missing", 1, "missing is not defined"},
+	} {
+		if err := runtime.Set(test.name, test.value); err == nil || err.Error() != test.message {
+			t.Errorf("Expected the error %q setting %s, got %v", test.message, test.name, err)
+		}
+	}
+}
+
+// Calls functions declared by programs from Go
+func TestRuntimeCall(t *testing.T) {
+	runtime, _ := newRuntime()
+	source := "fn greet(name: string, greeting: string = \"hello\", mark: string = \"!\"): string {\n\treturn greeting\n}\n" +
+		"fn sum(values: ...int64): int64 {\n\tvar total int64 = 0\n\tfor value range values {\n\t\ttotal = total + value\n\t}\n\treturn total\n}\n" +
+		"fn divide(a: int64, b: int64): int64 {\n\treturn a / b\n}\n" +
+		"fn forever() {\n\twhile true {\n\t}\n}\n"
+	if err := runtime.RunString(source); err != nil {
+		t.Fatalf("Expected the program to run, got %v", err)
+	}
+
+	for _, test := range []struct {
+		name   string
+		args   []any
+		result any
+	}{
+		{"greet", []any{"ada"}, "hello"},
+		{"greet", []any{"ada", "hi"}, "hi"},
+		{"greet", []any{"ada", "hi", "?"}, "hi"},
+		{"sum", nil, int64(0)},
+		{"sum", []any{int64(1), int64(2), int64(3)}, int64(6)},
+	} {
+		if result, err := runtime.Call(test.name, test.args...); err != nil || result != test.result {
+			t.Errorf("Expected %s%v to return %v, got %v and %v", test.name, test.args, test.result, result, err)
+		}
+	}
+
+	for _, test := range []struct {
+		name    string
+		args    []any
+		message string
+	}{
+		{"greet", nil, "greet takes 1 to 3 arguments but was called with 0"},
+		{"greet", []any{"a", "b", "c", "d"}, "greet takes 1 to 3 arguments but was called with 4"},
+		{"divide", []any{int64(1)}, "divide takes 2 arguments but was called with 1"},
+		{"greet", []any{1}, "argument 1 of greet cannot be a value of type int"},
+		{"sum", []any{int64(1), "2"}, "argument 2 of sum cannot be a value of type string"},
+		{"missing", nil, "missing is not a function"},
+		{"divide", []any{int64(1), int64(0)}, "runtime error: integer divide by zero (line 12, column 9)"},
+	} {
+		if _, err := runtime.Call(test.name, test.args...); err == nil || err.Error() != test.message {
+			t.Errorf("Expected %s%v to fail with %q, got %v", test.name, test.args, test.message, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := runtime.CallContext(ctx, "forever"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the call to be cancelled, got %v", err)
+	}
+	// The runtime can still be used once a call has failed
+	if result, err := runtime.Call("sum", int64(4)); err != nil || result != int64(4) {
+		t.Errorf("Expected sum(4) to return 4, got %v and %v", result, err)
+	}
+}
+
+// Evaluates expressions and programs
+func TestRuntimeEval(t *testing.T) {
+	runtime, output := newRuntime()
+	runtime.SetLimits(environment.Limits{MaxSteps: 1000})
+
+	if value, def, err := runtime.Eval("var x int64 = 20"); err != nil || value != nil || def != nil {
+		t.Fatalf("Expected the program to run without a value, got %v, %v and %v", value, def, err)
+	}
+	if value, def, err := runtime.Eval("x * 2 + 2"); err != nil || value != int64(42) || !def.Equals(int64Def) {
+		t.Errorf("Expected the int64 42, got %v of type %v and %v", value, def, err)
+	}
+	if _, _, err := runtime.Eval("print(x)"); err != nil || output.String() != "20\n" {
+		t.Errorf("Expected x to be printed, got %q and %v", output.String(), err)
+	}
+
+	var parseErr *interpreter.ParseError
+	if _, _, err := runtime.Eval("x +"); !errors.As(err, &parseErr) {
+		t.Errorf("Expected a parse error, got %v", err)
+	}
+	var runtimeErr *interpreter.RuntimeError
+	if _, _, err := runtime.Eval("x / (x - 20)"); !errors.As(err, &runtimeErr) {
+		t.Errorf("Expected a runtime error, got %v", err)
+	}
+	if _, _, err := runtime.Eval("while true {\n}"); !errors.Is(err, environment.ErrStepLimit) {
+		t.Errorf("Expected the step limit to be exceeded, got %v", err)
+	}
+}
@@ -21,8 +21,11 @@ const (
 	TypeStruct
 	TypeStructInstance
 	TypeAny
+	TypeUnion
 
 	TypeModule
+	// A type itself, such as the name declared by a type declaration
+	TypeType
 
 	TypeNil
 )
@@ -50,7 +53,11 @@ func (def GenericTypeDef) Equals(other TypeDef) bool {
 	if def.Type == TypeAny || other.GetGenericType() == TypeAny {
 		return true
 	}
-	return def == other.(GenericTypeDef)
+	if unionDef, ok := other.(UnionDef); ok {
+		return unionDef.Contains(def)
+	}
+	otherDef, ok := other.(GenericTypeDef)
+	return ok && def == otherDef
 }
 
 func (def GenericTypeDef) IsInteger() bool {
@@ -66,6 +73,10 @@ func (def GenericTypeDef) IsNumber() bool {
 type FuncDef struct {
 	GenericTypeDef
 	Args []TypeDef
+	// Names of the arguments, used to match named arguments at call sites (nil if the function doesn't support them)
+	ArgNames []string
+	// The number of arguments before the variadic argument that have a default value, these are always the last arguments
+	OptionalArgs int
 	// Whether or not the function has a variable number of arguments
 	Variadic   bool
 	ReturnType TypeDef
@@ -80,19 +91,33 @@ func NewFuncDef(args []TypeDef, variadic bool, returnType TypeDef) FuncDef {
 	}
 }
 
+// Gets the number of arguments that are not collected by a variadic argument
+func (def FuncDef) FixedArgs() int {
+	if def.Variadic {
+		return len(def.Args) - 1
+	}
+	return len(def.Args)
+}
+
 func (def FuncDef) Equals(other TypeDef) bool {
 	if other.GetGenericType() == TypeAny {
 		return true
 	}
+	if unionDef, ok := other.(UnionDef); ok {
+		return unionDef.Contains(def)
+	}
 	otherDef, ok := other.(FuncDef)
-	if !ok || len(def.Args) != len(otherDef.Args) {
+	if !ok || len(def.Args) != len(otherDef.Args) || def.Variadic != otherDef.Variadic {
 		return false
 	}
 	for i, argDef := range def.Args {
-		if otherDef.Args[i].Equals(argDef) {
+		if !otherDef.Args[i].Equals(argDef) {
 			return false
 		}
 	}
+	if def.ReturnType == nil || otherDef.ReturnType == nil {
+		return def.ReturnType == nil && otherDef.ReturnType == nil
+	}
 	return def.ReturnType.Equals(otherDef.ReturnType)
 }
 
@@ -114,6 +139,9 @@ func (def MapDef) Equals(other TypeDef) bool {
 	if other.GetGenericType() == TypeAny {
 		return true
 	}
+	if unionDef, ok := other.(UnionDef); ok {
+		return unionDef.Contains(def)
+	}
 	otherDef, ok := other.(MapDef)
 	return ok && def.KeyType.Equals(otherDef.KeyType) && def.ValueType.Equals(otherDef.ValueType)
 }
@@ -136,28 +164,62 @@ func (def ArrayDef) Equals(other TypeDef) bool {
 	if other.GetGenericType() == TypeAny {
 		return true
 	}
+	if unionDef, ok := other.(UnionDef); ok {
+		return unionDef.Contains(def)
+	}
 	otherDef, ok := other.(ArrayDef)
-	return ok && def.ElementType.Equals(otherDef.ElementType)
+	// If the other array has a fixed size the sizes must match so that the size can be relied on
+	return ok && def.ElementType.Equals(otherDef.ElementType) && (otherDef.Size == -1 || def.Size == otherDef.Size)
 }
 
+// Definition of a struct, the generic type is TypeStruct for the declared struct itself
+// and TypeStructInstance for instances of it
 type StructDef struct {
 	GenericTypeDef
 	Properties   map[string]int
 	PropertyDefs []TypeDef
-	Name         string
+	// The number of properties that hold data, these are always before the methods
+	DataProperties int
+	// Properties that can only be set when the struct is created
+	ReadonlyProperties map[string]bool
+	Name               string
 }
 
 func NewStructDef(properties map[string]int, propertyDefs []TypeDef, name string) StructDef {
 	return StructDef{
-		GenericTypeDef: GenericTypeDef{TypeStruct},
-		Properties:     properties,
-		PropertyDefs:   propertyDefs,
-		Name:           name,
+		GenericTypeDef:     GenericTypeDef{TypeStruct},
+		Properties:         properties,
+		PropertyDefs:       propertyDefs,
+		ReadonlyProperties: make(map[string]bool),
+		Name:               name,
+	}
+}
+
+// Gets the definition for an instance of the struct
+func (def StructDef) InstanceDef() StructDef {
+	def.Type = TypeStructInstance
+	return def
+}
+
+// Gets the name of the property at an index
+func (def StructDef) GetPropertyName(index int) string {
+	for name, propertyIndex := range def.Properties {
+		if propertyIndex == index {
+			return name
+		}
 	}
+	return ""
 }
 
 func (def StructDef) Equals(other TypeDef) bool {
-	return other.GetGenericType() == TypeAny
+	if other.GetGenericType() == TypeAny {
+		return true
+	}
+	if unionDef, ok := other.(UnionDef); ok {
+		return unionDef.Contains(def)
+	}
+	otherDef, ok := other.(StructDef)
+	return ok && def.Type == otherDef.Type && def.Name == otherDef.Name
 }
 
 type ModuleDef struct {
@@ -175,3 +237,88 @@ func NewModuleDef(properties map[string]TypeDef) ModuleDef {
 func (def ModuleDef) Equals(other TypeDef) bool {
 	return false
 }
+
+// Definition of a name declared with the type keyword, Def is the type that the name refers to
+type TypeDeclarationDef struct {
+	GenericTypeDef
+	Def TypeDef
+}
+
+func NewTypeDeclarationDef(def TypeDef) TypeDeclarationDef {
+	return TypeDeclarationDef{
+		GenericTypeDef: GenericTypeDef{TypeType},
+		Def:            def,
+	}
+}
+
+func (def TypeDeclarationDef) Equals(other TypeDef) bool {
+	return false
+}
+
+// Definition of a distinct type, values of it are represented the same way as the underlying type
+// but can't be used in place of it (or the other way round) without an explicit conversion
+type NamedTypeDef struct {
+	GenericTypeDef
+	Name       string
+	Methods    map[string]int
+	MethodDefs []TypeDef
+}
+
+func NewNamedTypeDef(name string, underlying GenericTypeDef) NamedTypeDef {
+	return NamedTypeDef{
+		GenericTypeDef: underlying,
+		Name:           name,
+		Methods:        make(map[string]int),
+		MethodDefs:     make([]TypeDef, 0),
+	}
+}
+
+func (def NamedTypeDef) Equals(other TypeDef) bool {
+	if other.GetGenericType() == TypeAny {
+		return true
+	}
+	if unionDef, ok := other.(UnionDef); ok {
+		return unionDef.Contains(def)
+	}
+	otherDef, ok := other.(NamedTypeDef)
+	return ok && def.Name == otherDef.Name
+}
+
+// Definition of a value that can be of any one of the types in the union, the type is found at runtime from the value
+type UnionDef struct {
+	GenericTypeDef
+	Types []TypeDef
+}
+
+func NewUnionDef(types []TypeDef) UnionDef {
+	return UnionDef{
+		GenericTypeDef: GenericTypeDef{TypeUnion},
+		Types:          types,
+	}
+}
+
+// Checks whether a value of the other definition can be used as a value of the union
+func (def UnionDef) Contains(other TypeDef) bool {
+	for _, memberDef := range def.Types {
+		if other.Equals(memberDef) {
+			return true
+		}
+	}
+	return false
+}
+
+func (def UnionDef) Equals(other TypeDef) bool {
+	if other.GetGenericType() == TypeAny {
+		return true
+	}
+	otherDef, ok := other.(UnionDef)
+	if !ok {
+		return false
+	}
+	for _, memberDef := range def.Types {
+		if !otherDef.Contains(memberDef) {
+			return false
+		}
+	}
+	return true
+}
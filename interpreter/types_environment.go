@@ -2,14 +2,20 @@ package interpreter
 
 type TypeEnvironment struct {
 	identifiers map[string]TypeDef
-	returnType  TypeDef
-	returned    bool
+	// The slots of identifiers in the runtime environment, assigned in the order identifiers are first declared
+	slots map[string]int
+	// The lines that identifiers which cannot be reassigned were declared at
+	immutableLines map[string]int
+	returnType     TypeDef
+	returned       bool
+	// Set once code after the return statement has been reported as unreachable
+	unreachable bool
 	parent      *TypeEnvironment
 	Depth       int
 }
 
 func NewTypeEnvironment(parent *TypeEnvironment, returnType TypeDef, depth int) *TypeEnvironment {
-	return &TypeEnvironment{make(map[string]TypeDef), returnType, false, parent, depth}
+	return &TypeEnvironment{make(map[string]TypeDef), make(map[string]int), make(map[string]int), returnType, false, false, parent, depth}
 }
 
 // Creates a new type environment with the current instance as it's parent
@@ -45,8 +51,85 @@ func (e *TypeEnvironment) getWithDepthCounter(name string, depth int) (TypeDef,
 	return nil, -1
 }
 
+// Gets the current definition of a distinct type by it's name
+func (e *TypeEnvironment) GetTypeDeclaration(name string) (NamedTypeDef, bool) {
+	def, _ := e.Get(name)
+	if declarationDef, ok := def.(TypeDeclarationDef); ok {
+		namedDef, ok := declarationDef.Def.(NamedTypeDef)
+		return namedDef, ok && namedDef.Name == name
+	}
+	return NamedTypeDef{}, false
+}
+
 func (e *TypeEnvironment) Set(name string, value TypeDef) {
 	e.identifiers[name] = value
+	e.declareSlot(name)
+	delete(e.immutableLines, name)
+}
+
+// Sets an identifier that cannot be reassigned, storing the line it was declared at for error messages
+func (e *TypeEnvironment) SetImmutable(name string, value TypeDef, line int) {
+	e.identifiers[name] = value
+	e.declareSlot(name)
+	e.immutableLines[name] = line
+}
+
+// Gives an identifier the next slot of the environment if it doesn't have one, redeclarations keep their slot
+func (e *TypeEnvironment) declareSlot(name string) {
+	if _, ok := e.slots[name]; !ok {
+		e.slots[name] = len(e.slots)
+	}
+}
+
+// Copies the identifiers declared in the environment, so that the declarations made after can be undone by restore
+func (e *TypeEnvironment) snapshot() TypeEnvironment {
+	snapshot := *e
+	snapshot.identifiers = make(map[string]TypeDef, len(e.identifiers))
+	for name, def := range e.identifiers {
+		snapshot.identifiers[name] = def
+	}
+	snapshot.slots = make(map[string]int, len(e.slots))
+	for name, slot := range e.slots {
+		snapshot.slots[name] = slot
+	}
+	snapshot.immutableLines = make(map[string]int, len(e.immutableLines))
+	for name, line := range e.immutableLines {
+		snapshot.immutableLines[name] = line
+	}
+	return snapshot
+}
+
+func (e *TypeEnvironment) restore(snapshot TypeEnvironment) {
+	*e = snapshot
+}
+
+// Gets the slot of an identifier and the depth of the parent environment it is declared in
+func (e *TypeEnvironment) GetSlot(name string) (int, int) {
+	depth := 0
+	for env := e; env != nil; env = env.parent {
+		if slot, ok := env.slots[name]; ok {
+			return slot, depth
+		}
+		depth++
+	}
+	return -1, -1
+}
+
+// Gets the line an identifier was declared at if the identifier cannot be reassigned
+func (e *TypeEnvironment) GetImmutableLine(name string) (int, bool) {
+	if _, ok := e.identifiers[name]; ok {
+		return e.getLocalImmutableLine(name)
+	}
+	if e.parent != nil {
+		return e.parent.GetImmutableLine(name)
+	}
+	return 0, false
+}
+
+// Gets the line an identifier was declared at if it is immutable and declared in this environment, ignoring parents
+func (e *TypeEnvironment) getLocalImmutableLine(name string) (int, bool) {
+	line, ok := e.immutableLines[name]
+	return line, ok
 }
 
 func (e *TypeEnvironment) GetReturned() bool {
@@ -14,9 +14,11 @@ type TypeNodeGenerator interface {
 	GetInequalityComparison(comparison nodes.ComparisonType, leftSide environment.Node, rightSide environment.Node) environment.Node
 	GetArrayInitialization(elements []environment.Node) environment.Node
 	GetArrayIndex(array environment.Node, index environment.Node) environment.Node
-	GetArrayAssignment(array environment.Node, index environment.Node, value environment.Node) environment.Node
+	GetArrayAssignment(arrayIndex environment.Node, value environment.Node) environment.Node
 	GetLoopArray(valIdentifier string, indexIdentifier string, array environment.Node, inner *nodes.Block) environment.Node
 	ArrayIndexDetails(node environment.Node) (array environment.Node, index environment.Node, ok bool)
+	PackArray(values []any) any
+	GetDestructure(identifiers []string, slots []int, indexes []int, value environment.Node) environment.Node
 }
 
 func GetGenericTypeNode(def TypeDef) TypeNodeGenerator {
@@ -26,6 +28,8 @@ func GetGenericTypeNode(def TypeDef) TypeNodeGenerator {
 		return TypeNodeGeneratorAny[string]{}
 	case TypeBool:
 		return TypeNodeGeneratorAny[bool]{}
+	case TypeAny, TypeUnion:
+		return TypeNodeGeneratorAny[any]{}
 	case TypeInt8:
 		return TypeNodeGeneratorNumber[int8]{}
 	case TypeInt16:
@@ -65,13 +69,12 @@ func (tn TypeNodeGeneratorAny[T]) GetArrayIndex(array environment.Node, index en
 	}
 }
 
-func (tn TypeNodeGeneratorAny[T]) GetArrayAssignment(array environment.Node, index environment.Node, value environment.Node) environment.Node {
+// Creates the assignment to the element of an array node created by GetArrayIndex, which keeps it's position for errors
+func (tn TypeNodeGeneratorAny[T]) GetArrayAssignment(arrayIndex environment.Node, value environment.Node) environment.Node {
+	index, _ := arrayIndex.(*nodes.ArrayIndex[T])
 	return &nodes.ArrayAssignment[T]{
-		ArrayIndex: &nodes.ArrayIndex[T]{
-			Array: array,
-			Index: index,
-		},
-		Value: value,
+		ArrayIndex: index,
+		Value:      value,
 	}
 }
 
@@ -86,7 +89,28 @@ func (tn TypeNodeGeneratorAny[T]) GetLoopArray(valIdentifier string, indexIdenti
 
 func (tn TypeNodeGeneratorAny[T]) ArrayIndexDetails(node environment.Node) (array environment.Node, index environment.Node, ok bool) {
 	val, ok := node.(*nodes.ArrayIndex[T])
-	return val.Array, val.Index, ok
+	if !ok {
+		return nil, nil, false
+	}
+	return val.Array, val.Index, true
+}
+
+// Converts values to an array of the generic type, used to pass the arguments collected by a variadic argument
+func (tn TypeNodeGeneratorAny[T]) PackArray(values []any) any {
+	array := make([]T, len(values))
+	for i, val := range values {
+		array[i], _ = val.(T)
+	}
+	return array
+}
+
+func (tn TypeNodeGeneratorAny[T]) GetDestructure(identifiers []string, slots []int, indexes []int, value environment.Node) environment.Node {
+	return &nodes.Destructure[T]{
+		Identifiers: identifiers,
+		Slots:       slots,
+		Indexes:     indexes,
+		Value:       value,
+	}
 }
 
 func (tn TypeNodeGeneratorAny[T]) GetMathsOperation(operation nodes.MathsOperationType, leftSide environment.Node, rightSide environment.Node) environment.Node {
@@ -0,0 +1,156 @@
+package interpreter
+
+// Values are stored at runtime as Go values, so when the type of a value is only known at runtime
+// (such as a value of type any or a union) the type can be found from the dynamic type of the Go value
+
+import (
+	"main/interpreter/environment"
+	"reflect"
+)
+
+var runtimeAnyType = reflect.TypeOf((*any)(nil)).Elem()
+var runtimeCallableType = reflect.TypeOf((*environment.Callable)(nil)).Elem()
+
+// Gets the Go type that values of a definition are stored as at runtime
+func GetRuntimeType(def TypeDef) reflect.Type {
+	switch def := def.(type) {
+	case ArrayDef:
+		return reflect.SliceOf(GetRuntimeType(def.ElementType))
+	case MapDef:
+		return reflect.MapOf(GetRuntimeType(def.KeyType), GetRuntimeType(def.ValueType))
+	case StructDef:
+		// Struct instances are arrays of their properties and methods
+		return reflect.TypeOf([]any{})
+	case FuncDef:
+		return runtimeCallableType
+	}
+
+	switch def.GetGenericType() {
+	case TypeInt8:
+		return reflect.TypeOf(int8(0))
+	case TypeInt16:
+		return reflect.TypeOf(int16(0))
+	case TypeInt32:
+		return reflect.TypeOf(int32(0))
+	case TypeInt64:
+		return reflect.TypeOf(int64(0))
+	case TypeUint8:
+		return reflect.TypeOf(uint8(0))
+	case TypeUint16:
+		return reflect.TypeOf(uint16(0))
+	case TypeUint32:
+		return reflect.TypeOf(uint32(0))
+	case TypeUint64:
+		return reflect.TypeOf(uint64(0))
+	case TypeFloat32:
+		return reflect.TypeOf(float32(0))
+	case TypeFloat64:
+		return reflect.TypeOf(float64(0))
+	case TypeString:
+		return reflect.TypeOf("")
+	case TypeBool:
+		return reflect.TypeOf(false)
+	}
+	return runtimeAnyType
+}
+
+// Creates a function that checks whether a value at runtime is of the type in the definition
+func GetRuntimeTypeCheck(def TypeDef) func(any) bool {
+	switch def := def.(type) {
+	case UnionDef:
+		checks := make([]func(any) bool, len(def.Types))
+		for i, memberDef := range def.Types {
+			checks[i] = GetRuntimeTypeCheck(memberDef)
+		}
+		return func(v any) bool {
+			for _, check := range checks {
+				if check(v) {
+					return true
+				}
+			}
+			return false
+		}
+	case FuncDef:
+		// Every kind of function implements Callable so functions are only checked by that
+		return func(v any) bool {
+			_, ok := v.(environment.Callable)
+			return ok
+		}
+	}
+
+	if def.GetGenericType() == TypeAny {
+		return func(v any) bool {
+			return true
+		}
+	}
+	runtimeType := GetRuntimeType(def)
+	return func(v any) bool {
+		return reflect.TypeOf(v) == runtimeType
+	}
+}
+
+// The check of a type used by nodes, which keeps the definition it was created from so that it can be cached
+type runtimeTypeCheck struct {
+	def     TypeDef
+	matches func(any) bool
+}
+
+func newRuntimeTypeCheck(def TypeDef) *runtimeTypeCheck {
+	return &runtimeTypeCheck{def: def, matches: GetRuntimeTypeCheck(def)}
+}
+
+func (c *runtimeTypeCheck) Matches(value any) bool {
+	return c.matches(value)
+}
+
+// Checks whether values of two definitions can be told apart from each other at runtime
+func isDistinctAtRuntime(def TypeDef, other TypeDef) bool {
+	if def.GetGenericType() == TypeFunc && other.GetGenericType() == TypeFunc {
+		return false
+	}
+	return GetRuntimeType(def) != GetRuntimeType(other)
+}
+
+// Checks whether values of a definition can be told apart at runtime from the values of every other type, so that
+// values of type any can be checked against it. Struct instances are all arrays of any, named types are stored as the
+// type they are based on and every function is checked as being callable.
+func isIdentifiableAtRuntime(def TypeDef) bool {
+	switch def := def.(type) {
+	case UnionDef:
+		for _, memberDef := range def.Types {
+			if !isIdentifiableAtRuntime(memberDef) {
+				return false
+			}
+		}
+		return true
+	case StructDef, NamedTypeDef, FuncDef:
+		return false
+	case ArrayDef:
+		// Arrays of any or of a union are stored the same way as struct instances
+		return GetRuntimeType(def.ElementType) != runtimeAnyType && isIdentifiableAtRuntime(def.ElementType)
+	case MapDef:
+		for _, def := range []TypeDef{def.KeyType, def.ValueType} {
+			if GetRuntimeType(def) == runtimeAnyType || !isIdentifiableAtRuntime(def) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
+
+// Checks whether a value of a definition could be a value of the target definition at runtime
+func canBeOfType(def TypeDef, target TypeDef) bool {
+	if def.GetGenericType() == TypeAny {
+		return true
+	}
+	if unionDef, ok := target.(UnionDef); ok {
+		for _, memberDef := range unionDef.Types {
+			if canBeOfType(def, memberDef) {
+				return true
+			}
+		}
+		return false
+	}
+	return target.Equals(def)
+}
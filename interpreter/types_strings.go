@@ -0,0 +1,105 @@
+package interpreter
+
+// Type definitions are formatted the way they are written in source code, so that they can be shown to users such as
+// in the hover of the language server
+
+import (
+	"strconv"
+	"strings"
+)
+
+// The names of the generic types that are written as keywords
+var genericTypeNames = map[GenericType]string{
+	TypeInt8:    "int8",
+	TypeInt16:   "int16",
+	TypeInt32:   "int32",
+	TypeInt64:   "int64",
+	TypeUint8:   "uint8",
+	TypeUint16:  "uint16",
+	TypeUint32:  "uint32",
+	TypeUint64:  "uint64",
+	TypeFloat32: "float32",
+	TypeFloat64: "float64",
+	TypeString:  "string",
+	TypeBool:    "bool",
+	TypeAny:     "any",
+	TypeModule:  "module",
+	TypeNil:     "nil",
+}
+
+func (def GenericTypeDef) String() string {
+	if name, ok := genericTypeNames[def.Type]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func (def FuncDef) String() string {
+	var builder strings.Builder
+	builder.WriteString("fn(")
+	for i, argDef := range def.Args {
+		if i > 0 {
+			builder.WriteString(", ")
+		}
+		if i < len(def.ArgNames) {
+			builder.WriteString(def.ArgNames[i] + ": ")
+		}
+		if def.Variadic && i == len(def.Args)-1 {
+			builder.WriteString("...")
+		}
+		builder.WriteString(TypeDefString(argDef))
+	}
+	builder.WriteString(")")
+	if def.ReturnType != nil {
+		builder.WriteString(": " + TypeDefString(def.ReturnType))
+	}
+	return builder.String()
+}
+
+func (def MapDef) String() string {
+	return "map[" + TypeDefString(def.KeyType) + "]" + TypeDefString(def.ValueType)
+}
+
+func (def ArrayDef) String() string {
+	size := ""
+	if def.Size != -1 {
+		size = strconv.Itoa(def.Size)
+	}
+	return "[" + size + "]" + TypeDefString(def.ElementType)
+}
+
+func (def StructDef) String() string {
+	if def.Type == TypeStruct {
+		return "struct " + def.Name
+	}
+	return def.Name
+}
+
+func (def ModuleDef) String() string {
+	return "module"
+}
+
+func (def TypeDeclarationDef) String() string {
+	return "type " + TypeDefString(def.Def)
+}
+
+func (def NamedTypeDef) String() string {
+	return def.Name
+}
+
+func (def UnionDef) String() string {
+	names := make([]string, len(def.Types))
+	for i, memberDef := range def.Types {
+		names[i] = TypeDefString(memberDef)
+	}
+	return strings.Join(names, " | ")
+}
+
+// Formats a type definition the way it is written in source code, the definition can be nil for functions that don't
+// return a value
+func TypeDefString(def TypeDef) string {
+	if stringer, ok := def.(interface{ String() string }); ok {
+		return stringer.String()
+	}
+	return "nil"
+}
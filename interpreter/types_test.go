@@ -0,0 +1,104 @@
+package interpreter_test
+
+import (
+	"main/interpreter"
+	"main/interpreter/environment"
+	standardlibrary "main/standard_library"
+	"testing"
+)
+
+// Narrows unions containing structs and named types, checking the arm or type check matching the value is used
+func TestTypeNarrowing(t *testing.T) {
+	declarations := "struct Pet {\n\tname: string\n}\nstruct Point {\n\tx: int64\n\ty: int64\n}\ntype Celsius float64\n"
+	for _, test := range []struct {
+		name   string
+		source string
+		output string
+	}{
+		{
+			name:   "struct in a union",
+			source: "fn describe(v: Pet | int64) {\n\tmatch v {\n\t\tp: Pet => print(p.name)\n\t\tn: int64 => print(n)\n\t}\n}\ndescribe(Pet{name: \"rex\"})\ndescribe(3)\n",
+			output: "rex\n3\n",
+		},
+		{
+			name:   "struct checked with is",
+			source: "var v Point | string = Point{x: 1, y: 2}\nprint(v is Point, v is string)\nv = \"a\"\nprint(v is Point, v is string)\n",
+			output: "true false\nfalse true\n",
+		},
+		{
+			name:   "named type in a union",
+			source: "fn describe(v: Celsius | string) {\n\tmatch v {\n\t\tc: Celsius => print(float64(c) * 2.0)\n\t\ts: string => print(s)\n\t}\n}\ndescribe(Celsius(1.5))\ndescribe(\"cold\")\n",
+			output: "3\ncold\n",
+		},
+		{
+			// Named values are stored as the type they are based on
+			name:   "named value in any",
+			source: "var v any = Celsius(1.5)\nprint(v is float64, v is string)\n",
+			output: "true false\n",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			// The bytecode engine doesn't support struct and type declarations
+			output, err := run(t, declarations+test.source, interpreter.EngineTreeWalker, environment.Limits{})
+			if err != nil {
+				t.Fatalf("Expected the program to run, got %v", err)
+			}
+			if output != test.output {
+				t.Errorf("Expected the output %q, got %q", test.output, output)
+			}
+		})
+	}
+}
+
+// Checks values against types that can't be told apart from others at runtime, checking each is reported once as a
+// type error
+func TestTypeNarrowingErrors(t *testing.T) {
+	globalDefs, _ := interpreter.BindValues(standardlibrary.Globals)
+	declarations := "struct Pet {\n\tname: string\n}\nstruct Point {\n\tx: int64\n\ty: int64\n}\ntype Celsius float64\nvar v any = 1\n"
+	for _, test := range []struct {
+		name    string
+		source  string
+		message string
+	}{
+		{
+			name:    "struct in a match on any",
+			source:  "match v {\n\tp: Point => print(p.y)\n\t_ => print(0)\n}\n",
+			message: "Values of type any can't be checked against structs, named types, functions, or arrays and maps of any or unions, since they can't be told apart at runtime.",
+		},
+		{
+			name:    "struct checked with is on any",
+			source:  "print(v is Pet)\n",
+			message: "Values of type any can't be checked against structs, named types, functions, or arrays and maps of any or unions, since they can't be told apart at runtime.",
+		},
+		{
+			name:    "named type checked with is on any",
+			source:  "print(v is Celsius)\n",
+			message: "Values of type any can't be checked against structs, named types, functions, or arrays and maps of any or unions, since they can't be told apart at runtime.",
+		},
+		{
+			name:    "array of any checked with is on any",
+			source:  "print(v is []any)\n",
+			message: "Values of type any can't be checked against structs, named types, functions, or arrays and maps of any or unions, since they can't be told apart at runtime.",
+		},
+		{
+			name:    "union of structs",
+			source:  "var u Pet | Point = Pet{name: \"rex\"}\n",
+			message: "Types in a union must be stored as different types at runtime, so they can be told apart.",
+		},
+		{
+			name:    "union of a named type and the type it is based on",
+			source:  "var u Celsius | float64 = 1.5\n",
+			message: "Types in a union must be stored as different types at runtime, so they can be told apart.",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, diagnostics := interpreter.NewParser(declarations+test.source, "test.lang", globalDefs, nil).Parse()
+			if len(diagnostics) != 1 {
+				t.Fatalf("Expected 1 diagnostic, got %v", diagnostics)
+			}
+			if diagnostic := diagnostics[0]; diagnostic.Code != interpreter.DiagnosticTypeError || diagnostic.Message != test.message {
+				t.Errorf("Expected the type error %q, got %s", test.message, diagnostic)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package vm
+
+import (
+	"main/interpreter/environment"
+	"main/interpreter/nodes"
+)
+
+type Opcode uint8
+
+const (
+	// Pushes the constant at index A
+	OpConstant Opcode = iota
+	// Pushes nil
+	OpNil
+	OpPop
+	OpDup
+	// Pushes the local variable in slot A of the current frame
+	OpGetLocal
+	// Pops a value in to the local variable in slot A of the current frame
+	OpSetLocal
+	OpGetGlobal
+	OpSetGlobal
+	// Maths operations pop the right then left side and push the result, B is the NumberKind of both sides
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	// Comparisons with a NumberKind in B, equality of any two values uses OpEqual
+	OpGreater
+	OpLess
+	OpGreaterOrEqual
+	OpLessOrEqual
+	OpEqual
+	OpNot
+	// Converts any integer to an int64 for range loops
+	OpToInt64
+	// Jumps to the instruction at A
+	OpJump
+	// Pops a bool and jumps to A if it is false
+	OpJumpIfFalse
+	// Jumps to A if the bool on the top of the stack is false, otherwise pops it. Used to short circuit and
+	OpJumpIfFalseOrPop
+	// Jumps to A if the bool on the top of the stack is true, otherwise pops it. Used to short circuit or
+	OpJumpIfTrueOrPop
+	// Jumps to B if the local variable in slot A was passed a value instead of being left for it's default value
+	OpJumpIfNotDefault
+	// Pushes a closure of the function at index A
+	OpClosure
+	// Calls the function below A arguments on the stack, nil arguments use the argument's default value.
+	// B is the index of the call in the call sites of the function.
+	OpCall
+	// Calls the function below A arguments on the stack like OpCall, in place of the current frame if the function is
+	// a closure. Followed by OpReturn, which returns the result if the function is a Go function.
+	OpTailCall
+	OpReturn
+	OpReturnNil
+	// Pushes the value passed in place of an omitted argument
+	OpDefaultArg
+	// Pushes the value passed to the virtual machine as a global with the name in constant A, such as print
+	OpGlobalValue
+	// Pushes the built in module with the name in constant A
+	OpImport
+	// Pops a key then a module and pushes the module's value for the key
+	OpModuleValue
+	// Pushes a new array of length B using the array operations at index A
+	OpMakeArray
+	// Pops a value and sets it at index B of the array on top of the stack using the array operations at A
+	OpInitElement
+	// Pops an index then an array and pushes the element using the array operations at A
+	OpIndex
+	// Pops an index, an array then a value, sets the element and pushes the value using the array operations at A
+	OpSetIndex
+	// Pops an int64 index then an array and pushes the element without checking the index, used for loops and destructuring
+	OpElement
+	// Pops an array and pushes it's length as an int64 using the array operations at A
+	OpLen
+)
+
+// The type of the numbers an opcode operates on, so that operations are performed without reflection
+type NumberKind int32
+
+const (
+	KindInt8 NumberKind = iota
+	KindInt16
+	KindInt32
+	KindInt64
+	KindUint8
+	KindUint16
+	KindUint32
+	KindUint64
+	KindFloat32
+	KindFloat64
+)
+
+type Instruction struct {
+	Op Opcode
+	A  int32
+	B  int32
+}
+
+// A function compiled to bytecode, the main program is compiled to a function with no arguments
+type Function struct {
+	Name string
+	Line int
+	Code []Instruction
+	// The position of the statement each instruction was compiled from, or of the expression for instructions that
+	// can fail such as calls and divisions
+	Positions []environment.Position
+	// The positions of the function calls made by the function, used for the call stack of runtime errors
+	CallSites []environment.Position
+	// The number of arguments the function takes, including the variadic argument
+	NumArgs int
+	// The number of local variable slots, starting with the arguments
+	NumLocals int
+	// Set if the function is variadic, converts the trailing arguments in to the array passed as the last argument
+	PackVariadic func(args []any) any
+}
+
+type Program struct {
+	File      string
+	Main      *Function
+	Functions []*Function
+	Constants []any
+	// Operations for the arrays used by the program, referenced by array opcodes
+	ArrayOperations []nodes.ArrayOperations
+	// The number of slots used by the variables of the main program
+	NumGlobals int
+}
@@ -0,0 +1,549 @@
+package vm
+
+import (
+	"fmt"
+	"main/interpreter/environment"
+	"main/interpreter/nodes"
+	"strconv"
+)
+
+// Mirrors an environment that the tree walking interpreter would create, mapping identifiers to slots
+type scope struct {
+	slots  map[string]int
+	parent *scope
+	unit   *compileUnit
+}
+
+// A function being compiled, the variables of the main program are stored as globals
+type compileUnit struct {
+	function *Function
+	isMain   bool
+	// The scope the function was declared in
+	enclosing *scope
+	// How many loops the compiler is currently inside of in the function
+	loopDepth int
+	// The position of the statement being compiled, given to each instruction for runtime errors
+	position environment.Position
+}
+
+// A function declaration waiting for it's body to be compiled, bodies are compiled after the main program so that
+// every global they reference has been given a slot
+type pendingFunction struct {
+	function    *Function
+	declaration *nodes.FuncDeclaration
+	enclosing   *scope
+}
+
+type compiler struct {
+	program *Program
+	unit    *compileUnit
+	scope   *scope
+	pending []pendingFunction
+}
+
+// An error for a construct the compiler can't compile, the program should be run by the tree walking interpreter instead
+type unsupportedError struct {
+	construct string
+	// The line of the statement using the construct, 0 if it isn't in a statement such as a default argument
+	line int
+}
+
+func (e unsupportedError) Error() string {
+	message := "the bytecode engine does not support " + e.construct
+	if e.line > 0 {
+		message += " (line " + strconv.Itoa(e.line) + ")"
+	}
+	return message
+}
+
+// Compiles an AST produced by the parser to bytecode.
+// Returns an error if the AST uses features that are not supported by the virtual machine.
+func Compile(ast []environment.Node, fileName string) (program *Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			unsupported, ok := r.(unsupportedError)
+			if !ok {
+				panic(r)
+			}
+			program, err = nil, unsupported
+		}
+	}()
+
+	c := &compiler{
+		program: &Program{
+			File: fileName,
+			Main: &Function{Name: "main"},
+		},
+	}
+	c.unit = &compileUnit{function: c.program.Main, isMain: true}
+	c.scope = &scope{slots: make(map[string]int), unit: c.unit}
+
+	// Functions, structs and types are declared before the other statements in the same way as the tree walking
+	// interpreter
+	for _, node := range ast {
+		if isHoisted(node) {
+			c.compileStatement(node)
+		}
+	}
+	for _, node := range ast {
+		if !isHoisted(node) {
+			c.compileStatement(node)
+		}
+	}
+	c.emit(OpReturnNil, 0, 0)
+
+	for len(c.pending) > 0 {
+		pending := c.pending[0]
+		c.pending = c.pending[1:]
+		c.compileFunctionBody(pending)
+	}
+	return c.program, nil
+}
+
+func isHoisted(node environment.Node) bool {
+	switch node.(type) {
+	case *nodes.FuncDeclaration, *nodes.StructDeclaration, *nodes.TypeDeclaration:
+		return true
+	}
+	return false
+}
+
+func (c *compiler) unsupported(construct ...any) {
+	panic(unsupportedError{construct: fmt.Sprint(construct...), line: c.unit.position.Line})
+}
+
+// Names the constructs of the language that the compiler doesn't support
+func unsupportedConstruct(node environment.Node) string {
+	switch node.(type) {
+	case *nodes.StructDeclaration:
+		return "struct declarations"
+	case *nodes.StructProperty:
+		return "struct properties"
+	case *nodes.StructPropertyAssignment:
+		return "assignments to struct properties"
+	case *nodes.TypeDeclaration:
+		return "type declarations"
+	case *nodes.TypeMethod:
+		return "methods of types"
+	case *nodes.TypeSwitch:
+		return "match statements"
+	case *nodes.TypeCheck:
+		return "is expressions"
+	}
+	return fmt.Sprintf("nodes of type %T", node)
+}
+
+func (c *compiler) emit(op Opcode, a int, b int) int {
+	return c.emitAt(c.unit.position, op, a, b)
+}
+
+// Emits an instruction that can fail at the position of it's expression instead of the statement, such as a call
+func (c *compiler) emitAt(position environment.Position, op Opcode, a int, b int) int {
+	c.unit.function.Code = append(c.unit.function.Code, Instruction{op, int32(a), int32(b)})
+	c.unit.function.Positions = append(c.unit.function.Positions, position)
+	return len(c.unit.function.Code) - 1
+}
+
+// Sets the jump target of the instruction at pos to the next instruction
+func (c *compiler) patchJump(pos int) {
+	code := c.unit.function.Code
+	if code[pos].Op == OpJumpIfNotDefault {
+		code[pos].B = int32(len(code))
+	} else {
+		code[pos].A = int32(len(code))
+	}
+}
+
+func (c *compiler) addConstant(value any) int {
+	c.program.Constants = append(c.program.Constants, value)
+	return len(c.program.Constants) - 1
+}
+
+func (c *compiler) addArrayOperations(ops nodes.ArrayOperations) int {
+	c.program.ArrayOperations = append(c.program.ArrayOperations, ops)
+	return len(c.program.ArrayOperations) - 1
+}
+
+func (c *compiler) pushScope() {
+	c.scope = &scope{slots: make(map[string]int), parent: c.scope, unit: c.unit}
+}
+
+func (c *compiler) popScope() {
+	c.scope = c.scope.parent
+}
+
+// Gets the scope that contains s, crossing in to the scope a function was declared in
+func (s *scope) up() *scope {
+	if s.parent != nil {
+		return s.parent
+	}
+	return s.unit.enclosing
+}
+
+// Gives a new slot to an identifier in a scope, or returns it's slot if it already has one
+func (c *compiler) declare(s *scope, name string) int {
+	if slot, ok := s.slots[name]; ok {
+		return slot
+	}
+	slot := c.allocateSlot(s.unit)
+	s.slots[name] = slot
+	return slot
+}
+
+// Allocates a slot that is not used by any identifier
+func (c *compiler) allocateSlot(unit *compileUnit) int {
+	if unit.isMain {
+		c.program.NumGlobals++
+		return c.program.NumGlobals - 1
+	}
+	unit.function.NumLocals++
+	return unit.function.NumLocals - 1
+}
+
+// Emits an instruction to get or set a slot in a scope, which must either be in the current function or the main program
+func (c *compiler) emitSlot(s *scope, slot int, get bool) {
+	if s.unit.isMain {
+		if get {
+			c.emit(OpGetGlobal, slot, 0)
+		} else {
+			c.emit(OpSetGlobal, slot, 0)
+		}
+	} else if s.unit == c.unit {
+		if get {
+			c.emit(OpGetLocal, slot, 0)
+		} else {
+			c.emit(OpSetLocal, slot, 0)
+		}
+	} else {
+		c.unsupported("functions using the variables of the function they are declared in")
+	}
+}
+
+func (c *compiler) emitGet(name string) {
+	for s := c.scope; s != nil; s = s.up() {
+		if slot, ok := s.slots[name]; ok {
+			c.emitSlot(s, slot, true)
+			return
+		}
+	}
+	c.unsupported("using ", name, " before it is declared")
+}
+
+// Emits an instruction to pop a value in to an identifier in the scope depth environments above the current one,
+// matching how assignments are performed by the tree walking interpreter
+func (c *compiler) emitSet(name string, depth int) {
+	s := c.scope
+	for i := 0; i < depth; i++ {
+		if s = s.up(); s == nil {
+			c.unsupported("assigning to ", name, " outside of the program")
+		}
+	}
+	c.emitSlot(s, c.declare(s, name), false)
+}
+
+func (c *compiler) compileBlock(block *nodes.Block) {
+	for _, node := range block.Nodes {
+		c.compileStatement(node)
+	}
+}
+
+// Compiles a node where the value it evaluates to is not used
+func (c *compiler) compileStatement(node environment.Node) {
+	if positioned, ok := node.(environment.Positioned); ok {
+		// Instructions after a nested block, such as the jump at the end of a loop, belong to the outer statement
+		previous := c.unit.position
+		c.unit.position = positioned.GetPosition()
+		defer func() {
+			c.unit.position = previous
+		}()
+	}
+
+	switch n := node.(type) {
+	case *nodes.Assignment:
+		c.compileExpression(n.NewValue)
+		c.emitSet(n.Identifier, n.Depth)
+	case *nodes.MultiAssignment:
+		for _, value := range n.Values {
+			c.compileExpression(value)
+		}
+		for i := len(n.Identifiers) - 1; i >= 0; i-- {
+			c.emitSet(n.Identifiers[i], n.Depths[i])
+		}
+	case *nodes.Import:
+		c.emit(OpImport, c.addConstant(n.Module), 0)
+		c.emitSet(n.Identifier, 0)
+	case *nodes.Return:
+		if n.TailCall {
+			c.compileCall(n.Value.(*nodes.FuncCall), OpTailCall)
+		} else {
+			c.compileExpression(n.Value)
+		}
+		c.emit(OpReturn, 0, 0)
+	case *nodes.IfStatement:
+		c.compileIfStatement(n)
+	case *nodes.LoopWhile:
+		c.unit.loopDepth++
+		start := len(c.unit.function.Code)
+		c.compileExpression(n.Condition)
+		exit := c.emit(OpJumpIfFalse, 0, 0)
+		c.pushScope()
+		c.compileBlock(n.Inner)
+		c.popScope()
+		c.emit(OpJump, start, 0)
+		c.patchJump(exit)
+		c.unit.loopDepth--
+	case *nodes.LoopRange:
+		c.compileLoopRange(n)
+	case *nodes.Block:
+		c.compileBlock(n)
+	default:
+		if c.compileArrayStatement(node) {
+			return
+		}
+		c.compileExpression(node)
+		c.emit(OpPop, 0, 0)
+	}
+}
+
+// Compiles a function call using either OpCall or OpTailCall
+func (c *compiler) compileCall(n *nodes.FuncCall, op Opcode) {
+	c.compileExpression(n.Function)
+	for _, arg := range n.Args {
+		if arg == nil {
+			c.emit(OpDefaultArg, 0, 0)
+		} else {
+			c.compileExpression(arg)
+		}
+	}
+	c.unit.function.CallSites = append(c.unit.function.CallSites, n.Position)
+	c.emitAt(n.Position, op, len(n.Args), len(c.unit.function.CallSites)-1)
+}
+
+func (c *compiler) compileIfStatement(n *nodes.IfStatement) {
+	c.compileExpression(n.Condition)
+	toElse := c.emit(OpJumpIfFalse, 0, 0)
+	c.pushScope()
+	c.compileBlock(n.Inner)
+	c.popScope()
+	if n.Else == nil {
+		c.patchJump(toElse)
+		return
+	}
+
+	toEnd := c.emit(OpJump, 0, 0)
+	c.patchJump(toElse)
+	if block, ok := n.Else.(*nodes.Block); ok {
+		c.pushScope()
+		c.compileBlock(block)
+		c.popScope()
+	} else {
+		c.compileStatement(n.Else)
+	}
+	c.patchJump(toEnd)
+}
+
+func (c *compiler) compileLoopRange(n *nodes.LoopRange) {
+	counter, end := c.allocateSlot(c.unit), c.allocateSlot(c.unit)
+	c.compileExpression(n.Start)
+	c.emit(OpToInt64, 0, 0)
+	c.emitSlot(c.scope, counter, false)
+	c.compileExpression(n.End)
+	c.emit(OpToInt64, 0, 0)
+	c.emitSlot(c.scope, end, false)
+
+	c.unit.loopDepth++
+	start := len(c.unit.function.Code)
+	c.emitSlot(c.scope, counter, true)
+	c.emitSlot(c.scope, end, true)
+	c.emit(OpLess, 0, int(KindInt64))
+	exit := c.emit(OpJumpIfFalse, 0, 0)
+
+	// Each iteration has it's own copy of the value so assigning to it doesn't change the iteration
+	c.pushScope()
+	c.emitSlot(c.scope, counter, true)
+	c.emitSlot(c.scope, c.declare(c.scope, n.ValIdentifier), false)
+	c.compileBlock(n.Inner)
+	c.popScope()
+
+	c.emitSlot(c.scope, counter, true)
+	c.emit(OpConstant, c.addConstant(int64(1)), 0)
+	c.emit(OpAdd, 0, int(KindInt64))
+	c.emitSlot(c.scope, counter, false)
+	c.emit(OpJump, start, 0)
+	c.patchJump(exit)
+	c.unit.loopDepth--
+}
+
+// Compiles array nodes that do not evaluate to a value, returning false if the node is not one of them
+func (c *compiler) compileArrayStatement(node environment.Node) bool {
+	switch n := node.(type) {
+	case nodes.LoopArrayNode:
+		valIdentifier, indexIdentifier, arrayNode, inner := n.LoopDetails()
+		array, index := c.allocateSlot(c.unit), c.allocateSlot(c.unit)
+		opsIndex := c.addArrayOperations(n.ArrayOperations())
+		c.compileExpression(arrayNode)
+		c.emitSlot(c.scope, array, false)
+		c.emit(OpConstant, c.addConstant(int64(0)), 0)
+		c.emitSlot(c.scope, index, false)
+
+		c.unit.loopDepth++
+		start := len(c.unit.function.Code)
+		c.emitSlot(c.scope, index, true)
+		c.emitSlot(c.scope, array, true)
+		c.emit(OpLen, opsIndex, 0)
+		c.emit(OpLess, 0, int(KindInt64))
+		exit := c.emit(OpJumpIfFalse, 0, 0)
+
+		c.pushScope()
+		c.emitSlot(c.scope, array, true)
+		c.emitSlot(c.scope, index, true)
+		c.emit(OpElement, opsIndex, 0)
+		c.emitSlot(c.scope, c.declare(c.scope, valIdentifier), false)
+		c.emitSlot(c.scope, index, true)
+		c.emitSlot(c.scope, c.declare(c.scope, indexIdentifier), false)
+		c.compileBlock(inner)
+		c.popScope()
+
+		c.emitSlot(c.scope, index, true)
+		c.emit(OpConstant, c.addConstant(int64(1)), 0)
+		c.emit(OpAdd, 0, int(KindInt64))
+		c.emitSlot(c.scope, index, false)
+		c.emit(OpJump, start, 0)
+		c.patchJump(exit)
+		c.unit.loopDepth--
+	case nodes.DestructureNode:
+		identifiers, indexes, valueNode := n.DestructureDetails()
+		opsIndex := c.addArrayOperations(n.ArrayOperations())
+		value := c.allocateSlot(c.unit)
+		c.compileExpression(valueNode)
+		c.emitSlot(c.scope, value, false)
+		for i, identifier := range identifiers {
+			c.emitSlot(c.scope, value, true)
+			c.emit(OpConstant, c.addConstant(int64(indexes[i])), 0)
+			c.emit(OpElement, opsIndex, 0)
+			c.emitSet(identifier, 0)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// Compiles a node so that the value it evaluates to is pushed on to the stack
+func (c *compiler) compileExpression(node environment.Node) {
+	switch n := node.(type) {
+	case *nodes.Value:
+		c.emit(OpConstant, c.addConstant(n.Value), 0)
+	case *nodes.Identifier:
+		c.emitGet(n.Name)
+	case *nodes.Global:
+		c.emit(OpGlobalValue, c.addConstant(n.Name), 0)
+	case *nodes.Assignment:
+		c.compileExpression(n.NewValue)
+		c.emit(OpDup, 0, 0)
+		c.emitSet(n.Identifier, n.Depth)
+	case *nodes.Not:
+		c.compileExpression(n.Value)
+		c.emit(OpNot, 0, 0)
+	case *nodes.And:
+		c.compileExpression(n.LeftSide)
+		end := c.emit(OpJumpIfFalseOrPop, 0, 0)
+		c.compileExpression(n.RightSide)
+		c.patchJump(end)
+	case *nodes.Or:
+		c.compileExpression(n.LeftSide)
+		end := c.emit(OpJumpIfTrueOrPop, 0, 0)
+		c.compileExpression(n.RightSide)
+		c.patchJump(end)
+	case *nodes.EqualityComparison:
+		c.compileExpression(n.LeftSide)
+		c.compileExpression(n.RightSide)
+		c.emit(OpEqual, 0, 0)
+	case *nodes.MapValue[string, any]:
+		c.compileExpression(n.Map)
+		c.compileExpression(n.Key)
+		c.emit(OpModuleValue, 0, 0)
+	case *nodes.FuncCall:
+		c.compileCall(n, OpCall)
+	case *nodes.FuncDeclaration:
+		if c.unit.loopDepth > 0 {
+			c.unsupported("functions declared inside of loops")
+		}
+		function := &Function{
+			Name:      n.Name + "()",
+			Line:      n.Line,
+			NumArgs:   len(n.ArgNames),
+			NumLocals: len(n.ArgNames),
+		}
+		if n.PackVariadic != nil {
+			function.PackVariadic = n.PackVariadic.PackArray
+		}
+		c.program.Functions = append(c.program.Functions, function)
+		c.pending = append(c.pending, pendingFunction{function, n, c.scope})
+		c.emit(OpClosure, len(c.program.Functions)-1, 0)
+		if n.Name != "" {
+			c.emit(OpDup, 0, 0)
+			c.emitSet(n.Name, 0)
+		}
+	default:
+		if c.compileNumberOperation(node) || c.compileArrayExpression(node) {
+			return
+		}
+		c.unsupported(unsupportedConstruct(node))
+	}
+}
+
+// Compiles array nodes that evaluate to a value, returning false if the node is not one of them
+func (c *compiler) compileArrayExpression(node environment.Node) bool {
+	switch n := node.(type) {
+	case nodes.ArrayInitializationNode:
+		opsIndex := c.addArrayOperations(n.ArrayOperations())
+		elements := n.ElementNodes()
+		c.emit(OpMakeArray, opsIndex, len(elements))
+		for i, element := range elements {
+			if element != nil {
+				c.compileExpression(element)
+				c.emit(OpInitElement, opsIndex, i)
+			}
+		}
+	case nodes.ArrayAssignmentNode:
+		arrayIndex, value := n.AssignmentDetails()
+		array, index := arrayIndex.IndexDetails()
+		c.compileExpression(value)
+		c.compileExpression(array)
+		c.compileExpression(index)
+		c.emitAt(arrayIndex.GetPosition(), OpSetIndex, c.addArrayOperations(n.ArrayOperations()), 0)
+	case nodes.ArrayIndexNode:
+		array, index := n.IndexDetails()
+		c.compileExpression(array)
+		c.compileExpression(index)
+		c.emitAt(n.GetPosition(), OpIndex, c.addArrayOperations(n.ArrayOperations()), 0)
+	default:
+		return false
+	}
+	return true
+}
+
+func (c *compiler) compileFunctionBody(pending pendingFunction) {
+	prevUnit, prevScope := c.unit, c.scope
+	c.unit = &compileUnit{function: pending.function, enclosing: pending.enclosing}
+	c.scope = &scope{slots: make(map[string]int), unit: c.unit}
+	for i, name := range pending.declaration.ArgNames {
+		c.scope.slots[name] = i
+	}
+
+	// Arguments that were omitted by the caller are set to their default values
+	for i, defaultValue := range pending.declaration.Defaults {
+		if defaultValue == nil {
+			continue
+		}
+		skip := c.emit(OpJumpIfNotDefault, i, 0)
+		c.compileExpression(defaultValue)
+		c.emit(OpSetLocal, i, 0)
+		c.patchJump(skip)
+	}
+
+	c.compileBlock(pending.declaration.Inner)
+	c.emit(OpReturnNil, 0, 0)
+	c.unit, c.scope = prevUnit, prevScope
+}
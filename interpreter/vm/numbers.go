@@ -0,0 +1,160 @@
+package vm
+
+import (
+	"main/interpreter/environment"
+	"main/interpreter/nodes"
+)
+
+type number interface {
+	int8 | int16 | int32 | int64 | uint8 | uint16 | uint32 | uint64 | float32 | float64
+}
+
+var mathsOpcodes = map[nodes.MathsOperationType]Opcode{
+	nodes.MathsAddition:       OpAdd,
+	nodes.MathsSubtraction:    OpSubtract,
+	nodes.MathsMultiplication: OpMultiply,
+	nodes.MathsDivision:       OpDivide,
+}
+
+var comparisonOpcodes = map[nodes.ComparisonType]Opcode{
+	nodes.ComparisonEquals:              OpEqual,
+	nodes.ComparisonGreaterThan:         OpGreater,
+	nodes.ComparisonLessThan:            OpLess,
+	nodes.ComparisonGreaterThanOrEquals: OpGreaterOrEqual,
+	nodes.ComparisonLessThanOrEquals:    OpLessOrEqual,
+}
+
+// Compiles maths operations and comparisons of numbers, returning false if the node is not one of them
+func (c *compiler) compileNumberOperation(node environment.Node) bool {
+	switch n := node.(type) {
+	case *nodes.MathsOperation[int8]:
+		compileMaths(c, n, KindInt8)
+	case *nodes.MathsOperation[int16]:
+		compileMaths(c, n, KindInt16)
+	case *nodes.MathsOperation[int32]:
+		compileMaths(c, n, KindInt32)
+	case *nodes.MathsOperation[int64]:
+		compileMaths(c, n, KindInt64)
+	case *nodes.MathsOperation[uint8]:
+		compileMaths(c, n, KindUint8)
+	case *nodes.MathsOperation[uint16]:
+		compileMaths(c, n, KindUint16)
+	case *nodes.MathsOperation[uint32]:
+		compileMaths(c, n, KindUint32)
+	case *nodes.MathsOperation[uint64]:
+		compileMaths(c, n, KindUint64)
+	case *nodes.MathsOperation[float32]:
+		compileMaths(c, n, KindFloat32)
+	case *nodes.MathsOperation[float64]:
+		compileMaths(c, n, KindFloat64)
+	case *nodes.InequalityComparison[int8]:
+		compileComparison(c, n, KindInt8)
+	case *nodes.InequalityComparison[int16]:
+		compileComparison(c, n, KindInt16)
+	case *nodes.InequalityComparison[int32]:
+		compileComparison(c, n, KindInt32)
+	case *nodes.InequalityComparison[int64]:
+		compileComparison(c, n, KindInt64)
+	case *nodes.InequalityComparison[uint8]:
+		compileComparison(c, n, KindUint8)
+	case *nodes.InequalityComparison[uint16]:
+		compileComparison(c, n, KindUint16)
+	case *nodes.InequalityComparison[uint32]:
+		compileComparison(c, n, KindUint32)
+	case *nodes.InequalityComparison[uint64]:
+		compileComparison(c, n, KindUint64)
+	case *nodes.InequalityComparison[float32]:
+		compileComparison(c, n, KindFloat32)
+	case *nodes.InequalityComparison[float64]:
+		compileComparison(c, n, KindFloat64)
+	default:
+		return false
+	}
+	return true
+}
+
+func compileMaths[T number](c *compiler, n *nodes.MathsOperation[T], kind NumberKind) {
+	c.compileExpression(n.LeftSide)
+	c.compileExpression(n.RightSide)
+	c.emitAt(n.Position, mathsOpcodes[n.Operation], 0, int(kind))
+}
+
+func compileComparison[T number](c *compiler, n *nodes.InequalityComparison[T], kind NumberKind) {
+	c.compileExpression(n.LeftSide)
+	c.compileExpression(n.RightSide)
+	c.emit(comparisonOpcodes[n.Type], 0, int(kind))
+}
+
+// Performs a maths operation or comparison on two numbers of a kind
+func operateOnNumbers(op Opcode, kind NumberKind, lhs any, rhs any) any {
+	switch kind {
+	case KindInt8:
+		return operate(op, lhs.(int8), rhs.(int8))
+	case KindInt16:
+		return operate(op, lhs.(int16), rhs.(int16))
+	case KindInt32:
+		return operate(op, lhs.(int32), rhs.(int32))
+	case KindInt64:
+		return operate(op, lhs.(int64), rhs.(int64))
+	case KindUint8:
+		return operate(op, lhs.(uint8), rhs.(uint8))
+	case KindUint16:
+		return operate(op, lhs.(uint16), rhs.(uint16))
+	case KindUint32:
+		return operate(op, lhs.(uint32), rhs.(uint32))
+	case KindUint64:
+		return operate(op, lhs.(uint64), rhs.(uint64))
+	case KindFloat32:
+		return operate(op, lhs.(float32), rhs.(float32))
+	case KindFloat64:
+		return operate(op, lhs.(float64), rhs.(float64))
+	}
+	return nil
+}
+
+func operate[T number](op Opcode, lhs T, rhs T) any {
+	switch op {
+	case OpAdd:
+		return lhs + rhs
+	case OpSubtract:
+		return lhs - rhs
+	case OpMultiply:
+		return lhs * rhs
+	case OpDivide:
+		return lhs / rhs
+	case OpGreater:
+		return lhs > rhs
+	case OpLess:
+		return lhs < rhs
+	case OpGreaterOrEqual:
+		return lhs >= rhs
+	case OpLessOrEqual:
+		return lhs <= rhs
+	}
+	return nil
+}
+
+// Converts any integer to an int64
+func toInt64(value any) int64 {
+	switch n := value.(type) {
+	case int:
+		return int64(n)
+	case int8:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case uint8:
+		return int64(n)
+	case uint16:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	}
+	return 0
+}
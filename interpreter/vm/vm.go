@@ -0,0 +1,345 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"main/interpreter/environment"
+	"main/interpreter/nodes"
+	"strconv"
+	"strings"
+)
+
+// A function value created by the virtual machine
+type Closure struct {
+	Function *Function
+	vm       *VM
+}
+
+// Calls the closure from outside of the virtual machine's loop, such as from a Go function
+func (c *Closure) Call(_ *environment.Environment, args []any) any {
+	vm := c.vm
+	depth := len(vm.frames)
+	vm.push(c)
+	for _, arg := range args {
+		vm.push(arg)
+	}
+	vm.callClosure(c, len(args))
+	vm.run(depth)
+	return vm.pop()
+}
+
+func (c *Closure) String() string {
+	return "fn " + c.Function.Name + "()"
+}
+
+type frame struct {
+	function *Function
+	ip       int
+	// The position of the first local variable on the stack
+	base int
+}
+
+// Pushed in place of an argument that was omitted by the caller so that the default value is used instead
+type defaultArg struct{}
+
+type VM struct {
+	program *Program
+	stack   []any
+	sp      int
+	globals []any
+	frames  []frame
+	modules map[string]map[string]any
+	// Values passed to the virtual machine by name, such as print
+	globalValues map[string]any
+	limiter      *environment.Limiter
+	// Whether or not steps and arrays are counted, only the call depth is checked if the program has no limits
+	limited bool
+}
+
+// Runs a compiled program, loading all globals and modules in to the virtual machine.
+// Returns a runtime error if the program panics, is cancelled by the context or exceeds one of the limits.
+func Run(ctx context.Context, program *Program, globals map[string]any, modules map[string]map[string]any, limits environment.Limits) (err *environment.RuntimeError) {
+	vm := &VM{
+		program:      program,
+		stack:        make([]any, 1024),
+		globals:      make([]any, program.NumGlobals),
+		modules:      modules,
+		globalValues: globals,
+	}
+	vm.limiter = environment.NewLimiter(ctx, limits)
+	vm.limited = vm.limiter.Enabled()
+	vm.frames = append(vm.frames, frame{
+		function: program.Main,
+	})
+	defer func() {
+		if r := recover(); r != nil {
+			err = vm.newRuntimeError(r)
+		}
+	}()
+	vm.run(0)
+	return nil
+}
+
+func (vm *VM) push(value any) {
+	if vm.sp == len(vm.stack) {
+		vm.stack = append(vm.stack, value)
+	} else {
+		vm.stack[vm.sp] = value
+	}
+	vm.sp++
+}
+
+func (vm *VM) pop() any {
+	vm.sp--
+	return vm.stack[vm.sp]
+}
+
+// Runs the current frame until the number of frames returns to a depth, a depth of 0 runs the whole program
+func (vm *VM) run(depth int) {
+	f := &vm.frames[len(vm.frames)-1]
+	code := f.function.Code
+	for {
+		instruction := code[f.ip]
+		f.ip++
+		if vm.limited {
+			if err := vm.limiter.Step(); err != nil {
+				panic(err)
+			}
+		}
+		switch instruction.Op {
+		case OpConstant:
+			vm.push(vm.program.Constants[instruction.A])
+		case OpNil:
+			vm.push(nil)
+		case OpPop:
+			vm.sp--
+		case OpDup:
+			vm.push(vm.stack[vm.sp-1])
+		case OpGetLocal:
+			vm.push(vm.stack[f.base+int(instruction.A)])
+		case OpSetLocal:
+			vm.stack[f.base+int(instruction.A)] = vm.pop()
+		case OpGetGlobal:
+			vm.push(vm.globals[instruction.A])
+		case OpSetGlobal:
+			vm.globals[instruction.A] = vm.pop()
+		case OpAdd, OpSubtract, OpMultiply, OpDivide, OpGreater, OpLess, OpGreaterOrEqual, OpLessOrEqual:
+			rhs := vm.pop()
+			vm.stack[vm.sp-1] = operateOnNumbers(instruction.Op, NumberKind(instruction.B), vm.stack[vm.sp-1], rhs)
+		case OpEqual:
+			rhs := vm.pop()
+			vm.stack[vm.sp-1] = vm.stack[vm.sp-1] == rhs
+		case OpNot:
+			vm.stack[vm.sp-1] = !vm.stack[vm.sp-1].(bool)
+		case OpToInt64:
+			vm.stack[vm.sp-1] = toInt64(vm.stack[vm.sp-1])
+		case OpJump:
+			f.ip = int(instruction.A)
+		case OpJumpIfFalse:
+			if !vm.pop().(bool) {
+				f.ip = int(instruction.A)
+			}
+		case OpJumpIfFalseOrPop:
+			if !vm.stack[vm.sp-1].(bool) {
+				f.ip = int(instruction.A)
+			} else {
+				vm.sp--
+			}
+		case OpJumpIfTrueOrPop:
+			if vm.stack[vm.sp-1].(bool) {
+				f.ip = int(instruction.A)
+			} else {
+				vm.sp--
+			}
+		case OpJumpIfNotDefault:
+			if _, ok := vm.stack[f.base+int(instruction.A)].(defaultArg); !ok {
+				f.ip = int(instruction.B)
+			}
+		case OpDefaultArg:
+			vm.push(defaultArg{})
+		case OpClosure:
+			vm.push(&Closure{Function: vm.program.Functions[instruction.A], vm: vm})
+		case OpCall:
+			argCount := int(instruction.A)
+			if closure, ok := vm.stack[vm.sp-argCount-1].(*Closure); ok {
+				vm.callClosure(closure, argCount)
+				f = &vm.frames[len(vm.frames)-1]
+				code = f.function.Code
+			} else {
+				vm.callNative(argCount)
+				// The Go function may have called a closure, which can reallocate the frames
+				f = &vm.frames[len(vm.frames)-1]
+			}
+		case OpTailCall:
+			argCount := int(instruction.A)
+			if closure, ok := vm.stack[vm.sp-argCount-1].(*Closure); ok {
+				vm.tailCallClosure(closure, argCount)
+				f = &vm.frames[len(vm.frames)-1]
+				code = f.function.Code
+			} else {
+				// The OpReturn after the call returns the result of the Go function
+				vm.callNative(argCount)
+				f = &vm.frames[len(vm.frames)-1]
+			}
+		case OpReturn, OpReturnNil:
+			var result any
+			if instruction.Op == OpReturn {
+				result = vm.pop()
+			}
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == 0 {
+				return
+			}
+			// Clear the frame so that the values it used can be garbage collected
+			for i := f.base - 1; i < vm.sp; i++ {
+				vm.stack[i] = nil
+			}
+			vm.sp = f.base - 1
+			vm.push(result)
+			if len(vm.frames) == depth {
+				return
+			}
+			f = &vm.frames[len(vm.frames)-1]
+			code = f.function.Code
+		case OpGlobalValue:
+			vm.push(vm.globalValues[vm.program.Constants[instruction.A].(string)])
+		case OpImport:
+			vm.push(vm.modules[vm.program.Constants[instruction.A].(string)])
+		case OpModuleValue:
+			key := vm.pop().(string)
+			vm.stack[vm.sp-1] = vm.stack[vm.sp-1].(map[string]any)[key]
+		case OpMakeArray:
+			vm.allocateArray(int(instruction.B))
+			vm.push(vm.program.ArrayOperations[instruction.A].Make(int(instruction.B)))
+		case OpInitElement:
+			value := vm.pop()
+			vm.program.ArrayOperations[instruction.A].Set(vm.stack[vm.sp-1], int(instruction.B), value)
+		case OpIndex:
+			ops := vm.program.ArrayOperations[instruction.A]
+			index := vm.pop()
+			array := vm.stack[vm.sp-1]
+			vm.stack[vm.sp-1] = ops.Get(array, vm.validateIndex(ops, array, index))
+		case OpSetIndex:
+			ops := vm.program.ArrayOperations[instruction.A]
+			index := vm.pop()
+			array := vm.pop()
+			ops.Set(array, vm.validateIndex(ops, array, index), vm.stack[vm.sp-1])
+		case OpElement:
+			index := vm.pop().(int64)
+			vm.stack[vm.sp-1] = vm.program.ArrayOperations[instruction.A].Get(vm.stack[vm.sp-1], int(index))
+		case OpLen:
+			vm.stack[vm.sp-1] = int64(vm.program.ArrayOperations[instruction.A].Len(vm.stack[vm.sp-1]))
+		default:
+			panic("Unknown opcode " + strconv.Itoa(int(instruction.Op)))
+		}
+	}
+}
+
+// Pushes a frame for a closure, the closure and it's arguments are on the top of the stack
+func (vm *VM) callClosure(closure *Closure, argCount int) {
+	function := closure.Function
+	// The depth of the main program is 0, so the depth of the call is the number of frames before it is pushed
+	if err := vm.limiter.CheckCallDepth(len(vm.frames)); err != nil {
+		panic(err)
+	}
+	if function.PackVariadic != nil {
+		fixedArgs := function.NumArgs - 1
+		variadicStart := vm.sp - argCount + fixedArgs
+		vm.allocateArray(vm.sp - variadicStart)
+		variadicArgs := make([]any, vm.sp-variadicStart)
+		copy(variadicArgs, vm.stack[variadicStart:vm.sp])
+		vm.sp = variadicStart
+		vm.push(function.PackVariadic(variadicArgs))
+	}
+
+	base := vm.sp - function.NumArgs
+	for vm.sp < base+function.NumLocals {
+		vm.push(nil)
+	}
+	vm.frames = append(vm.frames, frame{
+		function: function,
+		base:     base,
+	})
+}
+
+// Replaces the current frame with a frame for a closure called in tail position, the closure and it's arguments are on
+// the top of the stack. They are moved to where the closure of the current frame was, so the stack doesn't grow.
+func (vm *VM) tailCallClosure(closure *Closure, argCount int) {
+	f := vm.frames[len(vm.frames)-1]
+	copy(vm.stack[f.base-1:], vm.stack[vm.sp-argCount-1:vm.sp])
+	// Clear the rest of the frame so that the values it used can be garbage collected
+	top := f.base + argCount
+	for i := top; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = top
+	vm.frames = vm.frames[:len(vm.frames)-1]
+	vm.callClosure(closure, argCount)
+}
+
+// Calls a Go function, the function and it's arguments are on the top of the stack
+func (vm *VM) callNative(argCount int) {
+	args := make([]any, argCount)
+	copy(args, vm.stack[vm.sp-argCount:vm.sp])
+	result := vm.stack[vm.sp-argCount-1].(environment.Callable).Call(nil, args)
+	vm.sp -= argCount + 1
+	vm.push(result)
+}
+
+// Counts the elements of an array being allocated, ending the program if too many have been allocated
+func (vm *VM) allocateArray(elements int) {
+	if vm.limited {
+		if err := vm.limiter.AllocateArray(elements); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Converts an index value to an int, panicking if it is outside of the array
+func (vm *VM) validateIndex(ops nodes.ArrayOperations, array any, indexVal any) int {
+	index := toInt64(indexVal)
+	if index < 0 {
+		vm.panic("Array index cannot be less than 0")
+	}
+	if index >= int64(ops.Len(array)) {
+		vm.panic("Index out of array bounds")
+	}
+	return int(index)
+}
+
+// Performs a runtime panic, ending the program with a runtime error
+func (vm *VM) panic(msg ...any) {
+	panic(vm.newRuntimeError(strings.TrimSuffix(fmt.Sprintln(msg...), "\n")))
+}
+
+// Creates a runtime error from a value recovered from a panic, with the call stack of the current frame
+func (vm *VM) newRuntimeError(recovered any) *environment.RuntimeError {
+	if err, ok := recovered.(*environment.RuntimeError); ok {
+		return err
+	}
+
+	err := &environment.RuntimeError{Message: fmt.Sprint(recovered)}
+	if goErr, ok := recovered.(error); ok {
+		err.Message = goErr.Error()
+		err.Cause = goErr
+	}
+	// The instruction pointer of each frame has already moved past the instruction that panicked or the call it is
+	// waiting on, which matches the tree walker giving the position of the statement and of the call sites
+	for i := len(vm.frames) - 1; i >= 0; i-- {
+		f := vm.frames[i]
+		call := environment.Call{Name: f.function.Name, File: vm.program.File}
+		if f.ip > 0 {
+			position := f.function.Positions[f.ip-1]
+			if i < len(vm.frames)-1 {
+				position = f.function.CallSites[f.function.Code[f.ip-1].B]
+			}
+			call.Line = position.Line
+			call.Column = position.Column
+		}
+		err.CallStack = append(err.CallStack, call)
+	}
+	if f := vm.frames[len(vm.frames)-1]; f.ip > 0 {
+		err.Position = f.function.Positions[f.ip-1]
+	}
+	return err
+}
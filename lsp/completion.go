@@ -0,0 +1,210 @@
+package lsp
+
+// Completion suggests the members of the value before a dot, such as the properties and methods of a struct or the
+// members of a module, otherwise the identifiers that can be used at the position.
+
+import (
+	"main/interpreter"
+	"main/interpreter/environment"
+	"sort"
+)
+
+// Gets the completion items at a position
+func (d *document) complete(pos environment.Position, globalDefs map[string]interpreter.TypeDef) []completionItem {
+	if pos.Line < 1 || pos.Line > len(d.lines) {
+		return []completionItem{}
+	}
+	text := d.lines[pos.Line-1]
+	end := pos.Column - 1
+	if end > len(text) {
+		end = len(text)
+	}
+	// The identifier being typed is left for the client to filter by
+	start := end
+	for start > 0 && isIdentifierByte(text[start-1]) {
+		start--
+	}
+	if start == 0 || text[start-1] != '.' {
+		return d.completeIdentifiers(pos, globalDefs)
+	}
+
+	// The value before the dot can only be completed if it's a chain of identifiers, such as a.b.
+	chain := make([]string, 0)
+	for start > 0 && text[start-1] == '.' {
+		nameEnd := start - 1
+		start = nameEnd
+		for start > 0 && isIdentifierByte(text[start-1]) {
+			start--
+		}
+		if start == nameEnd {
+			return []completionItem{}
+		}
+		chain = append([]string{text[start:nameEnd]}, chain...)
+	}
+	root := environment.Position{Line: pos.Line, Column: start + 1}
+
+	if chain[0] == "self" {
+		if declaration := d.selfDeclaration(root); declaration != nil {
+			return d.completeMembers(declaration.Def, chain[1:])
+		}
+		return []completionItem{}
+	}
+	var def interpreter.TypeDef
+	if declaration := d.analysis.Resolve(chain[0], root); declaration != nil {
+		def = declaration.Def
+	} else if reference, ok := d.lastReference(chain[0], root); ok {
+		def = reference.Def
+	} else {
+		def = globalDefs[chain[0]]
+	}
+	return d.completeMembers(def, chain[1:])
+}
+
+func isIdentifierByte(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// Gets the last use of an identifier before a position, used for identifiers the program doesn't declare
+func (d *document) lastReference(name string, pos environment.Position) (interpreter.Reference, bool) {
+	var last interpreter.Reference
+	found := false
+	for _, reference := range d.analysis.References {
+		start := reference.Position
+		if reference.Name == name && (start.Line < pos.Line || (start.Line == pos.Line && start.Column < pos.Column)) {
+			last, found = reference, true
+		}
+	}
+	return last, found
+}
+
+// Gets the declaration of the struct or type that has the method a position is in, which is what self refers to
+func (d *document) selfDeclaration(pos environment.Position) *interpreter.Declaration {
+	for _, declaration := range d.analysis.Declarations {
+		for _, member := range declaration.Members {
+			if member.Kind == interpreter.DeclarationMethod && spanContains(member.Span, pos) {
+				if structDef, ok := declaration.Def.(interpreter.StructDef); ok {
+					// Self is an instance of the struct
+					structDef.Type = interpreter.TypeStructInstance
+					return &interpreter.Declaration{Name: declaration.Name, Def: structDef}
+				}
+				return &interpreter.Declaration{Name: declaration.Name, Def: interpreter.NamedTypeDef{Name: declaration.Name}}
+			}
+		}
+	}
+	return nil
+}
+
+// Checks whether a position is inside a span that can cover several lines
+func spanContains(span environment.Position, pos environment.Position) bool {
+	afterStart := pos.Line > span.Line || (pos.Line == span.Line && pos.Column >= span.Column)
+	beforeEnd := pos.Line < span.EndLine || (pos.Line == span.EndLine && pos.Column < span.EndColumn)
+	return afterStart && beforeEnd
+}
+
+// Gets the members of a definition after following a chain of members from it
+func (d *document) completeMembers(def interpreter.TypeDef, chain []string) []completionItem {
+	for _, name := range chain {
+		members := d.members(def)
+		def = nil
+		for _, member := range members {
+			if member.Label == name {
+				def = member.def
+			}
+		}
+	}
+
+	members := d.members(def)
+	items := make([]completionItem, len(members))
+	for i, member := range members {
+		items[i] = member.completionItem
+	}
+	return items
+}
+
+type member struct {
+	completionItem
+	def interpreter.TypeDef
+}
+
+// Gets the members of a struct instance, a value of a type with methods or a module, sorted by their names
+func (d *document) members(def interpreter.TypeDef) []member {
+	members := make([]member, 0)
+	switch def := def.(type) {
+	case interpreter.StructDef:
+		if def.Type != interpreter.TypeStructInstance {
+			break
+		}
+		for name, index := range def.Properties {
+			kind := completionField
+			if index >= def.DataProperties {
+				kind = completionMethod
+			}
+			members = append(members, newMember(name, kind, def.PropertyDefs[index]))
+		}
+	case interpreter.NamedTypeDef:
+		// The methods are read from the declaration of the type since the definition may have been copied before all
+		// of the methods were known
+		for _, declaration := range d.analysis.Declarations {
+			if declaration.Kind == interpreter.DeclarationType && declaration.Name == def.Name {
+				for _, method := range declaration.Members {
+					members = append(members, newMember(method.Name, completionMethod, method.Def))
+				}
+			}
+		}
+	case interpreter.ModuleDef:
+		for name, propertyDef := range def.Properties {
+			kind := completionField
+			if _, ok := propertyDef.(interpreter.FuncDef); ok {
+				kind = completionFunction
+			}
+			members = append(members, newMember(name, kind, propertyDef))
+		}
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].Label < members[j].Label
+	})
+	return members
+}
+
+func newMember(name string, kind int, def interpreter.TypeDef) member {
+	return member{completionItem{Label: name, Kind: kind, Detail: interpreter.TypeDefString(def)}, def}
+}
+
+// Gets the identifiers that can be used at a position, which are the declarations of the program that are visible
+// along with the globals, sorted by their names
+func (d *document) completeIdentifiers(pos environment.Position, globalDefs map[string]interpreter.TypeDef) []completionItem {
+	identifiers := make(map[string]completionItem)
+	for name, def := range globalDefs {
+		kind := completionVariable
+		if _, ok := def.(interpreter.FuncDef); ok {
+			kind = completionFunction
+		}
+		identifiers[name] = completionItem{Label: name, Kind: kind, Detail: interpreter.TypeDefString(def)}
+	}
+	// Declarations of inner scopes come last, so they replace the declarations of outer scopes with the same name
+	for _, declaration := range d.analysis.Visible(pos) {
+		var kind int
+		switch declaration.Kind {
+		case interpreter.DeclarationFunction:
+			kind = completionFunction
+		case interpreter.DeclarationStruct:
+			kind = completionStruct
+		case interpreter.DeclarationType:
+			kind = completionClass
+		case interpreter.DeclarationImport:
+			kind = completionModule
+		default:
+			kind = completionVariable
+		}
+		identifiers[declaration.Name] = completionItem{Label: declaration.Name, Kind: kind, Detail: interpreter.TypeDefString(declaration.Def)}
+	}
+
+	items := make([]completionItem, 0, len(identifiers))
+	for _, item := range identifiers {
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Label < items[j].Label
+	})
+	return items
+}
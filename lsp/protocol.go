@@ -0,0 +1,250 @@
+package lsp
+
+// The messages of the language server protocol that the server uses, along with converting between the positions of
+// the protocol and the positions of the interpreter. The protocol counts lines and characters from 0, with characters
+// counted in UTF-16 code units, while the interpreter counts lines and columns from 1, with columns counted in bytes.
+
+import (
+	"encoding/json"
+	"main/interpreter/environment"
+	"strings"
+	"unicode/utf8"
+)
+
+type message struct {
+	JSONRPC string `json:"jsonrpc"`
+	// Nil for notifications, which aren't replied to
+	ID     *json.RawMessage `json:"id,omitempty"`
+	Method string           `json:"method,omitempty"`
+	Params json.RawMessage  `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	// Omitted if the request failed, otherwise null if there is no result
+	Result *json.RawMessage `json:"result,omitempty"`
+	Error  *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type notification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params"`
+}
+
+// The codes of errors defined by JSON-RPC and the protocol
+const (
+	errorParse                = -32700
+	errorInvalidRequest       = -32600
+	errorMethodNotFound       = -32601
+	errorInvalidParams        = -32602
+	errorInternal             = -32603
+	errorServerNotInitialized = -32002
+)
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type protocolRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type location struct {
+	URI   string        `json:"uri"`
+	Range protocolRange `json:"range"`
+}
+
+type textDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+type textDocumentPositionParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+	Position     position               `json:"position"`
+}
+
+type didOpenParams struct {
+	TextDocument struct {
+		URI  string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+}
+
+type didChangeParams struct {
+	TextDocument   textDocumentIdentifier `json:"textDocument"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+type documentParams struct {
+	TextDocument textDocumentIdentifier `json:"textDocument"`
+}
+
+type initializeResult struct {
+	Capabilities serverCapabilities `json:"capabilities"`
+	ServerInfo   serverInfo         `json:"serverInfo"`
+}
+
+type serverCapabilities struct {
+	// Always full, the whole document is sent when it changes
+	TextDocumentSync       int               `json:"textDocumentSync"`
+	HoverProvider          bool              `json:"hoverProvider"`
+	DefinitionProvider     bool              `json:"definitionProvider"`
+	CompletionProvider     completionOptions `json:"completionProvider"`
+	DocumentSymbolProvider bool              `json:"documentSymbolProvider"`
+}
+
+type completionOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters"`
+}
+
+type serverInfo struct {
+	Name string `json:"name"`
+}
+
+type publishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Diagnostics []diagnostic `json:"diagnostics"`
+}
+
+type diagnostic struct {
+	Range    protocolRange `json:"range"`
+	Severity int           `json:"severity"`
+	Code     string        `json:"code"`
+	Message  string        `json:"message"`
+}
+
+// The severities of diagnostics
+const (
+	severityError   = 1
+	severityWarning = 2
+)
+
+type hover struct {
+	Contents markupContent `json:"contents"`
+	Range    protocolRange `json:"range"`
+}
+
+type markupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type completionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []completionItem `json:"items"`
+}
+
+type completionItem struct {
+	Label  string `json:"label"`
+	Kind   int    `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// The kinds of completion items
+const (
+	completionMethod   = 2
+	completionFunction = 3
+	completionField    = 5
+	completionVariable = 6
+	completionClass    = 7
+	completionModule   = 9
+	completionStruct   = 22
+)
+
+type documentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           int              `json:"kind"`
+	Range          protocolRange    `json:"range"`
+	SelectionRange protocolRange    `json:"selectionRange"`
+	Children       []documentSymbol `json:"children,omitempty"`
+}
+
+// The kinds of document symbols
+const (
+	symbolModule   = 2
+	symbolClass    = 5
+	symbolMethod   = 6
+	symbolField    = 8
+	symbolFunction = 12
+	symbolVariable = 13
+	symbolStruct   = 23
+)
+
+// The lines of a document, used to convert between the positions of the protocol and the interpreter
+type lines []string
+
+func splitLines(text string) lines {
+	return strings.Split(text, "\n")
+}
+
+// Converts a line and column of the interpreter to a position of the protocol, positions outside of the document are
+// moved to the nearest position inside it
+func (l lines) toPosition(line int, column int) position {
+	if line < 1 {
+		return position{}
+	}
+	if line > len(l) {
+		return position{Line: len(l) - 1, Character: utf16Length(l[len(l)-1])}
+	}
+	text := l[line-1]
+	if column < 1 {
+		column = 1
+	} else if column > len(text)+1 {
+		column = len(text) + 1
+	}
+	return position{Line: line - 1, Character: utf16Length(text[:column-1])}
+}
+
+// Converts a span of the interpreter to a range of the protocol, spans without an end are empty ranges
+func (l lines) toRange(span environment.Position) protocolRange {
+	start := l.toPosition(span.Line, span.Column)
+	if span.EndLine == 0 {
+		return protocolRange{start, start}
+	}
+	return protocolRange{start, l.toPosition(span.EndLine, span.EndColumn)}
+}
+
+// Converts a position of the protocol to a line and column of the interpreter
+func (l lines) fromPosition(pos position) (int, int) {
+	if pos.Line < 0 || pos.Line >= len(l) {
+		return pos.Line + 1, 1
+	}
+	text := l[pos.Line]
+	units := 0
+	for offset, r := range text {
+		if units >= pos.Character {
+			return pos.Line + 1, offset + 1
+		}
+		units += utf16RuneLength(r)
+	}
+	return pos.Line + 1, len(text) + 1
+}
+
+// Gets the number of UTF-16 code units needed to encode a string
+func utf16Length(text string) int {
+	units := 0
+	for _, r := range text {
+		units += utf16RuneLength(r)
+	}
+	return units
+}
+
+func utf16RuneLength(r rune) int {
+	// Characters outside of the basic multilingual plane are encoded as a surrogate pair
+	if r > 0xFFFF && r <= utf8.MaxRune {
+		return 2
+	}
+	return 1
+}
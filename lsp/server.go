@@ -0,0 +1,406 @@
+package lsp
+
+// A language server that speaks the language server protocol over a stream such as stdin and stdout. Each document
+// is analysed by the parser when it is opened or changed, publishing it's diagnostics, and the analysis is used to
+// answer hover, go to definition, completion and document symbol requests.
+//
+// Messages are JSON-RPC 2.0, each preceded by a header with it's length:
+//
+//	Content-Length: 44\r\n
+//	\r\n
+//	{"jsonrpc":"2.0","id":1,"method":"shutdown"}
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"main/interpreter"
+	"main/interpreter/environment"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Server struct {
+	globalDefs map[string]interpreter.TypeDef
+	moduleDefs map[string]map[string]interpreter.TypeDef
+	documents  map[string]*document
+	// Requests other than initialize are rejected until the server is initialized, and all requests are rejected once
+	// it has been shut down
+	initialized bool
+	shutdown    bool
+}
+
+// An open document along with the analysis of it's latest content
+type document struct {
+	uri      string
+	lines    lines
+	analysis *interpreter.Analysis
+}
+
+func NewServer(globalDefs map[string]interpreter.TypeDef, moduleDefs map[string]map[string]interpreter.TypeDef) *Server {
+	return &Server{
+		globalDefs: globalDefs,
+		moduleDefs: moduleDefs,
+		documents:  make(map[string]*document),
+	}
+}
+
+// An error replied to a request
+type requestError struct {
+	code    int
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// Reads messages and writes their replies until the client sends the exit notification or the input ends. Returns
+// whether the server was shut down before it exited, which clients expect to be the exit code.
+func (s *Server) Serve(r io.Reader, w io.Writer) (bool, error) {
+	reader := bufio.NewReader(r)
+	for {
+		content, err := readMessage(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return s.shutdown, nil
+			}
+			return s.shutdown, err
+		}
+		replies, exit := s.handle(content)
+		for _, reply := range replies {
+			if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n%s", len(reply), reply); err != nil {
+				return s.shutdown, err
+			}
+		}
+		if exit {
+			return s.shutdown, nil
+		}
+	}
+}
+
+// Reads the content of the next message, skipping headers other than the length of the content
+func readMessage(reader *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		header = strings.TrimRight(header, "\r\n")
+		if header == "" {
+			break
+		}
+		if value, ok := strings.CutPrefix(header, "Content-Length:"); ok {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, errors.New("Invalid Content-Length header " + strconv.Quote(header) + ".")
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("Message is missing the Content-Length header.")
+	}
+	content := make([]byte, length)
+	_, err := io.ReadFull(reader, content)
+	return content, err
+}
+
+// Handles the content of a message, returning the messages to send back which are the response to a request along
+// with any notifications. Also returns whether the server should exit.
+func (s *Server) handle(content []byte) ([][]byte, bool) {
+	var request message
+	if err := json.Unmarshal(content, &request); err != nil {
+		return [][]byte{s.reply(nil, nil, &requestError{errorParse, "Invalid JSON: " + err.Error()})}, false
+	}
+	if request.Method == "exit" {
+		return nil, true
+	}
+
+	result, notifications, err := s.dispatch(request)
+	replies := make([][]byte, 0, len(notifications)+1)
+	if request.ID != nil {
+		replies = append(replies, s.reply(request.ID, result, err))
+	}
+	for _, params := range notifications {
+		replies = append(replies, marshal(notification{"2.0", "textDocument/publishDiagnostics", params}))
+	}
+	return replies, false
+}
+
+// Handles a request or notification, returning the result along with the diagnostics to publish
+func (s *Server) dispatch(request message) (result any, notifications []publishDiagnosticsParams, err error) {
+	// A bug analysing a document shouldn't end the session, the request fails instead
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			result, notifications, err = nil, nil, &requestError{errorInternal, fmt.Sprint("Internal error: ", recovered)}
+		}
+	}()
+
+	if s.shutdown {
+		return nil, nil, &requestError{errorInvalidRequest, "The server has been shut down."}
+	}
+	if request.Method == "initialize" {
+		s.initialized = true
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:       1,
+				HoverProvider:          true,
+				DefinitionProvider:     true,
+				CompletionProvider:     completionOptions{TriggerCharacters: []string{"."}},
+				DocumentSymbolProvider: true,
+			},
+			ServerInfo: serverInfo{Name: "lang"},
+		}, nil, nil
+	}
+	if !s.initialized {
+		return nil, nil, &requestError{errorServerNotInitialized, "The server hasn't been initialized."}
+	}
+
+	switch request.Method {
+	case "initialized":
+		return nil, nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshalParams(request, &params); err != nil {
+			return nil, nil, err
+		}
+		return nil, []publishDiagnosticsParams{s.open(params.TextDocument.URI, params.TextDocument.Text)}, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshalParams(request, &params); err != nil {
+			return nil, nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil, nil
+		}
+		// Changes are always the whole document, since the server only supports full synchronisation
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, []publishDiagnosticsParams{s.open(params.TextDocument.URI, text)}, nil
+	case "textDocument/didClose":
+		var params documentParams
+		if err := unmarshalParams(request, &params); err != nil {
+			return nil, nil, err
+		}
+		delete(s.documents, params.TextDocument.URI)
+		// The diagnostics of closed documents are cleared
+		return nil, []publishDiagnosticsParams{{URI: params.TextDocument.URI, Diagnostics: []diagnostic{}}}, nil
+	case "textDocument/hover":
+		doc, pos, err := s.documentPosition(request)
+		if err != nil {
+			return nil, nil, err
+		}
+		return doc.hover(pos), nil, nil
+	case "textDocument/definition":
+		doc, pos, err := s.documentPosition(request)
+		if err != nil {
+			return nil, nil, err
+		}
+		return doc.definition(pos), nil, nil
+	case "textDocument/completion":
+		doc, pos, err := s.documentPosition(request)
+		if err != nil {
+			return nil, nil, err
+		}
+		return completionList{Items: doc.complete(pos, s.globalDefs)}, nil, nil
+	case "textDocument/documentSymbol":
+		var params documentParams
+		if err := unmarshalParams(request, &params); err != nil {
+			return nil, nil, err
+		}
+		doc, err := s.document(params.TextDocument.URI)
+		if err != nil {
+			return nil, nil, err
+		}
+		return doc.symbols(), nil, nil
+	}
+	if request.ID == nil {
+		// Notifications the server doesn't support are ignored
+		return nil, nil, nil
+	}
+	return nil, nil, &requestError{errorMethodNotFound, "Method " + strconv.Quote(request.Method) + " is not supported."}
+}
+
+// Creates the reply to a request
+func (s *Server) reply(id *json.RawMessage, result any, err error) []byte {
+	if id == nil {
+		null := json.RawMessage("null")
+		id = &null
+	}
+	if err != nil {
+		var requestErr *requestError
+		if !errors.As(err, &requestErr) {
+			requestErr = &requestError{errorInternal, err.Error()}
+		}
+		return marshal(response{JSONRPC: "2.0", ID: id, Error: &responseError{requestErr.code, requestErr.message}})
+	}
+	resultJSON := json.RawMessage(marshal(result))
+	return marshal(response{JSONRPC: "2.0", ID: id, Result: &resultJSON})
+}
+
+func marshal(value any) []byte {
+	content, err := json.Marshal(value)
+	if err != nil {
+		panic(err)
+	}
+	return content
+}
+
+func unmarshalParams(request message, params any) error {
+	if err := json.Unmarshal(request.Params, params); err != nil {
+		return &requestError{errorInvalidParams, "Invalid parameters: " + err.Error()}
+	}
+	return nil
+}
+
+// Gets an open document
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, &requestError{errorInvalidParams, "The document " + uri + " isn't open."}
+	}
+	return doc, nil
+}
+
+// Gets the document and position of a request at a position in a document, the position is converted to a line and
+// column of the interpreter
+func (s *Server) documentPosition(request message) (*document, environment.Position, error) {
+	var params textDocumentPositionParams
+	if err := unmarshalParams(request, &params); err != nil {
+		return nil, environment.Position{}, err
+	}
+	doc, err := s.document(params.TextDocument.URI)
+	if err != nil {
+		return nil, environment.Position{}, err
+	}
+	line, column := doc.lines.fromPosition(params.Position)
+	return doc, environment.Position{Line: line, Column: column}, nil
+}
+
+// Analyses the content of a document, replacing the previous analysis if it was already open. Returns the diagnostics
+// to publish for it.
+func (s *Server) open(uri string, text string) publishDiagnosticsParams {
+	parser := interpreter.NewParser(text, filePath(uri), s.globalDefs, s.moduleDefs)
+	analysis, diagnostics := parser.Analyse()
+	doc := &document{uri, splitLines(text), analysis}
+	s.documents[uri] = doc
+
+	params := publishDiagnosticsParams{URI: uri, Diagnostics: make([]diagnostic, len(diagnostics))}
+	for i, d := range diagnostics {
+		severity := severityError
+		if d.Severity == interpreter.SeverityWarning {
+			severity = severityWarning
+		}
+		params.Diagnostics[i] = diagnostic{doc.lines.toRange(d.Position), severity, string(d.Code), d.Message}
+	}
+	return params
+}
+
+// Gets the path of a file from it's URI, which is used by the parser in errors
+func filePath(uri string) string {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Scheme != "file" {
+		return uri
+	}
+	return parsed.Path
+}
+
+// Gets the identifier at a position along with it's definition
+func (d *document) hover(pos environment.Position) *hover {
+	reference, ok := d.analysis.ReferenceAt(pos)
+	if !ok || reference.Def == nil {
+		return nil
+	}
+	return &hover{
+		Contents: markupContent{Kind: "plaintext", Value: describe(reference)},
+		Range:    d.lines.toRange(reference.Position),
+	}
+}
+
+// Describes an identifier the way it is declared
+func describe(reference interpreter.Reference) string {
+	if declaration := reference.Declaration; declaration != nil {
+		switch declaration.Kind {
+		case interpreter.DeclarationStruct:
+			return "struct " + reference.Name
+		case interpreter.DeclarationType:
+			def, ok := reference.Def.(interpreter.TypeDeclarationDef)
+			if !ok {
+				break
+			}
+			// Types with methods are declared as named types, which don't have a definition to show
+			if namedDef, ok := def.Def.(interpreter.NamedTypeDef); ok && namedDef.Name == reference.Name {
+				return "type " + reference.Name
+			}
+			return "type " + reference.Name + " " + interpreter.TypeDefString(def.Def)
+		}
+	}
+	return reference.Name + ": " + interpreter.TypeDefString(reference.Def)
+}
+
+// Gets where the identifier at a position is declared, which is nil if it isn't declared by the program
+func (d *document) definition(pos environment.Position) *location {
+	reference, ok := d.analysis.ReferenceAt(pos)
+	if !ok || reference.Declaration == nil {
+		return nil
+	}
+	return &location{d.uri, d.lines.toRange(reference.Declaration.Position)}
+}
+
+// Gets the top-level declarations of the document, with the members of structs and types as their children
+func (d *document) symbols() []documentSymbol {
+	symbols := make([]documentSymbol, 0)
+	for _, declaration := range d.analysis.Declarations {
+		if declaration.Scope.Parent != nil {
+			continue
+		}
+		symbol := d.symbol(declaration)
+		for _, member := range declaration.Members {
+			symbol.Children = append(symbol.Children, d.symbol(member))
+		}
+		symbols = append(symbols, symbol)
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		start, other := symbols[i].SelectionRange.Start, symbols[j].SelectionRange.Start
+		return start.Line < other.Line || (start.Line == other.Line && start.Character < other.Character)
+	})
+	return symbols
+}
+
+func (d *document) symbol(declaration *interpreter.Declaration) documentSymbol {
+	var kind int
+	switch declaration.Kind {
+	case interpreter.DeclarationFunction:
+		kind = symbolFunction
+	case interpreter.DeclarationStruct:
+		kind = symbolStruct
+	case interpreter.DeclarationType:
+		kind = symbolClass
+	case interpreter.DeclarationImport:
+		kind = symbolModule
+	case interpreter.DeclarationProperty:
+		kind = symbolField
+	case interpreter.DeclarationMethod:
+		kind = symbolMethod
+	default:
+		kind = symbolVariable
+	}
+	detail := ""
+	if declaration.Def != nil && declaration.Kind != interpreter.DeclarationStruct {
+		detail = interpreter.TypeDefString(declaration.Def)
+	}
+	return documentSymbol{
+		Name:           declaration.Name,
+		Detail:         detail,
+		Kind:           kind,
+		Range:          d.lines.toRange(declaration.Span),
+		SelectionRange: d.lines.toRange(declaration.Position),
+	}
+}
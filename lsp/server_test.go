@@ -0,0 +1,119 @@
+package lsp
+
+import (
+	"bytes"
+	"flag"
+	"main/interpreter"
+	standardlibrary "main/standard_library"
+	keyvalue "main/standard_library/key_value.go"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Rewrites the messages of the server in the recorded sessions, run with go test ./lsp -update
+var update = flag.Bool("update", false, "If passed the recorded sessions are updated instead of being compared")
+
+func newTestServer() *Server {
+	globalDefs, _ := interpreter.BindValues(standardlibrary.Globals)
+	keyValueDefs, _ := interpreter.BindValues(keyvalue.Module)
+	return NewServer(globalDefs, map[string]map[string]interpreter.TypeDef{"key_value": keyValueDefs})
+}
+
+// Replays each session recorded in testdata, which has a line for each message. Lines starting with --> are sent by
+// the client and lines starting with <-- are the messages the server must send back, in order. Lines starting with #
+// are comments.
+func TestSessions(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.session"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("No sessions in testdata")
+	}
+
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".session"), func(t *testing.T) {
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			server := newTestServer()
+			recorded := make([]string, 0)
+			expected := make([]string, 0)
+			for _, line := range strings.Split(strings.TrimRight(string(content), "\n"), "\n") {
+				if message, ok := strings.CutPrefix(line, "<-- "); ok {
+					expected = append(expected, message)
+					continue
+				}
+				recorded = append(recorded, line)
+				message, ok := strings.CutPrefix(line, "--> ")
+				if !ok {
+					continue
+				}
+				replies, _ := server.handle([]byte(message))
+				for _, reply := range replies {
+					recorded = append(recorded, "<-- "+string(reply))
+				}
+			}
+
+			if *update {
+				if err := os.WriteFile(path, []byte(strings.Join(recorded, "\n")+"\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			replies := make([]string, 0)
+			for _, line := range recorded {
+				if reply, ok := strings.CutPrefix(line, "<-- "); ok {
+					replies = append(replies, reply)
+				}
+			}
+			for i := 0; i < len(replies) || i < len(expected); i++ {
+				if i >= len(replies) {
+					t.Errorf("Expected the server to send %s", expected[i])
+				} else if i >= len(expected) {
+					t.Errorf("Unexpected message from the server %s", replies[i])
+				} else if replies[i] != expected[i] {
+					t.Errorf("Expected the server to send\n%s\ngot\n%s", expected[i], replies[i])
+				}
+			}
+		})
+	}
+}
+
+// Sends framed messages to the server, checking the replies are framed and that it stops once it exits
+func TestServe(t *testing.T) {
+	input := ""
+	for _, content := range []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"shutdown"}`,
+	} {
+		input += "Content-Length: " + strconv.Itoa(len(content)) + "\r\n\r\n" + content
+	}
+
+	var output bytes.Buffer
+	shutdown, err := newTestServer().Serve(strings.NewReader(input), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !shutdown {
+		t.Error("Expected the server to have been shut down")
+	}
+	shutdownReply := `{"jsonrpc":"2.0","id":2,"result":null}`
+	if !strings.HasSuffix(output.String(), "Content-Length: "+strconv.Itoa(len(shutdownReply))+"\r\n\r\n"+shutdownReply) {
+		t.Errorf("Expected the reply to shutdown to be the last message, got %q", output.String())
+	}
+	if strings.Count(output.String(), "Content-Length") != 2 {
+		t.Errorf("Expected 2 replies, got %q", output.String())
+	}
+
+	_, err = newTestServer().Serve(strings.NewReader("Content-Type: text/plain\r\n\r\n{}"), &output)
+	if err == nil {
+		t.Error("Expected an error reading a message without a length")
+	}
+}
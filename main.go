@@ -1,21 +1,54 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"main/interpreter"
+	"main/interpreter/environment"
+	"main/interpreter/optimiser"
+	"main/lsp"
 	"main/profiler"
+	"main/repl"
 	standardlibrary "main/standard_library"
 	keyvalue "main/standard_library/key_value.go"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "compile" {
+		compile(os.Args[2:])
+		return
+	} else if len(os.Args) > 1 && os.Args[1] == "fmt" {
+		format(os.Args[2:])
+		return
+	} else if len(os.Args) > 1 && os.Args[1] == "lint" {
+		lint(os.Args[2:])
+		return
+	} else if len(os.Args) > 1 && os.Args[1] == "lsp" {
+		languageServer(os.Args[2:])
+		return
+	} else if len(os.Args) > 1 && os.Args[1] == "repl" {
+		interactiveSession(os.Args[2:])
+		return
+	}
+
 	entryPoint := flag.String("run", "", "The entry point file to run")
 	runProfiler := flag.Bool("profile", false, "If passed the program execution will be profiled")
 	openProfilerResultsViewer := flag.Bool("profiler-viewer", false, "If passed the profiler results viewer will be opened")
+	engineName := flag.String("engine", "tree", "The engine used to execute the program, either tree or bytecode")
+	gcMode := flag.String("gc", "eager", "When the garbage collector runs, either off, eager or threshold:N to run once N identifiers can be freed")
+	printGCStats := flag.Bool("gc-stats", false, "If passed statistics of the garbage collector will be printed")
+	timeout := flag.Duration("timeout", 0, "The maximum time the program can run for, such as 10s, or 0 for no limit")
+	maxSteps := flag.Int64("max-steps", 0, "The maximum number of statements or instructions executed, or 0 for no limit")
+	maxCallDepth := flag.Int("max-call-depth", environment.DefaultMaxCallDepth, "The maximum number of nested function calls, deeper calls end the program with a stack overflow")
+	maxArrayElements := flag.Int64("max-array-elements", 0, "The maximum number of array elements allocated, or 0 for no limit")
+	noOptimisation := flag.Bool("O0", false, "If passed the program will be run without being optimised, the same as -O1=false")
+	optimise := flag.Bool("O1", true, "If passed the program will be optimised before it is run, this is the default")
 	flag.Parse()
 
 	if *openProfilerResultsViewer {
@@ -24,6 +57,36 @@ func main() {
 		return
 	}
 
+	var engine interpreter.Engine
+	switch *engineName {
+	case "tree":
+		engine = interpreter.EngineTreeWalker
+	case "bytecode":
+		engine = interpreter.EngineBytecode
+	default:
+		fmt.Println("The engine must either be tree or bytecode")
+		return
+	}
+
+	gc := environment.GCOptions{Stats: *printGCStats}
+	switch *gcMode {
+	case "off":
+		gc.Mode = environment.GCOff
+	case "eager":
+		gc.Mode = environment.GCEager
+	default:
+		threshold, ok := strings.CutPrefix(*gcMode, "threshold:")
+		var err error
+		if ok {
+			gc.Threshold, err = strconv.Atoi(threshold)
+		}
+		if !ok || err != nil || gc.Threshold < 1 {
+			fmt.Println("The garbage collector mode must either be off, eager or threshold:N with a positive number N")
+			return
+		}
+		gc.Mode = environment.GCThreshold
+	}
+
 	var err error
 	if *entryPoint == "" {
 		fmt.Println("You must specify an entrypoint with the -run flag")
@@ -45,27 +108,247 @@ func main() {
 		return
 	}
 
-	parser := interpreter.NewParser(string(content), *entryPoint, map[string]interpreter.TypeDef{
-		"print": standardlibrary.PrintDef,
-		"input": standardlibrary.InputDef,
-	}, map[string]map[string]interpreter.TypeDef{
-		"key_value": {
-			"open": keyvalue.OpenDef,
-		},
-	})
-	ast := parser.Parse()
+	globalDefs, globals, moduleDefs, modules := bindStandardLibrary()
+	// Programs are only parsed if they haven't been compiled since their source code last changed
+	var ast []environment.Node
+	if program, ok := interpreter.ReadCache(interpreter.CachePath(*entryPoint), string(content)); ok {
+		ast = program.AST
+	} else {
+		parser := interpreter.NewParser(string(content), *entryPoint, globalDefs, moduleDefs)
+		ast = parse(parser, *entryPoint, string(content))
+		if ast == nil {
+			os.Exit(1)
+		}
+	}
+	level := optimiser.LevelDefault
+	if *noOptimisation || !*optimise {
+		level = optimiser.LevelNone
+	}
+	ast = optimiser.Optimise(ast, level)
 
-	profileResult := interpreter.Execute(ast, *entryPoint, *runProfiler, map[string]any{
-		"print": standardlibrary.Print,
-		"input": standardlibrary.Input,
-	}, map[string]map[string]any{
-		"key_value": {
-			"open": keyvalue.Open,
-		},
-	})
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	limits := environment.Limits{
+		MaxSteps:         *maxSteps,
+		MaxCallDepth:     *maxCallDepth,
+		MaxArrayElements: *maxArrayElements,
+	}
+
+	profileResult, fallback, runtimeErr := interpreter.Execute(ctx, ast, *entryPoint, engine, *runProfiler, gc, limits, globals, modules)
+	if fallback != nil {
+		// Written to stderr so that the output of the program is the same with either engine
+		fmt.Fprintln(os.Stderr, "The program was run by the tree engine since", fallback)
+	}
+	if runtimeErr != nil {
+		fmt.Println("panic:", runtimeErr)
+		fmt.Print(runtimeErr.Position.Snippet(string(content)))
+		fmt.Println(runtimeErr.StackTrace())
+		os.Exit(1)
+	}
+	if *printGCStats {
+		fmt.Println("Garbage collector:", profileResult.TotalGCStats())
+	}
 	if *runProfiler {
 		// os.WriteFile function automatically opens and closes file
 		os.WriteFile("profiler_results.csv", []byte(profileResult.ToCsv()), 0644)
 		fmt.Println("Saved profiler results to profiler_results.csv")
 	}
 }
+
+// Binds the standard library, the definitions of it are derived from the Go functions when they are bound
+func bindStandardLibrary() (map[string]interpreter.TypeDef, map[string]any, map[string]map[string]interpreter.TypeDef, map[string]map[string]any) {
+	globalDefs, globals := interpreter.BindValues(standardlibrary.Globals)
+	keyValueDefs, keyValue := interpreter.BindValues(keyvalue.Module)
+	return globalDefs, globals, map[string]map[string]interpreter.TypeDef{
+		"key_value": keyValueDefs,
+	}, map[string]map[string]any{
+		"key_value": keyValue,
+	}
+}
+
+// Parses a program and prints it's diagnostics, returning nil if the program has errors
+func parse(parser *interpreter.Parser, path string, content string) []environment.Node {
+	ast, diagnostics := parser.Parse()
+	for _, diagnostic := range diagnostics {
+		fmt.Print(diagnostic.Render(path, content))
+	}
+	if interpreter.HasErrors(diagnostics) {
+		return nil
+	}
+	return ast
+}
+
+// Parses and type checks programs ahead of time, writing them to cache files that are loaded instead of parsing the
+// programs when they are run. A cache is ignored once the source code of it's program changes.
+func compile(args []string) {
+	flags := flag.NewFlagSet("compile", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: compile <files>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	globalDefs, _, moduleDefs, _ := bindStandardLibrary()
+	failed := false
+	for _, path := range flags.Args() {
+		path, err := filepath.Abs(path)
+		if err != nil {
+			fmt.Println("Error resolving file path:", err)
+			os.Exit(1)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			os.Exit(1)
+		}
+
+		parser := interpreter.NewParser(string(content), path, globalDefs, moduleDefs)
+		ast := parse(parser, path, string(content))
+		if ast == nil {
+			failed = true
+			continue
+		}
+		cachePath := interpreter.CachePath(path)
+		err = interpreter.WriteCache(cachePath, string(content), &interpreter.CompiledProgram{AST: ast})
+		if err != nil {
+			fmt.Println("Error writing cache file:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Compiled", path, "to", cachePath)
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Reprints programs in the canonical style, rewriting the files in place. With -check the files are left unchanged and
+// the ones that aren't formatted are listed instead, exiting with an error if there are any.
+func format(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "If passed the files that aren't formatted are listed instead of being rewritten")
+	flags.Usage = func() {
+		fmt.Println("Usage: fmt [-check] <files>")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	failed := false
+	for _, path := range flags.Args() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			os.Exit(1)
+		}
+
+		formatted, err := interpreter.Format(string(content))
+		if err != nil {
+			var lexerErr *interpreter.LexerError
+			if errors.As(err, &lexerErr) {
+				fmt.Print(interpreter.Diagnostic{
+					Position: environment.Position{Line: lexerErr.Line, Column: lexerErr.Column},
+					Code:     interpreter.DiagnosticSyntaxError,
+					Severity: interpreter.SeverityError,
+					Message:  lexerErr.Error(),
+				}.Render(path, string(content)))
+			} else {
+				fmt.Println("Error formatting file:", err)
+			}
+			failed = true
+			continue
+		}
+		if formatted == string(content) {
+			continue
+		}
+
+		if *check {
+			fmt.Println(path)
+			failed = true
+		} else if err := os.WriteFile(path, []byte(formatted), 0644); err != nil {
+			fmt.Println("Error writing file:", err)
+			os.Exit(1)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Checks programs for code that is likely to be a mistake, printing a line for each problem along with the code of it's
+// rule. Exits with an error if there are any problems.
+func lint(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: lint <files>")
+		fmt.Println("Rules can be turned off for a line with a comment such as // lint:ignore unused-variable")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	globalDefs, _, moduleDefs, _ := bindStandardLibrary()
+	failed := false
+	for _, path := range flags.Args() {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Println("Error reading file:", err)
+			os.Exit(1)
+		}
+
+		parser := interpreter.NewParser(string(content), path, globalDefs, moduleDefs)
+		for _, diagnostic := range parser.Lint() {
+			fmt.Println(path + ":" + diagnostic.String())
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Runs a language server over stdin and stdout for editors to use, exiting with an error if the editor exits without
+// shutting the server down first
+func languageServer(args []string) {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: lsp")
+		fmt.Println("The language server reads messages from stdin and writes messages to stdout")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	globalDefs, _, moduleDefs, _ := bindStandardLibrary()
+	shutdown, err := lsp.NewServer(globalDefs, moduleDefs).Serve(os.Stdin, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error reading message:", err)
+		os.Exit(1)
+	}
+	if !shutdown {
+		os.Exit(1)
+	}
+}
+
+// Runs a REPL session on stdin and stdout, where each input is run in the same environment
+func interactiveSession(args []string) {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Println("Usage: repl")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	repl.Run(os.Stdin, os.Stdout)
+}
@@ -12,6 +12,44 @@ type ProfileResult struct {
 	Name        string
 	Duration    time.Duration
 	SubPrograms []*ProfileResult
+	// Statistics of the garbage collector, nil if they weren't collected
+	GC *GCStats
+}
+
+// Statistics of the garbage collector for the environments of a program or function call
+type GCStats struct {
+	Runs int
+	// The number of identifiers freed
+	Swept    int
+	Duration time.Duration
+	// The most identifiers alive in a single environment when the garbage collector ran
+	PeakLive int
+}
+
+// Adds the statistics of another result, the peak is the greatest of the two
+func (s *GCStats) Add(other GCStats) {
+	s.Runs += other.Runs
+	s.Swept += other.Swept
+	s.Duration += other.Duration
+	if other.PeakLive > s.PeakLive {
+		s.PeakLive = other.PeakLive
+	}
+}
+
+func (s GCStats) String() string {
+	return fmt.Sprint(s.Runs, " runs, ", s.Swept, " swept, ", s.Duration, ", peak of ", s.PeakLive, " live")
+}
+
+// Gets the statistics of the garbage collector for the result and all of it's subprograms
+func (pr *ProfileResult) TotalGCStats() GCStats {
+	var total GCStats
+	if pr.GC != nil {
+		total = *pr.GC
+	}
+	for _, subProgram := range pr.SubPrograms {
+		total.Add(subProgram.TotalGCStats())
+	}
+	return total
 }
 
 // Uses insertion sort to sort the durations of the subprograms from slowest to fastest.
@@ -38,6 +76,9 @@ func (pr *ProfileResult) ToSortedStringFormat(indentAmount int) string {
 		indent += "\t"
 	}
 	output := indent + "- " + pr.Name + " " + pr.Duration.String()
+	if pr.GC != nil {
+		output += " (gc: " + pr.GC.String() + ")"
+	}
 	if len(pr.SubPrograms) > 0 {
 		output += ":\n"
 		subPrograms := pr.SortSubPrograms()
@@ -58,6 +99,10 @@ func (pr *ProfileResult) ToCsv() string {
 
 func (pr *ProfileResult) generateCsvOutput(index int, parentIndex int) (string, int) {
 	csv := fmt.Sprint(parentIndex) + "," + pr.Name + "," + fmt.Sprint(pr.Duration.Nanoseconds())
+	// Garbage collector statistics are optional columns so that results without them can still be read
+	if pr.GC != nil {
+		csv += "," + fmt.Sprint(pr.GC.Runs) + "," + fmt.Sprint(pr.GC.Swept) + "," + fmt.Sprint(pr.GC.Duration.Nanoseconds()) + "," + fmt.Sprint(pr.GC.PeakLive)
+	}
 	currentIndex := index
 	for _, subProgram := range pr.SubPrograms {
 		var output string
@@ -74,7 +119,7 @@ func ParseCsv(csv string) (*ProfileResult, error) {
 	for i, line := range lines {
 		values := strings.Split(line, ",")
 
-		if len(values) != 3 {
+		if len(values) != 3 && len(values) != 7 {
 			return nil, errors.New("profiler CSV data is invalid (too many values on line)")
 		}
 		parentIndex, err := strconv.Atoi(values[0])
@@ -91,6 +136,21 @@ func ParseCsv(csv string) (*ProfileResult, error) {
 			Duration:    time.Nanosecond * time.Duration(durationNanoseconds),
 			SubPrograms: make([]*ProfileResult, 0),
 		}
+		if len(values) == 7 {
+			gcValues := make([]int, 4)
+			for j, value := range values[3:] {
+				gcValues[j], err = strconv.Atoi(value)
+				if err != nil {
+					return nil, errors.New("profiler CSV data is invalid (failed to read garbage collector integer)")
+				}
+			}
+			result.GC = &GCStats{
+				Runs:     gcValues[0],
+				Swept:    gcValues[1],
+				Duration: time.Nanosecond * time.Duration(gcValues[2]),
+				PeakLive: gcValues[3],
+			}
+		}
 		results[i] = result
 
 		if parentIndex != 0 {
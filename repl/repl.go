@@ -0,0 +1,132 @@
+package repl
+
+// A REPL runs each input in the same environment, so the identifiers it declares can be used by the inputs after it.
+// The value and type of expressions are printed, and input continues on the next line while brackets are open. Inputs
+// starting with a colon are commands:
+//
+//	:type expr  prints the type of an expression without evaluating it
+//	:load file  runs a program in the session
+//	:reset      starts a new session
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"main/interpreter"
+	standardlibrary "main/standard_library"
+	keyvalue "main/standard_library/key_value.go"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Runs a REPL session that reads inputs from a reader until it ends, writing the prompts and results to a writer
+func Run(r io.Reader, w io.Writer) {
+	runtime := newRuntime()
+	scanner := bufio.NewScanner(r)
+	input := ""
+	fmt.Fprint(w, "> ")
+	for scanner.Scan() {
+		input += scanner.Text() + "\n"
+		if openBrackets(input) > 0 {
+			fmt.Fprint(w, "... ")
+			continue
+		}
+		if strings.TrimSpace(input) == ":reset" {
+			runtime = newRuntime()
+		} else {
+			evaluate(runtime, strings.TrimSpace(input), w)
+		}
+		input = ""
+		fmt.Fprint(w, "> ")
+	}
+	fmt.Fprintln(w)
+}
+
+// Creates a runtime with the standard library registered, used as the environment of a REPL session
+func newRuntime() *interpreter.Runtime {
+	runtime := interpreter.NewRuntime()
+	standardlibrary.Register(runtime)
+	keyvalue.Register(runtime)
+	return runtime
+}
+
+// Gets the number of brackets that are open at the end of the input, which is 0 once the input can't be read further
+// so that the error is reported
+func openBrackets(input string) int {
+	lexer := interpreter.NewLexer(input)
+	open := 0
+	for {
+		token, err := lexer.Next()
+		if err != nil {
+			return 0
+		}
+		if token.Type == interpreter.TokenEOF {
+			return open
+		}
+		switch token.Type {
+		case interpreter.TokenLeftBrace, interpreter.TokenLeftBracket, interpreter.TokenLeftSquareBracket:
+			open++
+		case interpreter.TokenRightBrace, interpreter.TokenRightBracket, interpreter.TokenRightSquareBracket:
+			open--
+		}
+	}
+}
+
+// Runs an input of a REPL session and prints it's result, errors are printed without ending the session
+func evaluate(runtime *interpreter.Runtime, input string, w io.Writer) {
+	// A bug in the interpreter shouldn't lose the session either
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			fmt.Fprintln(w, "Internal error:", recovered)
+		}
+	}()
+
+	source := input
+	var err error
+	if expression, ok := strings.CutPrefix(input, ":type "); ok {
+		source = expression
+		var def interpreter.TypeDef
+		if def, err = runtime.TypeOf(expression); err == nil {
+			fmt.Fprintln(w, interpreter.TypeDefString(def))
+		}
+	} else if path, ok := strings.CutPrefix(input, ":load "); ok {
+		path = strings.TrimSpace(path)
+		content, readErr := os.ReadFile(path)
+		if readErr != nil {
+			fmt.Fprintln(w, "Error reading file:", readErr)
+			return
+		}
+		source = string(content)
+		err = runtime.RunFile(path)
+	} else if strings.HasPrefix(input, ":") {
+		fmt.Fprintln(w, "Unknown command, the commands are :type expr, :load file and :reset")
+		return
+	} else if input != "" {
+		var value any
+		var def interpreter.TypeDef
+		value, def, err = runtime.Eval(input)
+		// Functions that don't return a value have nothing to print
+		if err == nil && def != nil && def.GetGenericType() != interpreter.TypeNil {
+			if text, ok := value.(string); ok {
+				value = strconv.Quote(text)
+			}
+			fmt.Fprintln(w, value, "("+interpreter.TypeDefString(def)+")")
+		}
+	}
+
+	var parseErr *interpreter.ParseError
+	var runtimeErr *interpreter.RuntimeError
+	if errors.As(err, &parseErr) {
+		for _, diagnostic := range parseErr.Diagnostics {
+			fmt.Fprint(w, diagnostic.Render(parseErr.FilePath, source))
+		}
+	} else if errors.As(err, &runtimeErr) {
+		fmt.Fprintln(w, "panic:", runtimeErr)
+		fmt.Fprint(w, runtimeErr.Position.Snippet(source))
+		fmt.Fprintln(w, runtimeErr.StackTrace())
+	} else if err != nil {
+		fmt.Fprintln(w, "Error:", err)
+	}
+}
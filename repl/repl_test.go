@@ -0,0 +1,49 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Loads a file that ends inside a function after a return in to a REPL session, checking that the error is reported
+// and that the session and it's environment are still usable after
+func TestSessionLoadBrokenFile(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.lang")
+	if err := os.WriteFile(broken, []byte("fn f(): int64 {\n\treturn 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	working := filepath.Join(dir, "working.lang")
+	if err := os.WriteFile(working, []byte("var y = 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	Run(strings.NewReader(strings.Join([]string{
+		"var x = 40",
+		":load " + broken,
+		"x + 1",
+		":load " + working,
+		"x + y",
+		":type f",
+	}, "\n")+"\n"), &output)
+
+	results := output.String()
+	for _, expected := range []string{
+		"Reached end of file before the end of the code block.",
+		"41 (int64)",
+		"42 (int64)",
+		// The declarations of the broken file aren't kept
+		"f is not defined in this scope.",
+	} {
+		if !strings.Contains(results, expected) {
+			t.Errorf("Expected the session to print %q, got:\n%s", expected, results)
+		}
+	}
+	if !strings.HasSuffix(results, "> \n") {
+		t.Errorf("Expected the session to end once the input ended, got:\n%s", results)
+	}
+}
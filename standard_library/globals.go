@@ -7,29 +7,26 @@ import (
 	"os"
 )
 
-// Definition for use by parser for type checking of print function
-var PrintDef = interpreter.FuncDef{
-	GenericTypeDef: interpreter.GenericTypeDef{Type: interpreter.TypeFunc},
-	Args: []interpreter.TypeDef{
-		interpreter.GenericTypeDef{Type: interpreter.TypeAny},
-	},
-	Variadic:   true,
-	ReturnType: nil,
+// The global functions of the standard library, the definitions used by the parser for type checking are derived
+// from their Go types
+var Globals = map[string]any{
+	"print": Print,
+	"input": Input,
 }
 
 func Print(args ...any) {
 	fmt.Println(args...)
 }
 
-// Definition for use by parser for type checking of input function
-var InputDef = interpreter.FuncDef{
-	GenericTypeDef: interpreter.GenericTypeDef{Type: interpreter.TypeFunc},
-	Args:           make([]interpreter.TypeDef, 0),
-	ReturnType:     interpreter.GenericTypeDef{Type: interpreter.TypeString},
-}
-
 func Input() string {
 	reader := bufio.NewReader(os.Stdin)
 	line, _, _ := reader.ReadLine()
 	return string(line)
 }
+
+// Registers the global functions of the standard library with a runtime
+func Register(runtime *interpreter.Runtime) {
+	for name, fn := range Globals {
+		runtime.RegisterFunc(name, fn)
+	}
+}
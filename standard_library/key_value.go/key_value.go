@@ -2,105 +2,72 @@ package keyvalue
 
 import (
 	"database/sql"
+	"errors"
 	"main/interpreter"
-	"main/interpreter/interop"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// Definition of open function that returns a database struct
-var OpenDef = interpreter.FuncDef{
-	GenericTypeDef: interpreter.GenericTypeDef{Type: interpreter.TypeFunc},
-	Args: []interpreter.TypeDef{
-		interpreter.GenericTypeDef{
-			Type: interpreter.TypeString,
-		},
-	},
-	ReturnType: interpreter.StructDef{
-		GenericTypeDef: interpreter.GenericTypeDef{Type: interpreter.TypeStruct},
-		Properties: map[string]int{
-			"get":    0,
-			"set":    1,
-			"delete": 2,
-			"close":  3,
-		},
-		PropertyDefs: []interpreter.TypeDef{
-			GetDef,
-			SetDef,
-			DeleteDef,
-			CloseDef,
-		},
-		Name: "KeyValueDb",
-	},
+// The functions of the key_value module, the definitions used by the parser for type checking are derived from their
+// Go types
+var Module = map[string]any{
+	"open": Open,
 }
 
+// A database of string values stored by their key, the exported methods are the methods of the struct that open returns
 type KeyValueDb struct {
 	db *sql.DB
 }
 
 // Opens the database
-func Open(file string) []any {
+func Open(file string) (*KeyValueDb, error) {
 	db, err := sql.Open("sqlite3", file)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS key_value (
 	  key VARCHAR(255) NOT NULL PRIMARY KEY,
 	  value VARCHAR(65535) NOT NULL
 	);`); err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	return interop.CreateRuntimeStruct(&KeyValueDb{db}, []string{"Get", "Set", "Delete", "Close"})
+	return &KeyValueDb{db}, nil
 }
 
-var GetDef = interpreter.FuncDef{
-	GenericTypeDef: interpreter.GenericTypeDef{Type: interpreter.TypeFunc},
-	Args: []interpreter.TypeDef{
-		interpreter.GenericTypeDef{Type: interpreter.TypeString},
-	},
-	ReturnType: interpreter.GenericTypeDef{Type: interpreter.TypeString},
-}
-
-// Gets a value by it's key
-func (kv *KeyValueDb) Get(key string) string {
+// Gets a value by it's key, an empty string if there is no value for the key
+func (kv *KeyValueDb) Get(key string) (string, error) {
 	row := kv.db.QueryRow("SELECT value FROM key_value WHERE key = ?;", key)
 	var result string
-	row.Scan(&result)
-	return result
-}
-
-var SetDef = interpreter.FuncDef{
-	GenericTypeDef: interpreter.GenericTypeDef{Type: interpreter.TypeFunc},
-	Args: []interpreter.TypeDef{
-		interpreter.GenericTypeDef{Type: interpreter.TypeString},
-		interpreter.GenericTypeDef{Type: interpreter.TypeString},
-	},
+	if err := row.Scan(&result); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	return result, nil
 }
 
 // Sets a value by it's key
-func (kv *KeyValueDb) Set(key string, value string) {
-	kv.db.Exec("INSERT INTO key_value VALUES (?, ?) ON CONFLICT DO UPDATE SET value = ?;", key, value, value)
-}
-
-var DeleteDef = interpreter.FuncDef{
-	GenericTypeDef: interpreter.GenericTypeDef{Type: interpreter.TypeFunc},
-	Args: []interpreter.TypeDef{
-		interpreter.GenericTypeDef{Type: interpreter.TypeString},
-	},
+func (kv *KeyValueDb) Set(key string, value string) error {
+	_, err := kv.db.Exec("INSERT INTO key_value VALUES (?, ?) ON CONFLICT DO UPDATE SET value = ?;", key, value, value)
+	return err
 }
 
 // Deletes a value by it's key
-func (kv *KeyValueDb) Delete(key string) {
-	kv.db.Exec("DELETE FROM key_value WHERE key = ?", key)
+func (kv *KeyValueDb) Delete(key string) error {
+	_, err := kv.db.Exec("DELETE FROM key_value WHERE key = ?", key)
+	return err
 }
 
-var CloseDef = interpreter.FuncDef{
-	GenericTypeDef: interpreter.GenericTypeDef{Type: interpreter.TypeFunc},
+// Closes open database
+func (kv *KeyValueDb) Close() error {
+	return kv.db.Close()
 }
 
-// Closes open database
-func (kv *KeyValueDb) Close() {
-	kv.db.Close()
+// Registers the key_value module with a runtime
+func Register(runtime *interpreter.Runtime) {
+	module := interpreter.NewModule()
+	for name, fn := range Module {
+		module.RegisterFunc(name, fn)
+	}
+	runtime.RegisterModule("key_value", module)
 }